@@ -9,6 +9,7 @@ import (
 
 	"github.com/anchore/ecs-inventory/internal/config"
 	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/redact"
 	"github.com/anchore/ecs-inventory/pkg"
 	pkgLog "github.com/anchore/ecs-inventory/pkg/logger"
 )
@@ -22,11 +23,13 @@ var (
 func init() {
 	setGlobalCliOptions()
 
-	cobra.OnInitialize(
-		InitAppConfig,
-		initLogging,
-		logAppConfig,
-	)
+	// initialize the app before running any command, commands that need to handle loading the application config
+	// themselves (e.g. "config validate") can override this with their own PersistentPreRun
+	rootCmd.PersistentPreRun = func(_ *cobra.Command, _ []string) {
+		InitAppConfig()
+		initLogging()
+		logAppConfig()
+	}
 }
 
 func setGlobalCliOptions() {
@@ -55,10 +58,46 @@ func GetAppConfig() *config.AppConfig {
 	return appConfig
 }
 
+// configureRedaction ensures secrets from the config and sensitive tag values are not logged
+func configureRedaction(cfg *config.AppConfig) {
+	redact.Default.SetValues(redact.SensitiveValues(cfg)...)
+	redact.Default.SetTagKeys(cfg.Redact.TagKeys)
+}
+
+// configureLogging applies the log level of a reloaded config to the logger built from the running config, the other
+// logging settings only take effect once restarted
+func configureLogging(running *config.AppConfig) func(*config.AppConfig) {
+	level := running.Log.Level
+	return func(cfg *config.AppConfig) {
+		if zapLogger, ok := logger.Log.(*logger.ZapLogger); ok && cfg.Log.Level != level {
+			if err := zapLogger.SetLevel(cfg.Log.Level); err != nil {
+				log.Error("Unable to change the log level", err)
+			} else {
+				log.Info("Log level changed", "previous", level, "current", cfg.Log.Level)
+				level = cfg.Log.Level
+			}
+		}
+		if keys := config.LoggingChangesNeedingRestart(running.Log, cfg.Log); len(keys) > 0 {
+			log.Warn("Logging settings changed, restart to apply them", "keys", keys)
+		}
+	}
+}
+
 func initLogging() {
+	configureRedaction(appConfig)
+
 	logConfig := logger.LogConfig{
 		Level:        appConfig.Log.Level,
+		Format:       appConfig.Log.Format,
 		FileLocation: appConfig.Log.FileLocation,
+		Rotation: logger.Rotation{
+			MaxSizeMB:  appConfig.Log.Rotation.MaxSizeMB,
+			MaxAgeDays: appConfig.Log.Rotation.MaxAgeDays,
+			MaxBackups: appConfig.Log.Rotation.MaxBackups,
+			Compress:   appConfig.Log.Rotation.Compress,
+		},
+		Stdout:   appConfig.Log.Stdout,
+		Redactor: redact.Default,
 	}
 
 	logger.Log = logger.InitZapLogger(logConfig)
@@ -68,4 +107,7 @@ func initLogging() {
 
 func logAppConfig() {
 	log.Debug("Application config", "config", appConfig)
+	for _, unknown := range config.UnknownEnvVars() {
+		log.Warn("Ignoring unknown environment variable", "name", unknown.Key)
+	}
 }
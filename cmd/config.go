@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/anchore/ecs-inventory/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspect, validate and initialize the application config",
+	// config subcommands load (or deliberately don't load) the application config themselves, so that a broken
+	// config can be reported on rather than preventing the command from running
+	PersistentPreRun: func(_ *cobra.Command, _ []string) {},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate the application config, reporting unknown keys and invalid values",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		cfg, err := config.LoadConfigFromFile(viper.GetViper(), &cliOnlyOpts)
+		if err != nil {
+			var validationErrs config.ValidationErrors
+			if !errors.As(err, &validationErrs) {
+				fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Invalid config, %d problem(s) found:\n", len(validationErrs))
+			for _, validationErr := range validationErrs {
+				fmt.Fprintf(os.Stderr, "  - %s\n", validationErr.Error())
+			}
+			os.Exit(1)
+		}
+
+		if file := viper.GetViper().ConfigFileUsed(); file != "" {
+			fmt.Printf("Config is valid: %s\n", file)
+		} else {
+			fmt.Println("Config is valid (defaults and environment variables only)")
+		}
+		for _, unknown := range config.UnknownEnvVars() {
+			fmt.Printf("Warning: %s, it is ignored\n", unknown.Error())
+		}
+		if cfg.Region == "" {
+			fmt.Println("Note: no region is set, one must be provided with --region or ANCHORE_ECS_INVENTORY_REGION to run")
+		}
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show the effective config (with secrets redacted) and where each value came from",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		cfg, err := config.LoadConfigFromFile(viper.GetViper(), &cliOnlyOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load application config: \n\t%+v\n", err)
+			os.Exit(1)
+		}
+
+		description, err := config.Describe(viper.GetViper(), cfg, rootCmd.PersistentFlags())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to describe application config: %+v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(description)
+	},
+}
+
+var forceConfigInit bool
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "write a commented config file containing the default values",
+	Long: fmt.Sprintf(
+		"Write a commented config file containing the default values to %s (or the path given with --config)",
+		config.DefaultConfigPath(),
+	),
+	Args: cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		path := cliOnlyOpts.ConfigPath
+		if path == "" {
+			path = config.DefaultConfigPath()
+		}
+
+		if err := config.WriteDefaultConfig(path, forceConfigInit); err != nil {
+			if errors.Is(err, config.ErrConfigFileExists) {
+				fmt.Fprintf(os.Stderr, "%v, use --force to overwrite it\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Failed to write config: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote default config to %s\n", path)
+	},
+}
+
+func init() {
+	configInitCmd.Flags().BoolVarP(&forceConfigInit, "force", "f", false, "overwrite an existing config file")
+
+	configCmd.AddCommand(configValidateCmd, configShowCmd, configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
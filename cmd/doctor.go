@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/ecs-inventory/pkg/doctor"
+)
+
+var doctorCluster string
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check AWS credentials, IAM permissions and the Anchore connection",
+	Long: `Check that anchore-ecs-inventory can run with the current config: that AWS credentials resolve, that each
+action in the IAM policy for the config is allowed (ECS actions against one cluster, the first found or the one given
+with --cluster, and SQS actions against events.queue-url), and that Anchore is reachable and accepts the credentials.
+No calls change anything, a message received from the queue is left on it. With --dry-run credentials are resolved
+but the AWS and Anchore APIs are not called.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		checks := doctor.Run(cmd.Context(), doctor.Options{
+			Region:         appConfig.Region,
+			Cluster:        doctorCluster,
+			Collect:        collectOptions(appConfig),
+			Events:         appConfig.Events.Enabled,
+			QueueURL:       appConfig.Events.QueueURL,
+			QueueEndpoint:  appConfig.Events.Endpoint,
+			AnchoreDetails: appConfig.AnchoreDetails,
+			DryRun:         appConfig.DryRun,
+		})
+
+		fmt.Println()
+		doctor.Print(os.Stdout, checks)
+		if doctor.Failed(checks) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorCluster, "cluster", "", "name or ARN of the cluster to check permissions against (default is the first cluster found)")
+
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/anchore/ecs-inventory/pkg/inventory"
+)
+
+var iamPolicyOpts struct {
+	scope           bool
+	account         string
+	clusters        []string
+	taskDefinitions bool
+	deployable      bool
+	events          bool
+}
+
+var iamPolicyCmd = &cobra.Command{
+	Use:   "iam-policy",
+	Short: "print the least privilege IAM policy needed to run",
+	Long: `Print the least privilege IAM policy document needed to run with the current config, for the IAM role or user
+anchore-ecs-inventory runs as. By default the policy applies to all clusters in all regions, use --scope to restrict it
+to the configured region (and --account and --cluster to restrict it further).`,
+	Args: cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		opts := inventory.PolicyOptions{
+			Account:  iamPolicyOpts.account,
+			Clusters: iamPolicyOpts.clusters,
+			Collect:  collectOptions(appConfig),
+			Events:   iamPolicyOpts.events || appConfig.Events.Enabled,
+		}
+		opts.Collect.TaskDefinitions = opts.Collect.TaskDefinitions || iamPolicyOpts.taskDefinitions
+		opts.Collect.DeployableImages = opts.Collect.DeployableImages || iamPolicyOpts.deployable
+		if iamPolicyOpts.scope && appConfig.Region != "" {
+			opts.Regions = []string{appConfig.Region}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(inventory.Policy(opts)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to show IAM policy: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	flags := iamPolicyCmd.Flags()
+	flags.BoolVar(&iamPolicyOpts.scope, "scope", false, "scope the policy to the configured region")
+	flags.StringVar(&iamPolicyOpts.account, "account", "", "scope the policy to an AWS account ID")
+	flags.StringArrayVar(&iamPolicyOpts.clusters, "cluster", nil, "scope the policy to a cluster name or ARN (can be repeated)")
+	flags.BoolVar(&iamPolicyOpts.taskDefinitions, "task-definitions", false, "include the ECS actions needed to describe task definitions (included when collect.task-definitions or collect.environment-secrets is enabled)")
+	flags.BoolVar(&iamPolicyOpts.deployable, "deployable-images", false, "include the ECS and EventBridge actions needed to find task definition families and scheduled tasks (included when collect.deployable-images is enabled)")
+	flags.BoolVar(&iamPolicyOpts.events, "events", false, "include the SQS actions needed to consume ECS events (included when events.enabled is set)")
+
+	rootCmd.AddCommand(iamPolicyCmd)
+}
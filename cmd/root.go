@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/anchore/ecs-inventory/internal/config"
+	"github.com/anchore/ecs-inventory/internal/tracing"
 	"github.com/anchore/ecs-inventory/pkg"
+	"github.com/anchore/ecs-inventory/pkg/inventory"
 	"github.com/anchore/ecs-inventory/pkg/reporter"
 )
 
@@ -33,8 +38,8 @@ var rootCmd = &cobra.Command{
 		}
 		log.Info("Starting anchore-ecs-inventory")
 
-		// Check required config values are present
-		if appConfig.Region == "" {
+		// Check required config values are present, in daemon mode the region is that of the container instance
+		if appConfig.Region == "" && !appConfig.Daemon.Enabled {
 			log.Error(
 				"AWS region not specified, please set the ANCHORE_ECS_INVENTORY_REGION environment variable, use the --region flag, or specify a region in the config file",
 				ErrMissingDefaultConfigValue,
@@ -42,6 +47,21 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		// stopping the agent cancels the context, so the inventory loops return and buffered spans can be exported
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		// a second signal exits straight away, rather than waiting for the loops to return
+		context.AfterFunc(ctx, stop)
+
+		shutdownTracing, err := tracing.Init(ctx, appConfig.Tracing)
+		if err != nil {
+			log.Error("Failed to initialize tracing", err)
+			os.Exit(1)
+		}
+		if appConfig.Tracing.Enabled {
+			defer flushTraces(shutdownTracing)
+		}
+
 		// Validate anchore connection & credentials, using a dummy report to post but this will be
 		// replaced in the future with a health check endpoint for the agents
 		if appConfig.AnchoreDetails.IsValid() {
@@ -49,7 +69,7 @@ var rootCmd = &cobra.Command{
 				ClusterARN: "validating-creds",
 				Timestamp:  time.Now().UTC().Format(time.RFC3339),
 			}
-			err := reporter.Post(dummyReport, appConfig.AnchoreDetails)
+			err := reporter.PostWithContext(ctx, dummyReport, appConfig.AnchoreDetails)
 			if err != nil {
 				log.Error("Failed to validate connection to Anchore", err)
 			} else {
@@ -59,45 +79,98 @@ var rootCmd = &cobra.Command{
 			log.Warn("Anchore details not specified, will not report inventory")
 		}
 
-		pkg.PeriodicallyGetInventoryReport(
-			appConfig.PollingIntervalSeconds,
-			appConfig.AnchoreDetails,
-			appConfig.Region,
-			appConfig.Quiet,
-			appConfig.DryRun,
-		)
+		if err := startHTTPServers(ctx); err != nil {
+			log.Error("Failed to start HTTP server", err)
+			os.Exit(1)
+		}
+
+		reloader := config.NewReloader(viper.GetViper(), appConfig)
+		reloader.OnReload(configureRedaction)
+		reloader.OnReload(configureLogging(appConfig))
+		if err := reloader.Watch(ctx); err != nil {
+			log.Warn("Unable to watch for config changes, restart to apply config changes", "err", err)
+		}
+
+		if appConfig.Events.Enabled {
+			cfg := reloader.Current()
+			if err := pkg.ConsumeInventoryEvents(ctx, inventoryOptions(cfg), eventOptions(cfg)); err != nil {
+				log.Error("Failed to consume ECS events", err)
+				os.Exit(1)
+			}
+		} else {
+			pkg.PeriodicallyGetInventoryReportWithOptions(ctx, func() pkg.Options {
+				return inventoryOptions(reloader.Current())
+			})
+		}
+		log.Info("Stopping anchore-ecs-inventory")
 	},
 }
 
+// inventoryOptions maps the application config to the options of the inventory, in daemon mode only the container
+// instance the agent runs on is reported
+func inventoryOptions(cfg *config.AppConfig) pkg.Options {
+	opts := pkg.Options{
+		Region:          cfg.Region,
+		PollingInterval: time.Duration(cfg.PollingIntervalSeconds) * time.Second,
+		AnchoreDetails:  cfg.AnchoreDetails,
+		Collect:         collectOptions(cfg),
+		Quiet:           cfg.Quiet,
+		DryRun:          cfg.DryRun,
+	}
+	if cfg.Daemon.Enabled {
+		opts.IntrospectionURL = cfg.Daemon.IntrospectionURL
+		opts.DockerSocket = cfg.Daemon.DockerSocket
+	}
+	return opts
+}
+
+func collectOptions(cfg *config.AppConfig) inventory.CollectOptions {
+	return inventory.CollectOptions{
+		TaskDefinitions:    cfg.Collect.TaskDefinitions,
+		EnvironmentSecrets: cfg.Collect.EnvironmentSecrets,
+		DeployableImages:   cfg.Collect.DeployableImages,
+		StoppedTasks:       cfg.Collect.StoppedTasks,
+	}
+}
+
+func eventOptions(cfg *config.AppConfig) inventory.EventOptions {
+	return inventory.EventOptions{
+		QueueURL:          cfg.Events.QueueURL,
+		Endpoint:          cfg.Events.Endpoint,
+		FlushInterval:     time.Duration(cfg.Events.FlushIntervalSeconds) * time.Second,
+		ReconcileInterval: time.Duration(cfg.Events.ReconcileIntervalSeconds) * time.Second,
+	}
+}
+
 func init() {
 	opt := "polling-interval-seconds"
-	rootCmd.Flags().
+	rootCmd.PersistentFlags().
 		StringP(opt, "p", strconv.Itoa(config.DefaultConfigValues.PollingIntervalSeconds), "this specifies the polling interval of the ECS API in seconds")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
+	if err := viper.BindPFlag(opt, rootCmd.PersistentFlags().Lookup(opt)); err != nil {
 		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
 		os.Exit(1)
 	}
 
 	opt = "region"
-	rootCmd.Flags().
+	rootCmd.PersistentFlags().
 		StringP(opt, "r", config.DefaultConfigValues.Region, "if set overrides the AWS_REGION environment variable/region specified in anchore-ecs-inventory config")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
+	if err := viper.BindPFlag(opt, rootCmd.PersistentFlags().Lookup(opt)); err != nil {
 		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
 		os.Exit(1)
 	}
 
 	opt = "quiet"
-	rootCmd.Flags().
+	rootCmd.PersistentFlags().
 		BoolP(opt, "q", config.DefaultConfigValues.Quiet, "suppresses inventory report output to stdout")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
+	if err := viper.BindPFlag(opt, rootCmd.PersistentFlags().Lookup(opt)); err != nil {
 		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
 		os.Exit(1)
 	}
 
 	opt = "dry-run"
-	rootCmd.Flags().
+	rootCmd.PersistentFlags().
 		BoolP(opt, "d", config.DefaultConfigValues.DryRun, "do not report inventory to Anchore")
-	if err := viper.BindPFlag(opt, rootCmd.Flags().Lookup(opt)); err != nil {
+	if err := viper.BindPFlag(opt, rootCmd.PersistentFlags().Lookup(opt)); err != nil {
 		fmt.Printf("unable to bind flag '%s': %+v", opt, err)
 		os.Exit(1)
 	}
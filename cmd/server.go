@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/anchore/ecs-inventory/internal/health"
+	"github.com/anchore/ecs-inventory/internal/metrics"
+)
+
+// startHTTPServers serves the configured HTTP endpoints in the background until the context is done, endpoints
+// configured with the same address share a server. It fails if an address can't be listened on, but later errors are
+// only logged since serving is secondary to the inventory itself.
+func startHTTPServers(ctx context.Context) error {
+	muxes := map[string]*http.ServeMux{}
+	muxFor := func(address string) *http.ServeMux {
+		if _, ok := muxes[address]; !ok {
+			muxes[address] = http.NewServeMux()
+		}
+		return muxes[address]
+	}
+
+	if appConfig.Metrics.Enabled {
+		muxFor(appConfig.Metrics.Address).Handle("/metrics", metrics.Handler())
+	}
+	if appConfig.Health.Enabled {
+		health.Register(muxFor(appConfig.Health.Address), health.Default, appConfig.Health.MaxMissedIntervals)
+	}
+
+	addresses := make([]string, 0, len(muxes))
+	for address := range muxes {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if err := startHTTPServer(ctx, address, muxes[address]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startHTTPServer(ctx context.Context, address string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %w", address, err)
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("HTTP server failed", err)
+		}
+	}()
+	log.Info("Serving HTTP endpoints", "address", listener.Addr().String())
+	return nil
+}
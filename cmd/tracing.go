@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"context"
+	"time"
+)
+
+// flushTraces exports any spans still buffered once the agent stops, giving up after a few seconds so an unreachable
+// collector doesn't hold up the exit
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		log.Error("Failed to flush traces", err)
+	}
+}
@@ -12,6 +12,7 @@ are listed below in order of precedence:
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"strings"
 
@@ -21,11 +22,10 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/anchore/ecs-inventory/internal"
+	"github.com/anchore/ecs-inventory/internal/redact"
 	"github.com/anchore/ecs-inventory/pkg/connection"
 )
 
-const redacted = "******"
-
 // Configuration options that may only be specified on the command line
 type CliOnlyOptions struct {
 	ConfigPath string
@@ -40,18 +40,127 @@ type AppConfig struct {
 	Region                 string                 `mapstructure:"region"`
 	Quiet                  bool                   `mapstructure:"quiet"`   // if true do not log the inventory report to stdout
 	DryRun                 bool                   `mapstructure:"dry-run"` // if true do not report inventory to Anchore
+	Redact                 Redaction              `mapstructure:"redact"`
+	Metrics                Metrics                `mapstructure:"metrics"`
+	Health                 Health                 `mapstructure:"health"`
+	Tracing                Tracing                `mapstructure:"tracing"`
+	Collect                Collect                `mapstructure:"collect"`
+	Events                 Events                 `mapstructure:"events"`
+	Daemon                 Daemon                 `mapstructure:"daemon"`
 }
 
 // Logging Configuration
 type Logging struct {
 	Level        string `mapstructure:"level"`
 	FileLocation string `mapstructure:"file"`
+	// console or json, if empty log files are written as json and stdout as console
+	Format string `mapstructure:"format"`
+	// if true log to stdout as well as the log file
+	Stdout   bool        `mapstructure:"stdout"`
+	Rotation LogRotation `mapstructure:"rotation"`
+}
+
+// LogRotation Configuration, the log file is rotated when it reaches the maximum size
+type LogRotation struct {
+	// size in megabytes at which the log file is rotated
+	MaxSizeMB int `mapstructure:"max-size-mb"`
+	// days to keep rotated log files, 0 keeps them regardless of age
+	MaxAgeDays int `mapstructure:"max-age-days"`
+	// number of rotated log files to keep, 0 keeps them all
+	MaxBackups int `mapstructure:"max-backups"`
+	// if true gzip rotated log files
+	Compress bool `mapstructure:"compress"`
+}
+
+// Redaction Configuration, sensitive config values (those tagged `sensitive:"true"`) are always redacted
+type Redaction struct {
+	// patterns (see path.Match) of ECS tag keys whose values are redacted from logs and failed payload dumps
+	TagKeys []string `mapstructure:"tag-keys"`
+}
+
+// Metrics Configuration
+type Metrics struct {
+	// if true serve prometheus metrics on /metrics
+	Enabled bool `mapstructure:"enabled"`
+	// address (host:port) for the metrics server to listen on
+	Address string `mapstructure:"address"`
+}
+
+// Health Configuration
+type Health struct {
+	// if true serve /healthz, /readyz and /status
+	Enabled bool `mapstructure:"enabled"`
+	// address (host:port) for the health server to listen on, this may be the same as the metrics address
+	Address string `mapstructure:"address"`
+	// number of polling intervals without progress (or success) before the agent is unhealthy (or not ready)
+	MaxMissedIntervals int `mapstructure:"max-missed-intervals"`
+}
+
+// Tracing Configuration
+type Tracing struct {
+	// if true export OpenTelemetry traces of each polling cycle
+	Enabled bool `mapstructure:"enabled"`
+	// OTLP protocol to export traces with, grpc or http/protobuf
+	Protocol string `mapstructure:"protocol"`
+	// address (host:port) of the OTLP collector, if empty the OTEL_EXPORTER_OTLP_* environment variables are used
+	Endpoint string `mapstructure:"endpoint"`
+	// if true export without TLS
+	Insecure bool `mapstructure:"insecure"`
+	// fraction of polling cycles to trace, between 0 and 1
+	SampleRatio float64 `mapstructure:"sample-ratio"`
+}
+
+// Collect Configuration, optional details to collect in addition to the inventory
+type Collect struct {
+	// if true describe the task definition of each task and report its security posture
+	TaskDefinitions bool `mapstructure:"task-definitions"`
+	// if true scan the environment variables of each task definition for likely secrets, reporting their names only
+	EnvironmentSecrets bool `mapstructure:"environment-secrets"`
+	// if true report the images of the latest revision of every ACTIVE task definition family, and of scheduled tasks,
+	// as deployable
+	DeployableImages bool `mapstructure:"deployable-images"`
+	// if true also report the tasks that stopped since the previous polling cycle
+	StoppedTasks bool `mapstructure:"stopped-tasks"`
+}
+
+// Events Configuration, ECS state change events are consumed from an SQS queue and the region is only polled to
+// reconcile
+type Events struct {
+	// if true consume ECS task and deployment state change events instead of polling every polling interval
+	Enabled bool `mapstructure:"enabled"`
+	// URL of the SQS queue that EventBridge delivers the ECS events to
+	QueueURL string `mapstructure:"queue-url"`
+	// URL of the SQS API, set to use a local SQS compatible service, if empty the regional endpoint is used
+	Endpoint string `mapstructure:"endpoint"`
+	// seconds to gather events for before reporting the clusters they changed
+	FlushIntervalSeconds int `mapstructure:"flush-interval-seconds"`
+	// seconds between full polls of the region, which reconcile the inventory with ECS
+	ReconcileIntervalSeconds int `mapstructure:"reconcile-interval-seconds"`
+}
+
+// Daemon Configuration, the inventory of a single container instance is read from the ECS agent running on it
+type Daemon struct {
+	// if true report the tasks on this container instance, read from the ECS agent introspection API, instead of polling
+	// the ECS API for the whole region
+	Enabled bool `mapstructure:"enabled"`
+	// URL of the ECS agent introspection API on the container instance
+	IntrospectionURL string `mapstructure:"introspection-url"`
+	// path of the Docker daemon socket, used to find the digests of images referenced by tag, empty to leave them out
+	DockerSocket string `mapstructure:"docker-socket"`
 }
 
 var DefaultConfigValues = AppConfig{
 	Log: Logging{
 		Level:        "",
 		FileLocation: "",
+		Format:       "",
+		Stdout:       false,
+		Rotation: LogRotation{
+			MaxSizeMB:  100,
+			MaxAgeDays: 0,
+			MaxBackups: 5,
+			Compress:   false,
+		},
 	},
 	AnchoreDetails: connection.AnchoreInfo{
 		Account: "admin",
@@ -64,6 +173,43 @@ var DefaultConfigValues = AppConfig{
 	PollingIntervalSeconds: 300,
 	Quiet:                  false,
 	DryRun:                 false,
+	Redact: Redaction{
+		TagKeys: []string{"*password*", "*passwd*", "*secret*", "*token*", "*credential*", "*api*key*", "*private*key*"},
+	},
+	Metrics: Metrics{
+		Enabled: false,
+		Address: ":8080",
+	},
+	Health: Health{
+		Enabled:            false,
+		Address:            ":8080",
+		MaxMissedIntervals: 3,
+	},
+	Tracing: Tracing{
+		Enabled:     false,
+		Protocol:    "grpc",
+		Endpoint:    "",
+		Insecure:    false,
+		SampleRatio: 1,
+	},
+	Collect: Collect{
+		TaskDefinitions:    false,
+		EnvironmentSecrets: false,
+		DeployableImages:   false,
+		StoppedTasks:       false,
+	},
+	Events: Events{
+		Enabled:                  false,
+		QueueURL:                 "",
+		Endpoint:                 "",
+		FlushIntervalSeconds:     10,
+		ReconcileIntervalSeconds: 3600,
+	},
+	Daemon: Daemon{
+		Enabled:          false,
+		IntrospectionURL: "http://localhost:51678",
+		DockerSocket:     "/var/run/docker.sock",
+	},
 }
 
 var ErrConfigFileNotFound = fmt.Errorf("application config file not found")
@@ -71,9 +217,42 @@ var ErrConfigFileNotFound = fmt.Errorf("application config file not found")
 func setDefaultValues(v *viper.Viper) {
 	v.SetDefault("log.level", DefaultConfigValues.Log.Level)
 	v.SetDefault("log.file", DefaultConfigValues.Log.FileLocation)
+	v.SetDefault("log.format", DefaultConfigValues.Log.Format)
+	v.SetDefault("log.stdout", DefaultConfigValues.Log.Stdout)
+	v.SetDefault("log.rotation.max-size-mb", DefaultConfigValues.Log.Rotation.MaxSizeMB)
+	v.SetDefault("log.rotation.max-age-days", DefaultConfigValues.Log.Rotation.MaxAgeDays)
+	v.SetDefault("log.rotation.max-backups", DefaultConfigValues.Log.Rotation.MaxBackups)
+	v.SetDefault("log.rotation.compress", DefaultConfigValues.Log.Rotation.Compress)
 	v.SetDefault("anchore.account", DefaultConfigValues.AnchoreDetails.Account)
 	v.SetDefault("anchore.http.insecure", DefaultConfigValues.AnchoreDetails.HTTP.Insecure)
 	v.SetDefault("anchore.http.timeout-seconds", DefaultConfigValues.AnchoreDetails.HTTP.TimeoutSeconds)
+	v.SetDefault("region", DefaultConfigValues.Region)
+	v.SetDefault("polling-interval-seconds", DefaultConfigValues.PollingIntervalSeconds)
+	v.SetDefault("quiet", DefaultConfigValues.Quiet)
+	v.SetDefault("dry-run", DefaultConfigValues.DryRun)
+	v.SetDefault("redact.tag-keys", DefaultConfigValues.Redact.TagKeys)
+	v.SetDefault("metrics.enabled", DefaultConfigValues.Metrics.Enabled)
+	v.SetDefault("metrics.address", DefaultConfigValues.Metrics.Address)
+	v.SetDefault("health.enabled", DefaultConfigValues.Health.Enabled)
+	v.SetDefault("health.address", DefaultConfigValues.Health.Address)
+	v.SetDefault("health.max-missed-intervals", DefaultConfigValues.Health.MaxMissedIntervals)
+	v.SetDefault("tracing.enabled", DefaultConfigValues.Tracing.Enabled)
+	v.SetDefault("tracing.protocol", DefaultConfigValues.Tracing.Protocol)
+	v.SetDefault("tracing.endpoint", DefaultConfigValues.Tracing.Endpoint)
+	v.SetDefault("tracing.insecure", DefaultConfigValues.Tracing.Insecure)
+	v.SetDefault("tracing.sample-ratio", DefaultConfigValues.Tracing.SampleRatio)
+	v.SetDefault("collect.task-definitions", DefaultConfigValues.Collect.TaskDefinitions)
+	v.SetDefault("collect.environment-secrets", DefaultConfigValues.Collect.EnvironmentSecrets)
+	v.SetDefault("collect.deployable-images", DefaultConfigValues.Collect.DeployableImages)
+	v.SetDefault("collect.stopped-tasks", DefaultConfigValues.Collect.StoppedTasks)
+	v.SetDefault("events.enabled", DefaultConfigValues.Events.Enabled)
+	v.SetDefault("events.queue-url", DefaultConfigValues.Events.QueueURL)
+	v.SetDefault("events.endpoint", DefaultConfigValues.Events.Endpoint)
+	v.SetDefault("events.flush-interval-seconds", DefaultConfigValues.Events.FlushIntervalSeconds)
+	v.SetDefault("events.reconcile-interval-seconds", DefaultConfigValues.Events.ReconcileIntervalSeconds)
+	v.SetDefault("daemon.enabled", DefaultConfigValues.Daemon.Enabled)
+	v.SetDefault("daemon.introspection-url", DefaultConfigValues.Daemon.IntrospectionURL)
+	v.SetDefault("daemon.docker-socket", DefaultConfigValues.Daemon.DockerSocket)
 }
 
 // Load the Application Configuration from the Viper specifications
@@ -88,20 +267,40 @@ func LoadConfigFromFile(v *viper.Viper, cliOpts *CliOnlyOptions) (*AppConfig, er
 
 	err := readConfig(v, cliOptsConfigPath, internal.ApplicationName)
 	if errors.Is(err, ErrConfigFileNotFound) {
-		fmt.Println(
-			"No config file found. One can be specified with the --config flag or " +
-				"is present at one of the following locations:\n" +
-				"\t- ./anchore-ecs-inventory.yaml\n" +
-				"\t- ./.anchore-ecs-inventory/config.yaml\n" +
-				"\t- $HOME/anchore-ecs-inventory.yaml\n" +
-				"\t- $XDG_CONFIG_HOME/anchore-ecs-inventory/config.yaml\n\n" +
-				"Using default configuration values.")
+		// write to stderr so commands that print machine readable output (e.g. "iam-policy") can be piped
+		fmt.Fprintln(
+			os.Stderr,
+			"No config file found. One can be specified with the --config flag or "+
+				"is present at one of the following locations:\n"+
+				"\t- ./anchore-ecs-inventory.yaml\n"+
+				"\t- ./.anchore-ecs-inventory/config.yaml\n"+
+				"\t- $HOME/anchore-ecs-inventory.yaml\n"+
+				"\t- $XDG_CONFIG_HOME/anchore-ecs-inventory/config.yaml\n\n"+
+				"Using default configuration values.",
+		)
 	} else if err != nil {
 		return nil, err
 	}
 
-	config := &AppConfig{
-		CliOptions: *cliOpts,
+	return buildAppConfig(v, cliOpts)
+}
+
+// buildAppConfig creates a validated AppConfig from the current state of the viper instance
+func buildAppConfig(v *viper.Viper, cliOpts *CliOnlyOptions) (*AppConfig, error) {
+	// check the raw config file first so unknown keys and wrong types are reported with their location, rather
+	// than being silently ignored or failing to unmarshal without context
+	file, errs, err := parseConfigFile(v.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		errs.sort()
+		return nil, fmt.Errorf("invalid config:\n%w", errs)
+	}
+
+	config := &AppConfig{}
+	if cliOpts != nil {
+		config.CliOptions = *cliOpts
 	}
 	err = v.Unmarshal(config)
 	if err != nil {
@@ -113,6 +312,11 @@ func LoadConfigFromFile(v *viper.Viper, cliOpts *CliOnlyOptions) (*AppConfig, er
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	if errs := config.validate(file); len(errs) > 0 {
+		errs.sort()
+		return nil, fmt.Errorf("invalid config:\n%w", errs)
+	}
+
 	return config, nil
 }
 
@@ -144,7 +348,8 @@ func readConfig(v *viper.Viper, configPath, applicationName string) error {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 
 	if configPath != "" {
-		fmt.Println("using config file:", configPath)
+		// stderr, like the message when no config file is found, so machine readable output can be piped
+		fmt.Fprintln(os.Stderr, "using config file:", configPath)
 		v.SetConfigFile(configPath)
 		if err := v.ReadInConfig(); err == nil {
 			return nil
@@ -192,13 +397,14 @@ func readConfig(v *viper.Viper, configPath, applicationName string) error {
 	return ErrConfigFileNotFound
 }
 
+// redacted returns a copy of the config with sensitive information (fields tagged `sensitive:"true"`) redacted
+func (cfg AppConfig) redacted() AppConfig {
+	return redact.Struct(cfg)
+}
+
 func (cfg AppConfig) String() string {
 	// redact sensitive information
-	// Note: If the configuration grows to have more redacted fields it would be good to refactor this into something that
-	// is more dynamic based on a property or list of "sensitive" fields
-	if cfg.AnchoreDetails.Password != "" {
-		cfg.AnchoreDetails.Password = redacted
-	}
+	cfg = cfg.redacted()
 
 	// yaml is pretty human friendly (at least when compared to json)
 	appCfgStr, err := yaml.Marshal(&cfg)
@@ -26,6 +26,7 @@ func TestLoadConfigFromFileCliConfigPath(t *testing.T) {
 		Log: Logging{
 			Level:        "info",
 			FileLocation: "/var/log/anchore-ecs-inventory.log",
+			Rotation:     DefaultConfigValues.Log.Rotation,
 		},
 		AnchoreDetails: connection.AnchoreInfo{
 			Account:  "admin",
@@ -40,6 +41,13 @@ func TestLoadConfigFromFileCliConfigPath(t *testing.T) {
 		Region:                 "us-east-1",
 		PollingIntervalSeconds: 60,
 		Quiet:                  true,
+		Redact:                 DefaultConfigValues.Redact,
+		Metrics:                DefaultConfigValues.Metrics,
+		Health:                 DefaultConfigValues.Health,
+		Tracing:                DefaultConfigValues.Tracing,
+		Collect:                DefaultConfigValues.Collect,
+		Events:                 DefaultConfigValues.Events,
+		Daemon:                 DefaultConfigValues.Daemon,
 	}
 
 	assert.EqualValues(t, expectedCfg, appCfg)
@@ -85,6 +93,13 @@ func TestPasswordsAreObfuscated(t *testing.T) {
 	expected := `log:
   level: ""
   filelocation: ""
+  format: ""
+  stdout: false
+  rotation:
+    maxsizemb: 0
+    maxagedays: 0
+    maxbackups: 0
+    compress: false
 clioptions:
   configpath: testdata/config.yaml
   verbosity: 0
@@ -100,6 +115,36 @@ anchoredetails:
 region: ""
 quiet: false
 dryrun: false
+redact:
+  tagkeys: []
+metrics:
+  enabled: false
+  address: ""
+health:
+  enabled: false
+  address: ""
+  maxmissedintervals: 0
+tracing:
+  enabled: false
+  protocol: ""
+  endpoint: ""
+  insecure: false
+  sampleratio: 0
+collect:
+  taskdefinitions: false
+  environmentsecrets: false
+  deployableimages: false
+  stoppedtasks: false
+events:
+  enabled: false
+  queueurl: ""
+  endpoint: ""
+  flushintervalseconds: 0
+  reconcileintervalseconds: 0
+daemon:
+  enabled: false
+  introspectionurl: ""
+  dockersocket: ""
 `
 
 	assert.Equal(t, expected, config.String())
@@ -122,8 +167,10 @@ func TestDefaultValuesSuppliedForEmptyConfig(t *testing.T) {
 			ConfigPath: configPath,
 		},
 		Log: Logging{
-			Level: "info",
+			Level:    "info",
+			Rotation: DefaultConfigValues.Log.Rotation,
 		},
+		PollingIntervalSeconds: 300,
 		AnchoreDetails: connection.AnchoreInfo{
 			Account:  "admin",
 			Password: "",
@@ -132,6 +179,13 @@ func TestDefaultValuesSuppliedForEmptyConfig(t *testing.T) {
 				TimeoutSeconds: 60,
 			},
 		},
+		Redact:  DefaultConfigValues.Redact,
+		Metrics: DefaultConfigValues.Metrics,
+		Health:  DefaultConfigValues.Health,
+		Tracing: DefaultConfigValues.Tracing,
+		Collect: DefaultConfigValues.Collect,
+		Events:  DefaultConfigValues.Events,
+		Daemon:  DefaultConfigValues.Daemon,
 	}
 
 	assert.EqualValues(t, expectedCfg, appCfg)
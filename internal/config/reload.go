@@ -0,0 +1,166 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+)
+
+// Reloader holds the active application configuration and rebuilds it when the config file changes or the process
+// receives a SIGHUP. A new configuration only replaces the active one if it loads and validates successfully,
+// otherwise the previous configuration keeps running.
+type Reloader struct {
+	mu       sync.Mutex
+	v        *viper.Viper
+	cliOpts  CliOnlyOptions
+	current  atomic.Pointer[AppConfig]
+	onReload []func(*AppConfig)
+}
+
+func NewReloader(v *viper.Viper, cfg *AppConfig) *Reloader {
+	r := &Reloader{
+		v:       v,
+		cliOpts: cfg.CliOptions,
+	}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current returns the active configuration. Callers should take a single snapshot per unit of work (e.g. a polling
+// cycle) so a reload is applied atomically rather than part way through.
+func (r *Reloader) Current() *AppConfig {
+	return r.current.Load()
+}
+
+// OnReload registers a function that is called with the new configuration each time it is successfully reloaded
+func (r *Reloader) OnReload(fn func(*AppConfig)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+// Reload re-reads the config file and swaps the active configuration. On error the active configuration is left
+// untouched.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// when no config file is in use there is nothing to re-read, but the config is still rebuilt so that any
+	// values set directly on the viper instance are picked up
+	if r.v.ConfigFileUsed() != "" {
+		if err := r.v.ReadInConfig(); err != nil {
+			return fmt.Errorf("unable to read config: %w", err)
+		}
+	}
+
+	cfg, err := buildAppConfig(r.v, &r.cliOpts)
+	if err != nil {
+		return err
+	}
+
+	r.current.Store(cfg)
+	for _, fn := range r.onReload {
+		fn(cfg)
+	}
+	return nil
+}
+
+// Watch reloads the configuration whenever the config file is written or the process receives a SIGHUP, until the
+// context is cancelled.
+func (r *Reloader) Watch(ctx context.Context) error {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	var watcher *fsnotify.Watcher
+	configFile := r.v.ConfigFileUsed()
+	if configFile != "" {
+		var err error
+		watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			signal.Stop(sighup)
+			return fmt.Errorf("unable to watch config file: %w", err)
+		}
+		// watch the directory rather than the file itself, many editors replace the file instead of writing to it
+		// which would otherwise drop the watch
+		if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+			signal.Stop(sighup)
+			watcher.Close()
+			return fmt.Errorf("unable to watch config file: %w", err)
+		}
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	go func() {
+		defer signal.Stop(sighup)
+		if watcher != nil {
+			defer watcher.Close()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				r.reload("SIGHUP received")
+			case event := <-events:
+				if isConfigFileEvent(event, configFile) {
+					r.reload("config file changed")
+				}
+			case err := <-watchErrors:
+				// the errors have to be drained, otherwise the watcher blocks and no further changes are seen
+				logger.Log.Error("Error watching config file", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *Reloader) reload(reason string) {
+	logger.Log.Info("Reloading application config", "reason", reason)
+	if err := r.Reload(); err != nil {
+		logger.Log.Error("Rejected new application config, keeping the previous config", err)
+		return
+	}
+	logger.Log.Info("Application config reloaded, changes apply from the next polling cycle")
+	logger.Log.Debug("Application config", "config", r.Current())
+}
+
+// LoggingChangesNeedingRestart returns the keys of the logging settings that differ between those the logger was
+// built with and a reloaded configuration. The logger is built once at startup, only log.level can be applied to it
+// afterwards.
+func LoggingChangesNeedingRestart(running, reloaded Logging) []string {
+	var keys []string
+	if reloaded.FileLocation != running.FileLocation {
+		keys = append(keys, "log.file")
+	}
+	if reloaded.Format != running.Format {
+		keys = append(keys, "log.format")
+	}
+	if reloaded.Stdout != running.Stdout {
+		keys = append(keys, "log.stdout")
+	}
+	if reloaded.Rotation != running.Rotation {
+		keys = append(keys, "log.rotation")
+	}
+	return keys
+}
+
+func isConfigFileEvent(event fsnotify.Event, configFile string) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return false
+	}
+	return filepath.Clean(event.Name) == filepath.Clean(configFile)
+}
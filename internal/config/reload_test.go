@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, configPath string, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+}
+
+func newTestReloader(t *testing.T) (*Reloader, string) {
+	t.Helper()
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, configPath, "region: us-east-1\npolling-interval-seconds: 60\n")
+
+	cfg, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: configPath})
+	require.NoError(t, err)
+
+	return NewReloader(viper.GetViper(), cfg), configPath
+}
+
+func TestReloaderAppliesValidConfig(t *testing.T) {
+	t.Cleanup(cleanup)
+	reloader, configPath := newTestReloader(t)
+
+	writeConfig(t, configPath, "region: eu-west-2\npolling-interval-seconds: 120\n")
+
+	var reloaded *AppConfig
+	reloader.OnReload(func(cfg *AppConfig) {
+		reloaded = cfg
+	})
+
+	assert.NoError(t, reloader.Reload())
+	assert.Same(t, reloader.Current(), reloaded)
+	assert.Equal(t, "eu-west-2", reloader.Current().Region)
+	assert.Equal(t, 120, reloader.Current().PollingIntervalSeconds)
+	assert.Equal(t, configPath, reloader.Current().CliOptions.ConfigPath)
+}
+
+func TestReloaderKeepsPreviousConfigOnError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "unparsable yaml",
+			content: "region: [eu-west-2\n",
+		},
+		{
+			name:    "invalid value type",
+			content: "log: true\n",
+		},
+		{
+			name:    "zero polling interval",
+			content: "region: eu-west-2\npolling-interval-seconds: 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(cleanup)
+			reloader, configPath := newTestReloader(t)
+			previous := reloader.Current()
+
+			writeConfig(t, configPath, tt.content)
+
+			assert.Error(t, reloader.Reload())
+			assert.Same(t, previous, reloader.Current())
+		})
+	}
+}
+
+func TestReloaderWatchReloadsOnFileChange(t *testing.T) {
+	t.Cleanup(cleanup)
+	reloader, configPath := newTestReloader(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, reloader.Watch(ctx))
+
+	writeConfig(t, configPath, "region: ap-south-1\npolling-interval-seconds: 30\n")
+
+	assert.Eventually(t, func() bool {
+		return reloader.Current().Region == "ap-south-1"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLoggingChangesNeedingRestart(t *testing.T) {
+	running := Logging{Level: "info", FileLocation: "/var/log/inventory.log", Rotation: LogRotation{MaxSizeMB: 100}}
+
+	reloaded := running
+	reloaded.Level = "debug"
+	assert.Empty(t, LoggingChangesNeedingRestart(running, reloaded))
+
+	reloaded.Format = "json"
+	reloaded.Stdout = true
+	reloaded.Rotation.MaxBackups = 3
+	assert.Equal(t, []string{"log.format", "log.stdout", "log.rotation"}, LoggingChangesNeedingRestart(running, reloaded))
+
+	reloaded = running
+	reloaded.FileLocation = ""
+	assert.Equal(t, []string{"log.file"}, LoggingChangesNeedingRestart(running, reloaded))
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/anchore/ecs-inventory/internal"
+)
+
+// ValueSource is the effective value of a single config key along with where that value came from
+type ValueSource struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Sources returns the effective (redacted) value of every config key, in the order they are declared, annotated with
+// where each value came from. Sources are resolved following viper's precedence: flags, then environment variables,
+// then the config file, then defaults.
+func Sources(v *viper.Viper, cfg *AppConfig, flags *pflag.FlagSet) ([]ValueSource, error) {
+	file, _, err := parseConfigFile(v.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+
+	redactedCfg := cfg.redacted()
+	var sources []ValueSource
+	err = walkConfigValues(reflect.ValueOf(redactedCfg), "", func(key string, value reflect.Value) error {
+		rendered, err := renderValue(value)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, ValueSource{
+			Key:    key,
+			Value:  rendered,
+			Source: sourceOf(v, key, cfg, file, flags),
+		})
+		return nil
+	})
+	return sources, err
+}
+
+func sourceOf(v *viper.Viper, key string, cfg *AppConfig, file configFile, flags *pflag.FlagSet) string {
+	if key == "log.level" && v.GetString(key) == "" {
+		// the log level is derived from the verbosity flag when not set explicitly (see AppConfig.Build)
+		if cfg.CliOptions.Verbosity > 0 {
+			return "flag --verbose"
+		}
+		return "default"
+	}
+	if flags != nil {
+		if flag := flags.Lookup(key); flag != nil && flag.Changed {
+			return "flag --" + flag.Name
+		}
+	}
+	env := envVarName(internal.ApplicationName, key)
+	if value, ok := os.LookupEnv(env); ok && value != "" {
+		return "env " + env
+	}
+	if line, ok := file.lines[key]; ok {
+		return fmt.Sprintf("%s:%d", file.path, line)
+	}
+	return "default"
+}
+
+// walkConfigValues visits every leaf config value, identified by its config key
+func walkConfigValues(value reflect.Value, prefix string, visit func(key string, value reflect.Value) error) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		field := value.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walkConfigValues(field, key, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(key, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderValue formats a value as it would be written in a yaml config file, with lists and maps kept on one line
+func renderValue(value reflect.Value) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(value.Interface()); err != nil {
+		return "", err
+	}
+	if node.Kind == yaml.SequenceNode || node.Kind == yaml.MappingNode {
+		node.Style = yaml.FlowStyle
+	}
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Describe renders the effective config as yaml, with each value annotated with where it came from
+func Describe(v *viper.Viper, cfg *AppConfig, flags *pflag.FlagSet) (string, error) {
+	sources, err := Sources(v, cfg, flags)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(sources))
+	width := 0
+	var previousParents []string
+	for _, source := range sources {
+		parts := strings.Split(source.Key, ".")
+		parents := parts[:len(parts)-1]
+		// open any parent mappings that differ from the previous key
+		for depth, parent := range parents {
+			if depth < len(previousParents) && previousParents[depth] == parent {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s%s:", strings.Repeat("  ", depth), parent))
+			previousParents = append(previousParents[:depth], parent)
+		}
+		previousParents = previousParents[:len(parents)]
+
+		line := fmt.Sprintf("%s%s: %s", strings.Repeat("  ", len(parents)), parts[len(parts)-1], source.Value)
+		width = max(width, len(line))
+		lines = append(lines, line+"\x00"+source.Source)
+	}
+
+	var sb strings.Builder
+	if file := v.ConfigFileUsed(); file != "" {
+		fmt.Fprintf(&sb, "# config file: %s\n", file)
+	} else {
+		sb.WriteString("# config file: none\n")
+	}
+	for _, line := range lines {
+		value, source, annotated := strings.Cut(line, "\x00")
+		if !annotated {
+			sb.WriteString(value + "\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "%-*s  # %s\n", width, value, source)
+	}
+	return sb.String(), nil
+}
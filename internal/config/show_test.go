@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sourcesByKey(sources []ValueSource) map[string]ValueSource {
+	byKey := map[string]ValueSource{}
+	for _, source := range sources {
+		byKey[source.Key] = source
+	}
+	return byKey
+}
+
+func TestSources(t *testing.T) {
+	t.Cleanup(cleanup)
+	t.Setenv("ANCHORE_ECS_INVENTORY_ANCHORE_USER", "env-user")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("region", "", "")
+	flags.Bool("dry-run", false, "")
+	require.NoError(t, viper.BindPFlag("region", flags.Lookup("region")))
+	require.NoError(t, viper.BindPFlag("dry-run", flags.Lookup("dry-run")))
+	require.NoError(t, flags.Parse([]string{"--region", "eu-west-2"}))
+
+	cfg, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: "testdata/config.yaml"})
+	require.NoError(t, err)
+
+	sources, err := Sources(viper.GetViper(), cfg, flags)
+	require.NoError(t, err)
+	byKey := sourcesByKey(sources)
+
+	assert.Equal(t, ValueSource{Key: "region", Value: "eu-west-2", Source: "flag --region"}, byKey["region"])
+	assert.Equal(t, ValueSource{Key: "anchore.user", Value: "env-user", Source: "env ANCHORE_ECS_INVENTORY_ANCHORE_USER"}, byKey["anchore.user"])
+	assert.Equal(t, ValueSource{Key: "anchore.http.timeout-seconds", Value: "10", Source: "testdata/config.yaml:12"}, byKey["anchore.http.timeout-seconds"])
+	assert.Equal(t, ValueSource{Key: "dry-run", Value: "false", Source: "default"}, byKey["dry-run"])
+	assert.Equal(t, "'******'", byKey["anchore.password"].Value, "secrets must be redacted")
+}
+
+func TestSourcesLogLevelDerivedFromVerbosity(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	cfg, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: "testdata/empty_config.yaml", Verbosity: 2})
+	require.NoError(t, err)
+
+	sources, err := Sources(viper.GetViper(), cfg, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, ValueSource{Key: "log.level", Value: "debug", Source: "flag --verbose"}, sourcesByKey(sources)["log.level"])
+}
+
+func TestDescribe(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	cfg, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: "testdata/config.yaml"})
+	require.NoError(t, err)
+
+	description, err := Describe(viper.GetViper(), cfg, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, description, "# config file: testdata/config.yaml\n")
+	assert.Contains(t, description, "anchore:\n  url: http://localhost:8228")
+	assert.Contains(t, description, "  http:\n    insecure: false")
+	assert.Regexp(t, `    timeout-seconds: 10 +# testdata/config.yaml:12\n`, description)
+	assert.Regexp(t, `dry-run: false +# default\n`, description)
+	assert.NotContains(t, description, "foobar")
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/adrg/xdg"
+
+	"github.com/anchore/ecs-inventory/internal"
+)
+
+// defaultConfigTemplate is rendered with DefaultConfigValues to produce a fully commented config file. Every config key
+// must be present here, which is enforced by tests.
+const defaultConfigTemplate = `# anchore-ecs-inventory configuration
+#
+# Any value can be overridden with an environment variable prefixed with ANCHORE_ECS_INVENTORY_, for example
+# ANCHORE_ECS_INVENTORY_LOG_LEVEL=debug overrides log.level
+
+# only log.level is applied when the config is reloaded, changing the other log settings needs a restart
+log:
+  # level of logging that anchore-ecs-inventory will do { 'error' | 'warn' | 'info' | 'debug' }
+  # (default is "info", or as set with the -v flag)
+  level: {{ printf "%q" .Log.Level }}
+
+  # location to write the log file (default is not to have a log file)
+  file: {{ printf "%q" .Log.FileLocation }}
+
+  # format of log messages { 'console' | 'json' } (default is "json" when writing to a log file and "console" otherwise)
+  format: {{ printf "%q" .Log.Format }}
+
+  # log to stdout as well as the log file
+  stdout: {{ .Log.Stdout }}
+
+  rotation:
+    # size in megabytes at which the log file is rotated
+    max-size-mb: {{ .Log.Rotation.MaxSizeMB }}
+
+    # days to keep rotated log files (default is to keep them regardless of age)
+    max-age-days: {{ .Log.Rotation.MaxAgeDays }}
+
+    # number of rotated log files to keep, 0 keeps them all
+    max-backups: {{ .Log.Rotation.MaxBackups }}
+
+    # gzip rotated log files
+    compress: {{ .Log.Rotation.Compress }}
+
+anchore:
+  # anchore enterprise api url (e.g. http://localhost:8228)
+  url: {{ printf "%q" .AnchoreDetails.URL }}
+
+  # anchore enterprise username
+  user: {{ printf "%q" .AnchoreDetails.User }}
+
+  # anchore enterprise password, prefer setting this with ANCHORE_ECS_INVENTORY_ANCHORE_PASSWORD
+  password: {{ printf "%q" .AnchoreDetails.Password }}
+
+  # anchore enterprise account that the inventory will be sent
+  account: {{ printf "%q" .AnchoreDetails.Account }}
+
+  http:
+    # skip TLS certificate verification when connecting to anchore
+    insecure: {{ .AnchoreDetails.HTTP.Insecure }}
+
+    # timeout for requests to anchore
+    timeout-seconds: {{ .AnchoreDetails.HTTP.TimeoutSeconds }}
+
+# the aws region to inventory (e.g. us-east-1)
+region: {{ printf "%q" .Region }}
+
+# frequency of which to poll the region
+polling-interval-seconds: {{ .PollingIntervalSeconds }}
+
+# if true do not print the inventory report to stdout
+quiet: {{ .Quiet }}
+
+# if true do not report the inventory to anchore
+dry-run: {{ .DryRun }}
+
+collect:
+  # describe the task definition of each task and report its security posture (privileged containers, the user
+  # containers run as, capabilities, host namespaces and mounts, and whether ECS Exec is enabled)
+  task-definitions: {{ .Collect.TaskDefinitions }}
+
+  # scan the environment variables of each task definition for likely secrets (by their name, their entropy and the
+  # formats of well known credentials), only the names of the variables are reported, never their values
+  environment-secrets: {{ .Collect.EnvironmentSecrets }}
+
+  # report the images of the latest revision of every ACTIVE task definition family, and of the tasks run by
+  # EventBridge rules, as deployable (separately from the images in use), so images that only run briefly still get
+  # scanned
+  deployable-images: {{ .Collect.DeployableImages }}
+
+  # also report the tasks that stopped since the previous polling cycle (ECS keeps stopped tasks for about an hour), so
+  # tasks that start and stop between two cycles, such as batch jobs, are not missed
+  stopped-tasks: {{ .Collect.StoppedTasks }}
+
+events:
+  # consume "ECS Task State Change" and "ECS Deployment State Change" events from an SQS queue, reporting the clusters
+  # they change, instead of polling the region every polling interval (changing this needs a restart)
+  enabled: {{ .Events.Enabled }}
+
+  # URL of the SQS queue that an EventBridge rule delivers the ECS events to
+  queue-url: {{ printf "%q" .Events.QueueURL }}
+
+  # URL of the SQS API, to use a local SQS compatible service (default is the regional SQS endpoint)
+  endpoint: {{ printf "%q" .Events.Endpoint }}
+
+  # seconds to gather events for before reporting the clusters they changed
+  flush-interval-seconds: {{ .Events.FlushIntervalSeconds }}
+
+  # seconds between full polls of the region, which reconcile the inventory with ECS in case events were missed
+  reconcile-interval-seconds: {{ .Events.ReconcileIntervalSeconds }}
+
+daemon:
+  # report only the tasks on this container instance, read from the local ECS agent, instead of polling the ECS API for
+  # the whole region (run as an ECS DAEMON service on each EC2 container instance)
+  enabled: {{ .Daemon.Enabled }}
+
+  # URL of the ECS agent introspection API on the container instance
+  introspection-url: {{ printf "%q" .Daemon.IntrospectionURL }}
+
+  # path of the Docker daemon socket, mounted from the container instance, to find the digests of images referenced by
+  # tag (set to "" to report them without digests)
+  docker-socket: {{ printf "%q" .Daemon.DockerSocket }}
+
+metrics:
+  # serve prometheus metrics on /metrics
+  enabled: {{ .Metrics.Enabled }}
+
+  # address (host:port) for the metrics server to listen on
+  address: {{ printf "%q" .Metrics.Address }}
+
+health:
+  # serve /healthz (the polling loop is making progress), /readyz (the last polling cycle succeeded recently, and AWS
+  # and Anchore are reachable) and /status (a JSON summary of the last polling cycle for each cluster)
+  enabled: {{ .Health.Enabled }}
+
+  # address (host:port) for the health server to listen on, this may be the same as metrics.address
+  address: {{ printf "%q" .Health.Address }}
+
+  # number of polling intervals without progress (or a successful polling cycle) before the agent is reported as
+  # unhealthy (or not ready)
+  max-missed-intervals: {{ .Health.MaxMissedIntervals }}
+
+tracing:
+  # export OpenTelemetry traces of each polling cycle, covering every ECS API call and report to Anchore
+  enabled: {{ .Tracing.Enabled }}
+
+  # OTLP protocol to export traces with { 'grpc' | 'http/protobuf' }
+  protocol: {{ printf "%q" .Tracing.Protocol }}
+
+  # address (host:port) of the OTLP collector (default is to use the OTEL_EXPORTER_OTLP_ENDPOINT environment variable,
+  # or localhost)
+  endpoint: {{ printf "%q" .Tracing.Endpoint }}
+
+  # export traces without TLS
+  insecure: {{ .Tracing.Insecure }}
+
+  # fraction of polling cycles to trace, between 0 and 1
+  sample-ratio: {{ .Tracing.SampleRatio }}
+
+redact:
+  # ECS tag keys whose values are redacted from logs and failed payload dumps. Patterns are matched case-insensitively
+  # and support * and ? wildcards. Sensitive config values (e.g. anchore.password) are always redacted.
+  tag-keys: {{ list .Redact.TagKeys }}
+`
+
+var ErrConfigFileExists = fmt.Errorf("config file already exists")
+
+// DefaultConfigYAML renders a fully commented config file containing the default values
+func DefaultConfigYAML() ([]byte, error) {
+	tmpl, err := template.New("config").Funcs(template.FuncMap{"list": yamlList}).Parse(defaultConfigTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse default config template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, DefaultConfigValues); err != nil {
+		return nil, fmt.Errorf("unable to render default config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlList renders a list of strings as an inline yaml list
+func yamlList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// DefaultConfigPath is where "config init" writes the config, the XDG config home location searched by readConfig
+func DefaultConfigPath() string {
+	return filepath.Join(xdg.ConfigHome, internal.ApplicationName, "config.yaml")
+}
+
+// WriteDefaultConfig writes the default config to the given path, refusing to replace an existing file unless force
+// is set
+func WriteDefaultConfig(path string, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%w: %s", ErrConfigFileExists, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("unable to check for existing config: %w", err)
+		}
+	}
+
+	content, err := DefaultConfigYAML()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("unable to create config directory: %w", err)
+	}
+	// the config may go on to hold anchore credentials, so keep it private to the user
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("unable to write config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultConfigYAMLLoadsDefaults(t *testing.T) {
+	t.Cleanup(cleanup)
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, WriteDefaultConfig(configPath, false))
+
+	appCfg, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: configPath})
+	require.NoError(t, err)
+
+	expectedCfg := DefaultConfigValues
+	expectedCfg.CliOptions = CliOnlyOptions{ConfigPath: configPath}
+	// the log level is left empty so it can be set with -v, and otherwise defaults to info
+	expectedCfg.Log.Level = "info"
+
+	assert.Equal(t, &expectedCfg, appCfg)
+}
+
+func TestDefaultConfigYAMLContainsEveryKey(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, WriteDefaultConfig(configPath, false))
+
+	file, errs, err := parseConfigFile(configPath)
+	require.NoError(t, err)
+	require.Empty(t, errs)
+
+	for key := range configKeys() {
+		assert.Contains(t, file.lines, key, "default config is missing key %q", key)
+	}
+}
+
+func TestWriteDefaultConfigDoesNotOverwrite(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("region: us-east-1\n"), 0o600))
+
+	err := WriteDefaultConfig(configPath, false)
+	assert.ErrorIs(t, err, ErrConfigFileExists)
+
+	content, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "region: us-east-1\n", string(content))
+
+	require.NoError(t, WriteDefaultConfig(configPath, true))
+	content, err = os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "polling-interval-seconds: 300")
+}
@@ -0,0 +1,442 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/anchore/ecs-inventory/internal"
+	"github.com/anchore/ecs-inventory/internal/redact"
+)
+
+var awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// ValidationError describes a single problem with the application config, and where it was found
+type ValidationError struct {
+	File    string
+	Line    int
+	Key     string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	msg := e.Message
+	if e.Key != "" {
+		msg = fmt.Sprintf("%s: %s", e.Key, e.Message)
+	}
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	default:
+		return msg
+	}
+}
+
+// ValidationErrors is the full set of problems found while validating the application config
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// sort orders errors by their location in the config file, errors without a location are listed last
+func (e ValidationErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if (e[i].Line == 0) != (e[j].Line == 0) {
+			return e[j].Line == 0
+		}
+		return e[i].Line < e[j].Line
+	})
+}
+
+// configFile is a parsed config file along with the line each key is defined on
+type configFile struct {
+	path  string
+	lines map[string]int
+}
+
+func (f configFile) errorAt(key, format string, args ...interface{}) ValidationError {
+	return ValidationError{
+		File:    f.path,
+		Line:    f.lines[key],
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// errorFor creates an error for a key, only referencing the config file if the key was set there
+func (f configFile) errorFor(key, format string, args ...interface{}) ValidationError {
+	if _, ok := f.lines[key]; !ok {
+		return ValidationError{Key: key, Message: fmt.Sprintf(format, args...)}
+	}
+	return f.errorAt(key, format, args...)
+}
+
+// configKeys returns every config key (e.g. "anchore.http.timeout-seconds") along with its type, derived from the
+// mapstructure tags on AppConfig
+func configKeys() map[string]reflect.Type {
+	keys := map[string]reflect.Type{}
+	collectConfigKeys(reflect.TypeOf(AppConfig{}), "", keys)
+	return keys
+}
+
+func collectConfigKeys(t reflect.Type, prefix string, keys map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		keys[key] = field.Type
+		if field.Type.Kind() == reflect.Struct {
+			collectConfigKeys(field.Type, key, keys)
+		}
+	}
+}
+
+// parseConfigFile reads a yaml (or json) config file, reporting any keys that are not known to the application or
+// hold a value of the wrong type. Config files in other formats are not inspected.
+func parseConfigFile(path string) (configFile, ValidationErrors, error) {
+	file := configFile{path: path, lines: map[string]int{}}
+	if path == "" {
+		return file, nil, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return file, nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return file, nil, fmt.Errorf("unable to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return file, nil, fmt.Errorf("unable to parse config %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		// an empty file is valid, all defaults are used
+		return file, nil, nil
+	}
+
+	var errs ValidationErrors
+	file.walk(doc.Content[0], "", configKeys(), &errs)
+	return file, errs, nil
+}
+
+func (f configFile) walk(node *yaml.Node, prefix string, keys map[string]reflect.Type, errs *ValidationErrors) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		key := strings.ToLower(keyNode.Value)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		f.lines[key] = keyNode.Line
+
+		t, ok := keys[key]
+		if !ok {
+			err := f.errorAt(key, "unknown key")
+			if suggestion := closestKey(key, keys); suggestion != "" {
+				err.Message = fmt.Sprintf("unknown key, did you mean %q?", suggestion)
+			}
+			*errs = append(*errs, err)
+			continue
+		}
+
+		if msg := checkNodeType(valueNode, t); msg != "" {
+			*errs = append(*errs, f.errorAt(key, "%s", msg))
+			continue
+		}
+		if t.Kind() == reflect.Struct {
+			f.walk(valueNode, key, keys, errs)
+		}
+	}
+}
+
+// checkNodeType returns a description of the problem if the yaml value cannot be decoded into the given type. The
+// config is decoded with weak typing, so quoted scalars (e.g. "60") are accepted as long as they can be converted.
+func checkNodeType(node *yaml.Node, t reflect.Type) string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return fmt.Sprintf("expected a mapping but got %s", describeNode(node))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode && node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("expected a list but got %s", describeNode(node))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("expected an integer but got %s", describeNode(node))
+		}
+		if _, err := strconv.Atoi(node.Value); err != nil {
+			return fmt.Sprintf("expected an integer but got %q", node.Value)
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("expected a number but got %s", describeNode(node))
+		}
+		if _, err := strconv.ParseFloat(node.Value, 64); err != nil {
+			return fmt.Sprintf("expected a number but got %q", node.Value)
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("expected a boolean but got %s", describeNode(node))
+		}
+		if _, err := strconv.ParseBool(node.Value); err != nil {
+			return fmt.Sprintf("expected a boolean but got %q", node.Value)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Sprintf("expected a string but got %s", describeNode(node))
+		}
+	}
+	return ""
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// closestKey suggests a known key for a misspelled one, only considering keys at the same level of nesting
+func closestKey(key string, keys map[string]reflect.Type) string {
+	parent, name := splitKey(key)
+	candidates := []string{}
+	for k := range keys {
+		if p, _ := splitKey(k); p == parent {
+			candidates = append(candidates, k)
+		}
+	}
+	sort.Strings(candidates)
+
+	// a truncated key (e.g. "timeout" rather than "timeout-seconds") is the most likely mistake
+	for _, candidate := range candidates {
+		if _, candidateName := splitKey(candidate); strings.HasPrefix(candidateName, name) {
+			return candidate
+		}
+	}
+
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		_, candidateName := splitKey(candidate)
+		if d := levenshtein(name, candidateName); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func splitKey(key string) (string, string) {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// UnknownEnvVars reports environment variables that use the application prefix but do not map to a config key. They
+// are likely a typo, but could also be set for another version of the application, so they are only warned about rather
+// than preventing the application from starting.
+func UnknownEnvVars() ValidationErrors {
+	return unknownEnvVars(os.Environ())
+}
+
+func unknownEnvVars(environ []string) ValidationErrors {
+	prefix := envVarName(internal.ApplicationName, "") + "_"
+	known := map[string]bool{}
+	for key := range configKeys() {
+		known[envVarName(internal.ApplicationName, key)] = true
+	}
+
+	var errs ValidationErrors
+	for _, env := range environ {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, prefix) && !known[name] {
+			errs = append(errs, ValidationError{Key: name, Message: "unknown environment variable"})
+		}
+	}
+	return errs
+}
+
+// envVarName returns the environment variable that sets a config key, following the env key replacer in readConfig
+func envVarName(applicationName, key string) string {
+	name := applicationName
+	if key != "" {
+		name += "_" + key
+	}
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// validate checks the final (merged) config values are within range
+func (cfg *AppConfig) validate(file configFile) ValidationErrors {
+	var errs ValidationErrors
+
+	if cfg.Log.Level != "" {
+		if _, err := zapcore.ParseLevel(cfg.Log.Level); err != nil {
+			errs = append(errs, file.errorFor("log.level", "invalid log level %q, expected one of debug, info, warn, error", cfg.Log.Level))
+		}
+	}
+	if cfg.Log.Format != "" && cfg.Log.Format != "console" && cfg.Log.Format != "json" {
+		errs = append(errs, file.errorFor("log.format", "invalid log format %q, expected one of console, json", cfg.Log.Format))
+	}
+	if cfg.Log.Rotation.MaxSizeMB <= 0 {
+		errs = append(errs, file.errorFor("log.rotation.max-size-mb", "must be greater than 0, got %d", cfg.Log.Rotation.MaxSizeMB))
+	}
+	if cfg.Log.Rotation.MaxAgeDays < 0 {
+		errs = append(errs, file.errorFor("log.rotation.max-age-days", "must not be negative, got %d", cfg.Log.Rotation.MaxAgeDays))
+	}
+	if cfg.Log.Rotation.MaxBackups < 0 {
+		errs = append(errs, file.errorFor("log.rotation.max-backups", "must not be negative, got %d", cfg.Log.Rotation.MaxBackups))
+	}
+	if cfg.PollingIntervalSeconds <= 0 {
+		errs = append(errs, file.errorFor("polling-interval-seconds", "must be greater than 0, got %d", cfg.PollingIntervalSeconds))
+	}
+	if cfg.Region != "" && !awsRegionPattern.MatchString(cfg.Region) {
+		errs = append(errs, file.errorFor("region", "invalid AWS region %q, expected a region such as us-east-1", cfg.Region))
+	}
+	if cfg.AnchoreDetails.URL != "" {
+		if msg := checkURL(cfg.AnchoreDetails.URL); msg != "" {
+			errs = append(errs, file.errorFor("anchore.url", "%s", msg))
+		}
+	}
+	if cfg.AnchoreDetails.HTTP.TimeoutSeconds < 0 {
+		errs = append(errs, file.errorFor("anchore.http.timeout-seconds", "must not be negative, got %d", cfg.AnchoreDetails.HTTP.TimeoutSeconds))
+	}
+
+	if cfg.Metrics.Enabled {
+		if _, _, err := net.SplitHostPort(cfg.Metrics.Address); err != nil {
+			errs = append(errs, file.errorFor("metrics.address", "invalid listen address %q, expected host:port (e.g. :8080)", cfg.Metrics.Address))
+		}
+	}
+
+	if cfg.Health.Enabled {
+		if _, _, err := net.SplitHostPort(cfg.Health.Address); err != nil {
+			errs = append(errs, file.errorFor("health.address", "invalid listen address %q, expected host:port (e.g. :8080)", cfg.Health.Address))
+		}
+	}
+	if cfg.Health.MaxMissedIntervals <= 0 {
+		errs = append(errs, file.errorFor("health.max-missed-intervals", "must be greater than 0, got %d", cfg.Health.MaxMissedIntervals))
+	}
+
+	if cfg.Tracing.Enabled {
+		if cfg.Tracing.Protocol != "grpc" && cfg.Tracing.Protocol != "http/protobuf" {
+			errs = append(errs, file.errorFor("tracing.protocol", "invalid protocol %q, expected one of grpc, http/protobuf", cfg.Tracing.Protocol))
+		}
+		if cfg.Tracing.Endpoint != "" {
+			if _, _, err := net.SplitHostPort(cfg.Tracing.Endpoint); err != nil {
+				errs = append(errs, file.errorFor("tracing.endpoint", "invalid endpoint %q, expected host:port (e.g. localhost:4317)", cfg.Tracing.Endpoint))
+			}
+		}
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, file.errorFor("tracing.sample-ratio", "must be between 0 and 1, got %g", cfg.Tracing.SampleRatio))
+	}
+
+	if cfg.Events.Enabled && cfg.Events.QueueURL == "" {
+		errs = append(errs, file.errorFor("events.queue-url", "must be set when events are enabled"))
+	}
+	if cfg.Events.QueueURL != "" {
+		if msg := checkURL(cfg.Events.QueueURL); msg != "" {
+			errs = append(errs, file.errorFor("events.queue-url", "%s", msg))
+		}
+	}
+	if cfg.Events.Endpoint != "" {
+		if msg := checkURL(cfg.Events.Endpoint); msg != "" {
+			errs = append(errs, file.errorFor("events.endpoint", "%s", msg))
+		}
+	}
+	if cfg.Events.FlushIntervalSeconds <= 0 {
+		errs = append(errs, file.errorFor("events.flush-interval-seconds", "must be greater than 0, got %d", cfg.Events.FlushIntervalSeconds))
+	}
+	if cfg.Events.ReconcileIntervalSeconds <= 0 {
+		errs = append(errs, file.errorFor("events.reconcile-interval-seconds", "must be greater than 0, got %d", cfg.Events.ReconcileIntervalSeconds))
+	}
+
+	if cfg.Daemon.Enabled && cfg.Events.Enabled {
+		errs = append(errs, file.errorFor("daemon.enabled", "can't be set along with events.enabled"))
+	}
+	if msg := checkURL(cfg.Daemon.IntrospectionURL); msg != "" {
+		errs = append(errs, file.errorFor("daemon.introspection-url", "%s", msg))
+	}
+
+	for _, pattern := range cfg.Redact.TagKeys {
+		if !redact.ValidTagKeyPattern(pattern) {
+			errs = append(errs, file.errorFor("redact.tag-keys", "invalid tag key pattern %q", pattern))
+		}
+	}
+
+	return errs
+}
+
+func checkURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Sprintf("invalid URL %q: %v", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Sprintf("invalid URL %q, expected an http:// or https:// URL", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Sprintf("invalid URL %q, missing host", rawURL)
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadValidationErrors(t *testing.T, configPath string) ValidationErrors {
+	t.Helper()
+	_, err := LoadConfigFromFile(viper.GetViper(), &CliOnlyOptions{ConfigPath: configPath})
+	require.Error(t, err)
+
+	var errs ValidationErrors
+	require.True(t, errors.As(err, &errs), "expected validation errors, got: %v", err)
+	return errs
+}
+
+func errorStrings(errs ValidationErrors) []string {
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+func TestValidateReportsUnknownKeys(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	errs := loadValidationErrors(t, "testdata/unknown-keys-config.yaml")
+
+	assert.ElementsMatch(t, []string{
+		`testdata/unknown-keys-config.yaml:7: anchore.http.timeout: unknown key, did you mean "anchore.http.timeout-seconds"?`,
+		`testdata/unknown-keys-config.yaml:9: polling-interval-second: unknown key, did you mean "polling-interval-seconds"?`,
+	}, errorStrings(errs))
+}
+
+func TestValidateReportsWrongTypes(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	errs := loadValidationErrors(t, "testdata/wrong-types-config.yaml")
+
+	assert.ElementsMatch(t, []string{
+		`testdata/wrong-types-config.yaml:4: anchore.http.insecure: expected a boolean but got "maybe"`,
+		`testdata/wrong-types-config.yaml:5: anchore.http.timeout-seconds: expected an integer but got "ten"`,
+		`testdata/wrong-types-config.yaml:7: polling-interval-seconds: expected an integer but got a list`,
+		`testdata/wrong-types-config.yaml:12: tracing.sample-ratio: expected a number but got "half"`,
+	}, errorStrings(errs))
+}
+
+func TestValidateReportsOutOfRangeValues(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	errs := loadValidationErrors(t, "testdata/out-of-range-config.yaml")
+
+	assert.ElementsMatch(t, []string{
+		`testdata/out-of-range-config.yaml:2: log.level: invalid log level "verbose", expected one of debug, info, warn, error`,
+		`testdata/out-of-range-config.yaml:3: log.format: invalid log format "xml", expected one of console, json`,
+		`testdata/out-of-range-config.yaml:5: log.rotation.max-size-mb: must be greater than 0, got 0`,
+		`testdata/out-of-range-config.yaml:6: log.rotation.max-backups: must not be negative, got -1`,
+		`testdata/out-of-range-config.yaml:9: anchore.url: invalid URL "localhost:8228", expected an http:// or https:// URL`,
+		`testdata/out-of-range-config.yaml:11: anchore.http.timeout-seconds: must not be negative, got -1`,
+		`testdata/out-of-range-config.yaml:13: region: invalid AWS region "us-east", expected a region such as us-east-1`,
+		`testdata/out-of-range-config.yaml:15: polling-interval-seconds: must be greater than 0, got 0`,
+		`testdata/out-of-range-config.yaml:19: metrics.address: invalid listen address "8080", expected host:port (e.g. :8080)`,
+		`testdata/out-of-range-config.yaml:22: health.max-missed-intervals: must be greater than 0, got 0`,
+		`testdata/out-of-range-config.yaml:26: tracing.protocol: invalid protocol "http", expected one of grpc, http/protobuf`,
+		`testdata/out-of-range-config.yaml:27: tracing.endpoint: invalid endpoint "http://localhost:4318", expected host:port (e.g. localhost:4317)`,
+		`testdata/out-of-range-config.yaml:28: tracing.sample-ratio: must be between 0 and 1, got 2`,
+		`events.queue-url: must be set when events are enabled`,
+		`testdata/out-of-range-config.yaml:32: events.flush-interval-seconds: must be greater than 0, got 0`,
+		`testdata/out-of-range-config.yaml:33: events.reconcile-interval-seconds: must be greater than 0, got -1`,
+		`testdata/out-of-range-config.yaml:36: daemon.enabled: can't be set along with events.enabled`,
+		`testdata/out-of-range-config.yaml:37: daemon.introspection-url: invalid URL "localhost:51678", expected an http:// or https:// URL`,
+	}, errorStrings(errs))
+}
+
+func TestValidateValuesNotFromFileHaveNoLocation(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	viper.Set("region", "not-a-region")
+	errs := loadValidationErrors(t, "testdata/empty_config.yaml")
+
+	assert.Equal(t, []string{
+		`region: invalid AWS region "not-a-region", expected a region such as us-east-1`,
+	}, errorStrings(errs))
+}
+
+func TestValidateAcceptsValidRegions(t *testing.T) {
+	for _, region := range []string{"us-east-1", "eu-west-2", "ap-southeast-4", "us-gov-west-1", "cn-north-1", "us-isob-east-1"} {
+		t.Run(region, func(t *testing.T) {
+			assert.Regexp(t, awsRegionPattern, region)
+		})
+	}
+}
+
+func Test_unknownEnvVars(t *testing.T) {
+	errs := unknownEnvVars([]string{
+		"ANCHORE_ECS_INVENTORY_REGION=us-east-1",
+		"ANCHORE_ECS_INVENTORY_ANCHORE_HTTP_TIMEOUT_SECONDS=10",
+		"ANCHORE_ECS_INVENTORY_POLLING_INTERVAL_SECOND=10",
+		"HOME=/root",
+	})
+
+	assert.Equal(t, []string{
+		"ANCHORE_ECS_INVENTORY_POLLING_INTERVAL_SECOND: unknown environment variable",
+	}, errorStrings(errs))
+}
+
+func Test_closestKey(t *testing.T) {
+	keys := configKeys()
+
+	assert.Equal(t, "polling-interval-seconds", closestKey("polling-interval-second", keys))
+	assert.Equal(t, "anchore.http.timeout-seconds", closestKey("anchore.http.timeout", keys))
+	assert.Equal(t, "anchore.password", closestKey("anchore.pasword", keys))
+	assert.Equal(t, "", closestKey("something-completely-different", keys))
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Register adds the /healthz, /readyz and /status endpoints for the tracker to the mux
+func Register(mux *http.ServeMux, t *Tracker, maxMissedIntervals int) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		status := t.Status(maxMissedIntervals)
+		if !status.Healthy {
+			writeProblem(w, status.Reasons)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		status := t.Status(maxMissedIntervals)
+		if !status.Ready {
+			writeProblem(w, status.Reasons)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(t.Status(maxMissedIntervals))
+	})
+}
+
+func writeProblem(w http.ResponseWriter, reasons []string) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(w, strings.Join(reasons, "\n"))
+}
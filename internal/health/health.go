@@ -0,0 +1,189 @@
+// Tracks the outcome of each polling cycle, to report whether the agent is healthy (making progress) and ready (able to
+// build and report the inventory)
+package health
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClusterStatus is the outcome of inventorying a single cluster
+type ClusterStatus struct {
+	ClusterARN string    `json:"clusterArn"`
+	Timestamp  time.Time `json:"timestamp"`
+	Tasks      int       `json:"tasks"`
+	Containers int       `json:"containers"`
+	// Error is set if the inventory for the cluster could not be built
+	Error string `json:"error,omitempty"`
+	// ReportError is set if the inventory could not be reported to Anchore
+	ReportError string `json:"reportError,omitempty"`
+}
+
+// CycleStatus is the outcome of a polling cycle, End is zero while the cycle is running
+type CycleStatus struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end,omitzero"`
+	Error string    `json:"error,omitempty"`
+}
+
+// AnchoreStatus is the outcome of the last attempt to report an inventory to Anchore
+type AnchoreStatus struct {
+	LastReport time.Time `json:"lastReport,omitzero"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Status is a summary of the last polling cycle, along with the health and readiness of the agent
+type Status struct {
+	Healthy                bool          `json:"healthy"`
+	Ready                  bool          `json:"ready"`
+	Reasons                []string      `json:"reasons,omitempty"`
+	Region                 string        `json:"region"`
+	PollingIntervalSeconds int           `json:"pollingIntervalSeconds"`
+	LastCycle              *CycleStatus  `json:"lastCycle,omitempty"`
+	LastSuccessfulCycle    *time.Time    `json:"lastSuccessfulCycle,omitempty"`
+	Anchore                AnchoreStatus `json:"anchore"`
+	// Clusters are those inventoried in the last completed polling cycle
+	Clusters []ClusterStatus `json:"clusters"`
+}
+
+// Tracker records the outcome of each polling cycle, it is safe for concurrent use
+type Tracker struct {
+	mu          sync.RWMutex
+	now         func() time.Time
+	region      string
+	interval    time.Duration
+	cycle       *CycleStatus
+	lastSuccess time.Time
+	anchore     AnchoreStatus
+	pending     map[string]ClusterStatus
+	clusters    []ClusterStatus
+}
+
+// Default is the tracker the polling loop reports to
+var Default = NewTracker()
+
+func NewTracker() *Tracker {
+	return &Tracker{now: time.Now, pending: map[string]ClusterStatus{}}
+}
+
+// CycleStarted records the start of a polling cycle
+func (t *Tracker) CycleStarted(region string, interval time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.region = region
+	t.interval = interval
+	t.cycle = &CycleStatus{Start: t.now()}
+	t.pending = map[string]ClusterStatus{}
+}
+
+// ClusterFinished records the outcome of inventorying a cluster in the current polling cycle
+func (t *Tracker) ClusterFinished(status ClusterStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if status.Timestamp.IsZero() {
+		status.Timestamp = t.now()
+	}
+	t.pending[status.ClusterARN] = status
+}
+
+// AnchoreReported records the outcome of reporting an inventory to Anchore
+func (t *Tracker) AnchoreReported(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.anchore = AnchoreStatus{LastReport: t.now()}
+	if err != nil {
+		t.anchore.Error = err.Error()
+	}
+}
+
+// CycleFinished records the end of the current polling cycle, the clusters recorded during the cycle replace those
+// from the previous cycle
+func (t *Tracker) CycleFinished(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cycle == nil {
+		t.cycle = &CycleStatus{Start: t.now()}
+	}
+	t.cycle.End = t.now()
+	if err != nil {
+		t.cycle.Error = err.Error()
+	} else {
+		t.lastSuccess = t.cycle.End
+	}
+
+	t.clusters = make([]ClusterStatus, 0, len(t.pending))
+	for _, status := range t.pending {
+		t.clusters = append(t.clusters, status)
+	}
+	sort.Slice(t.clusters, func(i, j int) bool {
+		return t.clusters[i].ClusterARN < t.clusters[j].ClusterARN
+	})
+}
+
+// Status summarizes the last polling cycle. The agent is unhealthy if no polling cycle has started (or the current one
+// has not finished) within maxMissedIntervals polling intervals. It is ready when the last successful cycle finished
+// within maxMissedIntervals polling intervals, and the last calls to AWS and Anchore succeeded.
+func (t *Tracker) Status(maxMissedIntervals int) Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	status := Status{
+		Healthy:                true,
+		Ready:                  true,
+		Region:                 t.region,
+		PollingIntervalSeconds: int(t.interval.Seconds()),
+		Anchore:                t.anchore,
+		Clusters:               append([]ClusterStatus{}, t.clusters...),
+	}
+	if t.cycle != nil {
+		cycle := *t.cycle
+		status.LastCycle = &cycle
+	}
+	if !t.lastSuccess.IsZero() {
+		lastSuccess := t.lastSuccess
+		status.LastSuccessfulCycle = &lastSuccess
+	}
+
+	if reason := t.unhealthyReason(maxMissedIntervals); reason != "" {
+		status.Healthy = false
+		status.Reasons = append(status.Reasons, reason)
+	}
+	status.Reasons = append(status.Reasons, t.unreadyReasons(maxMissedIntervals)...)
+	status.Ready = status.Healthy && len(status.Reasons) == 0
+	return status
+}
+
+func (t *Tracker) unhealthyReason(maxMissedIntervals int) string {
+	if t.cycle == nil {
+		// still starting up
+		return ""
+	}
+	deadline := time.Duration(maxMissedIntervals) * t.interval
+	now := t.now()
+	switch {
+	case t.cycle.End.IsZero() && now.Sub(t.cycle.Start) > deadline:
+		return fmt.Sprintf("polling cycle started at %s has not finished", t.cycle.Start.Format(time.RFC3339))
+	case !t.cycle.End.IsZero() && now.Sub(t.cycle.End) > deadline:
+		return fmt.Sprintf("no polling cycle has started since %s", t.cycle.End.Format(time.RFC3339))
+	}
+	return ""
+}
+
+func (t *Tracker) unreadyReasons(maxMissedIntervals int) []string {
+	var reasons []string
+	switch {
+	case t.lastSuccess.IsZero():
+		reasons = append(reasons, "no polling cycle has succeeded yet")
+	case t.now().Sub(t.lastSuccess) > time.Duration(maxMissedIntervals)*t.interval:
+		reasons = append(reasons, fmt.Sprintf("last successful polling cycle finished at %s", t.lastSuccess.Format(time.RFC3339)))
+	}
+	if t.cycle != nil && t.cycle.Error != "" {
+		reasons = append(reasons, "AWS: "+t.cycle.Error)
+	}
+	if t.anchore.Error != "" {
+		reasons = append(reasons, "Anchore: "+t.anchore.Error)
+	}
+	return reasons
+}
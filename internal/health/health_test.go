@@ -0,0 +1,158 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTracker returns a tracker whose clock is advanced by the returned function
+func newTestTracker() (*Tracker, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t := NewTracker()
+	t.now = func() time.Time { return now }
+	return t, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTrackerStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		run         func(tracker *Tracker, advance func(time.Duration))
+		wantHealthy bool
+		wantReady   bool
+		wantReasons []string
+	}{
+		{
+			name:        "starting up",
+			run:         func(_ *Tracker, _ func(time.Duration)) {},
+			wantHealthy: true,
+			wantReasons: []string{"no polling cycle has succeeded yet"},
+		},
+		{
+			name: "successful cycle",
+			run: func(tracker *Tracker, advance func(time.Duration)) {
+				tracker.CycleStarted("us-east-1", time.Minute)
+				advance(10 * time.Second)
+				tracker.AnchoreReported(nil)
+				tracker.CycleFinished(nil)
+			},
+			wantHealthy: true,
+			wantReady:   true,
+		},
+		{
+			name: "failed cycle",
+			run: func(tracker *Tracker, _ func(time.Duration)) {
+				tracker.CycleStarted("us-east-1", time.Minute)
+				tracker.CycleFinished(nil)
+				tracker.CycleStarted("us-east-1", time.Minute)
+				tracker.AnchoreReported(errors.New("connection refused"))
+				tracker.CycleFinished(errors.New("no credentials"))
+			},
+			wantHealthy: true,
+			wantReasons: []string{"AWS: no credentials", "Anchore: connection refused"},
+		},
+		{
+			name: "cycle stuck",
+			run: func(tracker *Tracker, advance func(time.Duration)) {
+				tracker.CycleStarted("us-east-1", time.Minute)
+				tracker.CycleFinished(nil)
+				tracker.CycleStarted("us-east-1", time.Minute)
+				advance(4 * time.Minute)
+			},
+			wantReasons: []string{
+				"polling cycle started at 2024-01-01T00:00:00Z has not finished",
+				"last successful polling cycle finished at 2024-01-01T00:00:00Z",
+			},
+		},
+		{
+			name: "loop stopped",
+			run: func(tracker *Tracker, advance func(time.Duration)) {
+				tracker.CycleStarted("us-east-1", time.Minute)
+				tracker.CycleFinished(nil)
+				advance(4 * time.Minute)
+			},
+			wantReasons: []string{
+				"no polling cycle has started since 2024-01-01T00:00:00Z",
+				"last successful polling cycle finished at 2024-01-01T00:00:00Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, advance := newTestTracker()
+			tt.run(tracker, advance)
+
+			status := tracker.Status(3)
+
+			assert.Equal(t, tt.wantHealthy, status.Healthy)
+			assert.Equal(t, tt.wantReady, status.Ready)
+			assert.Equal(t, tt.wantReasons, status.Reasons)
+		})
+	}
+}
+
+func TestTrackerClustersAreReplacedEachCycle(t *testing.T) {
+	tracker, _ := newTestTracker()
+
+	tracker.CycleStarted("us-east-1", time.Minute)
+	tracker.ClusterFinished(ClusterStatus{ClusterARN: "cluster-2", Tasks: 1, Containers: 2})
+	tracker.ClusterFinished(ClusterStatus{ClusterARN: "cluster-1", Error: "access denied"})
+	tracker.CycleFinished(nil)
+
+	tracker.CycleStarted("us-east-1", time.Minute)
+	tracker.ClusterFinished(ClusterStatus{ClusterARN: "cluster-3"})
+
+	// the cycle in progress is not reported until it finishes
+	clusters := tracker.Status(3).Clusters
+	require.Len(t, clusters, 2)
+	assert.Equal(t, "cluster-1", clusters[0].ClusterARN)
+	assert.Equal(t, "access denied", clusters[0].Error)
+	assert.Equal(t, "cluster-2", clusters[1].ClusterARN)
+
+	tracker.CycleFinished(nil)
+	clusters = tracker.Status(3).Clusters
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "cluster-3", clusters[0].ClusterARN)
+}
+
+func TestRegister(t *testing.T) {
+	tracker, _ := newTestTracker()
+	mux := http.NewServeMux()
+	Register(mux, tracker, 3)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, get("/healthz").Code)
+	notReady := get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, notReady.Code)
+	assert.Equal(t, "no polling cycle has succeeded yet\n", notReady.Body.String())
+
+	tracker.CycleStarted("us-east-1", time.Minute)
+	tracker.ClusterFinished(ClusterStatus{ClusterARN: "cluster-1", Tasks: 1, Containers: 2})
+	tracker.CycleFinished(nil)
+	assert.Equal(t, http.StatusOK, get("/readyz").Code)
+
+	resp := get("/status")
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	var status Status
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+	assert.True(t, status.Ready)
+	assert.Equal(t, "us-east-1", status.Region)
+	assert.Equal(t, 60, status.PollingIntervalSeconds)
+	assert.Equal(t, []ClusterStatus{{
+		ClusterARN: "cluster-1",
+		Timestamp:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Tasks:      1,
+		Containers: 2,
+	}}, status.Clusters)
+}
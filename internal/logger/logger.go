@@ -1,11 +1,14 @@
 package logger
 
 import (
+	"context"
 	"log"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 
+	"github.com/anchore/ecs-inventory/internal/redact"
 	"github.com/anchore/ecs-inventory/pkg/logger"
 )
 
@@ -23,8 +26,14 @@ func (log NoOpLogger) Warnf(string, ...interface{}) {}
 
 func (log NoOpLogger) Error(string, error, ...interface{}) {}
 
+func (log NoOpLogger) With(...interface{}) logger.Logger {
+	return log
+}
+
 type ZapLogger struct {
 	zap *zap.SugaredLogger
+	// level is shared by the logger and every logger derived from it with With
+	level zap.AtomicLevel
 }
 
 func (log ZapLogger) Debug(msg string, args ...interface{}) {
@@ -53,43 +62,141 @@ func (log ZapLogger) Error(msg string, err error, args ...interface{}) {
 	log.zap.Errorw(msg, args...)
 }
 
+func (log ZapLogger) With(args ...interface{}) logger.Logger {
+	return ZapLogger{zap: log.zap.With(args...), level: log.level}
+}
+
+// SetLevel changes the level of the logger, and of every logger derived from it, while it is in use
+func (log ZapLogger) SetLevel(level string) error {
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.level.SetLevel(l)
+	return nil
+}
+
+// Log formats
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
 type LogConfig struct {
-	Level        string
+	Level string
+	// Format is either FormatConsole or FormatJSON, if empty JSON is used for log files and console otherwise
+	Format       string
 	FileLocation string
+	// Rotation limits the size and retention of the log file
+	Rotation Rotation
+	// Stdout, when set, writes to stdout as well as the log file
+	Stdout bool
+	// Redactor, when set, removes known secret values from everything written to the log
+	Redactor *redact.Redactor
+}
+
+// Rotation configures when the log file is rotated and how many rotated files are kept, zero values use the defaults of
+// lumberjack (rotate at 100MB and keep every rotated file)
+type Rotation struct {
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+	Compress   bool
 }
 
 var Log logger.Logger = &NoOpLogger{}
 
-func InitZapLogger(logConfig LogConfig) *ZapLogger {
-	var cfg zap.Config
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger, typically one with contextual fields added using With
+func NewContext(ctx context.Context, l logger.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
 
+// FromContext returns the logger carried by the context, or Log if there is none
+func FromContext(ctx context.Context) logger.Logger {
+	if l, ok := ctx.Value(contextKey{}).(logger.Logger); ok {
+		return l
+	}
+	return Log
+}
+
+// With returns a logger that adds the key-value pairs to every message, or l itself if it is not a logger.FieldLogger
+func With(l logger.Logger, args ...interface{}) logger.Logger {
+	if fields, ok := l.(logger.FieldLogger); ok {
+		return fields.With(args...)
+	}
+	return l
+}
+
+func InitZapLogger(logConfig LogConfig) *ZapLogger {
 	level, err := zap.ParseAtomicLevel(logConfig.Level)
 	if err != nil {
 		log.Printf("Invalid log level: %s, defaulting to `info`", logConfig.Level)
 		level = zap.NewAtomicLevelAt(zap.InfoLevel)
 	}
 
+	var cores []zapcore.Core
 	if logConfig.FileLocation != "" {
-		cfg = zap.Config{
-			Level:         level,
-			Encoding:      "json",
-			EncoderConfig: zap.NewProductionEncoderConfig(),
-			OutputPaths:   []string{logConfig.FileLocation},
-		}
-	} else {
-		zapEncoderCfg := zap.NewProductionEncoderConfig()
-		zapEncoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
-
-		cfg = zap.Config{
-			Level:            level,
-			Encoding:         "console",
-			EncoderConfig:    zapEncoderCfg,
-			OutputPaths:      []string{"stdout"},
-			ErrorOutputPaths: []string{"stderr"},
+		sink := zapcore.AddSync(&lumberjack.Logger{
+			Filename:   logConfig.FileLocation,
+			MaxSize:    logConfig.Rotation.MaxSizeMB,
+			MaxAge:     logConfig.Rotation.MaxAgeDays,
+			MaxBackups: logConfig.Rotation.MaxBackups,
+			Compress:   logConfig.Rotation.Compress,
+		})
+		cores = append(cores, zapcore.NewCore(newEncoder(logConfig.Format, FormatJSON), redacting(sink, logConfig.Redactor), level))
+	}
+	if logConfig.FileLocation == "" || logConfig.Stdout {
+		sink, _, err := zap.Open("stdout")
+		if err != nil {
+			panic(err)
 		}
+		cores = append(cores, zapcore.NewCore(newEncoder(logConfig.Format, FormatConsole), redacting(sink, logConfig.Redactor), level))
+	}
+	errSink, _, err := zap.Open("stderr")
+	if err != nil {
+		panic(err)
 	}
 
+	// equivalent to zap.Config.Build for a production config
+	core := zapcore.NewTee(cores...)
 	return &ZapLogger{
-		zap: zap.Must(cfg.Build()).Sugar(),
+		zap:   zap.New(core, zap.ErrorOutput(errSink), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).Sugar(),
+		level: level,
+	}
+}
+
+// newEncoder creates an encoder for the configured format, or the default format for the output if none is configured
+func newEncoder(format, defaultFormat string) zapcore.Encoder {
+	if format == "" {
+		format = defaultFormat
+	}
+
+	if format == FormatJSON {
+		return zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+	zapEncoderCfg := zap.NewProductionEncoderConfig()
+	zapEncoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	return zapcore.NewConsoleEncoder(zapEncoderCfg)
+}
+
+func redacting(sink zapcore.WriteSyncer, redactor *redact.Redactor) zapcore.WriteSyncer {
+	if redactor == nil {
+		return sink
+	}
+	return redactingWriteSyncer{WriteSyncer: sink, redactor: redactor}
+}
+
+// redactingWriteSyncer removes secrets from encoded log entries, covering messages, fields and errors alike
+type redactingWriteSyncer struct {
+	zapcore.WriteSyncer
+	redactor *redact.Redactor
+}
+
+func (w redactingWriteSyncer) Write(p []byte) (int, error) {
+	if _, err := w.WriteSyncer.Write([]byte(w.redactor.String(string(p)))); err != nil {
+		return 0, err
 	}
+	return len(p), nil
 }
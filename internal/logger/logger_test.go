@@ -1,11 +1,19 @@
 package logger
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/internal/redact"
+	"github.com/anchore/ecs-inventory/pkg/logger"
 )
 
 func TestLoggerInit(t *testing.T) {
@@ -96,3 +104,127 @@ func TestZapLoggerMethods(t *testing.T) {
 	assert.Contains(t, string(b), "warn message")
 	assert.Contains(t, string(b), "error message")
 }
+
+func TestRedactsSecretsFromLogOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	fileLocation := path.Join(tmpDir, "log")
+
+	redactor := &redact.Redactor{}
+	redactor.SetValues("supersecret")
+	zapLogger := InitZapLogger(LogConfig{Level: "info", FileLocation: fileLocation, Redactor: redactor})
+
+	zapLogger.Info("connecting with supersecret", "password", "supersecret")
+	zapLogger.Error("failed to connect", fmt.Errorf("bad password supersecret"))
+
+	b, err := os.ReadFile(fileLocation)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "supersecret")
+	assert.Contains(t, string(b), "connecting with ******")
+	assert.Contains(t, string(b), "bad password ******")
+}
+
+func TestWithLoggerWithoutFields(t *testing.T) {
+	// a logger that can't add fields, as set by a library consumer, logs without them
+	var plain logger.Logger = plainLogger{}
+	assert.Equal(t, plain, With(plain, "cluster", "cluster-1"))
+}
+
+// plainLogger only implements logger.Logger
+type plainLogger struct{}
+
+func (plainLogger) Error(string, error, ...interface{}) {}
+func (plainLogger) Warn(string, ...interface{})         {}
+func (plainLogger) Warnf(string, ...interface{})        {}
+func (plainLogger) Info(string, ...interface{})         {}
+func (plainLogger) Debug(string, ...interface{})        {}
+func (plainLogger) Debugf(string, ...interface{})       {}
+
+func TestWithAddsFieldsToEveryMessage(t *testing.T) {
+	fileLocation := path.Join(t.TempDir(), "log")
+	zapLogger := InitZapLogger(LogConfig{Level: "info", FileLocation: fileLocation})
+
+	clusterLogger := zapLogger.With("cluster", "cluster-1", "region", "us-east-1")
+	clusterLogger.Info("first")
+	With(clusterLogger, "run_id", "abc").Warn("second")
+	zapLogger.Info("third")
+
+	b, err := os.ReadFile(fileLocation)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"cluster":"cluster-1","region":"us-east-1"`)
+	assert.Contains(t, lines[1], `"cluster":"cluster-1","region":"us-east-1","run_id":"abc"`)
+	assert.NotContains(t, lines[2], "cluster")
+}
+
+func TestSetLevel(t *testing.T) {
+	fileLocation := path.Join(t.TempDir(), "log")
+	zapLogger := InitZapLogger(LogConfig{Level: "info", FileLocation: fileLocation})
+	clusterLogger := zapLogger.With("cluster", "cluster-1")
+
+	zapLogger.Debug("before")
+	assert.NoError(t, zapLogger.SetLevel("debug"))
+	// loggers derived before the level changed follow it
+	clusterLogger.Debug("after")
+	assert.Error(t, zapLogger.SetLevel("verbose"))
+
+	b, err := os.ReadFile(fileLocation)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "before")
+	assert.Contains(t, string(b), "after")
+	assert.Equal(t, "debug", zapLogger.zap.Level().String())
+}
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		wantJSON bool
+	}{
+		{name: "log files default to json", format: "", wantJSON: true},
+		{name: "json", format: FormatJSON, wantJSON: true},
+		{name: "console", format: FormatConsole, wantJSON: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileLocation := path.Join(t.TempDir(), "log")
+			InitZapLogger(LogConfig{Level: "info", Format: tt.format, FileLocation: fileLocation}).Info("message")
+
+			b, err := os.ReadFile(fileLocation)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantJSON, json.Valid(b))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, Log, FromContext(context.Background()))
+
+	l := &ZapLogger{}
+	assert.Equal(t, l, FromContext(NewContext(context.Background(), l)))
+}
+
+func TestRotatesLogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	fileLocation := path.Join(tmpDir, "log")
+	zapLogger := InitZapLogger(LogConfig{
+		Level:        "info",
+		FileLocation: fileLocation,
+		Rotation:     Rotation{MaxSizeMB: 1, MaxBackups: 2},
+	})
+
+	// each message is ~100KB, so the log is rotated every ~10 messages
+	msg := strings.Repeat("x", 100*1024)
+	for i := 0; i < 50; i++ {
+		zapLogger.Info(msg)
+	}
+
+	assert.Eventually(t, func() bool {
+		entries, err := os.ReadDir(tmpDir)
+		// the current log file plus the maximum number of backups
+		return err == nil && len(entries) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	info, err := os.Stat(fileLocation)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+}
@@ -0,0 +1,174 @@
+// Prometheus metrics describing what the inventory found and how the agent is performing
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "anchore_ecs_inventory"
+
+// Registry holds every metric, along with the standard go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	Clusters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clusters",
+		Help:      "Number of clusters found in the region during the last polling cycle.",
+	}, []string{"region"})
+
+	Tasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tasks",
+		Help:      "Number of running tasks found in the cluster during the last polling cycle.",
+	}, []string{"cluster"})
+
+	Containers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "containers",
+		Help:      "Number of containers found in the cluster during the last polling cycle.",
+	}, []string{"cluster"})
+
+	ContainersMissingDigest = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "containers_missing_digest",
+		Help:      "Number of containers in the cluster whose image digest was not reported by ECS during the last polling cycle.",
+	}, []string{"cluster"})
+
+	ECSAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ecs_api_calls_total",
+		Help:      "Number of ECS API calls made, by operation.",
+	}, []string{"operation"})
+
+	ECSAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ecs_api_errors_total",
+		Help:      "Number of ECS API calls that failed (after retries), by operation.",
+	}, []string{"operation"})
+
+	ECSAPIThrottles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ecs_api_throttles_total",
+		Help:      "Number of ECS API requests that were throttled (including those that succeeded on retry), by operation.",
+	}, []string{"operation"})
+
+	ECSAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ecs_api_duration_seconds",
+		Help:      "Duration of ECS API calls (including retries), by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	AnchorePosts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "anchore_posts_total",
+		Help:      "Number of attempts to post an inventory report to Anchore.",
+	})
+
+	AnchorePostFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "anchore_post_failures_total",
+		Help:      "Number of inventory reports that failed to post to Anchore.",
+	})
+
+	AnchorePostDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "anchore_post_duration_seconds",
+		Help:      "Duration of posting an inventory report to Anchore.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	Events = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Number of ECS events received from the SQS queue in event mode, by detail type.",
+	}, []string{"type"})
+
+	EventFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_failures_total",
+		Help:      "Number of ECS events that could not be applied to the inventory and were left on the queue to be retried, by detail type.",
+	}, []string{"type"})
+
+	LastSuccessfulCycle = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_cycle_timestamp_seconds",
+		Help:      "Unix time of the end of the last polling cycle that completed without error.",
+	})
+
+	CycleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cycle_duration_seconds",
+		Help:      "Duration of a polling cycle.",
+		Buckets:   []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Clusters,
+		Tasks,
+		Containers,
+		ContainersMissingDigest,
+		ECSAPICalls,
+		ECSAPIErrors,
+		ECSAPIThrottles,
+		ECSAPIDuration,
+		AnchorePosts,
+		AnchorePostFailures,
+		AnchorePostDuration,
+		Events,
+		EventFailures,
+		LastSuccessfulCycle,
+		CycleDuration,
+	)
+}
+
+// Handler serves the metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+var (
+	clustersMu    sync.Mutex
+	knownClusters = map[string]bool{}
+)
+
+// SetClusters records the clusters found in a region, and removes the per cluster metrics of clusters that have since
+// been deleted so they are not reported forever
+func SetClusters(region string, clusters []string) {
+	Clusters.WithLabelValues(region).Set(float64(len(clusters)))
+
+	current := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		current[cluster] = true
+	}
+
+	clustersMu.Lock()
+	defer clustersMu.Unlock()
+	for cluster := range knownClusters {
+		if !current[cluster] {
+			Tasks.DeleteLabelValues(cluster)
+			Containers.DeleteLabelValues(cluster)
+			ContainersMissingDigest.DeleteLabelValues(cluster)
+		}
+	}
+	knownClusters = current
+}
+
+// ObserveCycle records the duration of a polling cycle, and its completion time if it succeeded
+func ObserveCycle(start time.Time, err error) {
+	CycleDuration.Observe(time.Since(start).Seconds())
+	if err == nil {
+		LastSuccessfulCycle.SetToCurrentTime()
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetClustersRemovesDeletedClusters(t *testing.T) {
+	SetClusters("us-east-1", []string{"cluster-1", "cluster-2"})
+	Tasks.WithLabelValues("cluster-1").Set(1)
+	Tasks.WithLabelValues("cluster-2").Set(2)
+	assert.Equal(t, 2, testutil.CollectAndCount(Tasks))
+
+	SetClusters("us-east-1", []string{"cluster-1"})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(Clusters.WithLabelValues("us-east-1")))
+	assert.Equal(t, 1, testutil.CollectAndCount(Tasks))
+	assert.Equal(t, 1.0, testutil.ToFloat64(Tasks.WithLabelValues("cluster-1")))
+}
+
+func TestObserveCycle(t *testing.T) {
+	LastSuccessfulCycle.Set(0)
+
+	ObserveCycle(time.Now(), errors.New("failed"))
+	assert.Equal(t, 0.0, testutil.ToFloat64(LastSuccessfulCycle))
+
+	ObserveCycle(time.Now(), nil)
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(LastSuccessfulCycle), 5)
+}
+
+func TestHandler(t *testing.T) {
+	AnchorePosts.Inc()
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 200, recorder.Code)
+	assert.Contains(t, string(body), "anchore_ecs_inventory_anchore_posts_total")
+	assert.Contains(t, string(body), "go_goroutines")
+}
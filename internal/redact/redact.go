@@ -0,0 +1,170 @@
+// Handles removing sensitive information (e.g. credentials and sensitive ECS tag values) from anything that is logged
+// or displayed
+package redact
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const Redacted = "******"
+
+// minSecretLength is the shortest value that will be redacted from free text, shorter values are too likely to match
+// ordinary log output
+const minSecretLength = 4
+
+// Default is the redactor used for log output and anything else that is displayed
+var Default = &Redactor{}
+
+// Struct returns a copy of v where every non-empty string field tagged with `sensitive:"true"` (including those in
+// nested structs) is replaced with Redacted
+func Struct[T any](v T) T {
+	value := reflect.ValueOf(&v).Elem()
+	redactStruct(value)
+	return v
+}
+
+func redactStruct(value reflect.Value) {
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := value.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Struct:
+			redactStruct(field)
+		case isSensitive(t.Field(i)) && field.Kind() == reflect.String && field.String() != "":
+			field.SetString(Redacted)
+		}
+	}
+}
+
+// SensitiveValues returns the non-empty values of every string field tagged with `sensitive:"true"`
+func SensitiveValues(v any) []string {
+	var values []string
+	collectSensitiveValues(reflect.ValueOf(v), &values)
+	return values
+}
+
+func collectSensitiveValues(value reflect.Value, values *[]string) {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			collectSensitiveValues(field, values)
+		case isSensitive(t.Field(i)) && field.Kind() == reflect.String && field.String() != "":
+			*values = append(*values, field.String())
+		}
+	}
+}
+
+func isSensitive(field reflect.StructField) bool {
+	return field.Tag.Get("sensitive") == "true"
+}
+
+// Redactor masks known secret values wherever they appear in text, and the values of tags whose keys match any of a
+// list of patterns. It is safe for concurrent use, so the values can be updated when the config is reloaded.
+type Redactor struct {
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	tagKeys  []string
+}
+
+// SetValues replaces the set of secret values to redact from text
+func (r *Redactor) SetValues(values ...string) {
+	var oldnew []string
+	for _, value := range values {
+		if len(value) < minSecretLength {
+			continue
+		}
+		oldnew = append(oldnew, value, Redacted)
+		// structured (json) log output escapes special characters, so also redact the escaped form
+		if escaped, err := json.Marshal(value); err == nil {
+			if escapedValue := string(escaped[1 : len(escaped)-1]); escapedValue != value {
+				oldnew = append(oldnew, escapedValue, Redacted)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(oldnew) == 0 {
+		r.replacer = nil
+		return
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// SetTagKeys replaces the list of tag key patterns (see path.Match) whose values are redacted. Patterns are matched
+// case-insensitively.
+func (r *Redactor) SetTagKeys(patterns []string) {
+	lowered := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		lowered = append(lowered, strings.ToLower(pattern))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tagKeys = lowered
+}
+
+// String returns s with every known secret value replaced
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Tags returns a copy of the tags with the values of sensitive tag keys replaced
+func (r *Redactor) Tags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if r.IsSensitiveTagKey(key) {
+			value = Redacted
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// IsSensitiveTagKey reports whether the value of a tag with the given key should be redacted
+func (r *Redactor) IsSensitiveTagKey(key string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key = strings.ToLower(key)
+	for _, pattern := range r.tagKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidTagKeyPattern reports whether a tag key pattern can be used with SetTagKeys
+func ValidTagKeyPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
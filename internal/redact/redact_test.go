@@ -0,0 +1,88 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nested struct {
+	Token string `sensitive:"true"`
+	Name  string
+}
+
+type example struct {
+	Password string `sensitive:"true"`
+	User     string
+	Empty    string `sensitive:"true"`
+	Nested   nested
+	Count    int `sensitive:"true"`
+}
+
+func TestStruct(t *testing.T) {
+	original := example{
+		Password: "foobar",
+		User:     "admin",
+		Nested:   nested{Token: "abcd1234", Name: "nested"},
+		Count:    3,
+	}
+
+	redacted := Struct(original)
+
+	assert.Equal(t, example{
+		Password: Redacted,
+		User:     "admin",
+		Empty:    "",
+		Nested:   nested{Token: Redacted, Name: "nested"},
+		Count:    3,
+	}, redacted)
+	assert.Equal(t, "foobar", original.Password, "the original must not be modified")
+}
+
+func TestSensitiveValues(t *testing.T) {
+	values := SensitiveValues(&example{
+		Password: "foobar",
+		User:     "admin",
+		Nested:   nested{Token: "abcd1234"},
+	})
+
+	assert.Equal(t, []string{"foobar", "abcd1234"}, values)
+	assert.Empty(t, SensitiveValues((*example)(nil)))
+}
+
+func TestRedactorString(t *testing.T) {
+	r := &Redactor{}
+	assert.Equal(t, "password is foobar", r.String("password is foobar"))
+
+	r.SetValues("foobar", `quo"te`, "abc", "")
+
+	assert.Equal(t, "password is ******", r.String("password is foobar"))
+	assert.Equal(t, `{"password":"******"}`, r.String(`{"password":"quo\"te"}`))
+	assert.Equal(t, "abc is too short to redact", r.String("abc is too short to redact"))
+
+	r.SetValues()
+	assert.Equal(t, "password is foobar", r.String("password is foobar"))
+}
+
+func TestRedactorTags(t *testing.T) {
+	r := &Redactor{}
+	r.SetTagKeys([]string{"*password*", "API_KEY"})
+
+	redacted := r.Tags(map[string]string{
+		"DB_PASSWORD": "hunter2",
+		"api_key":     "abc",
+		"team":        "platform",
+	})
+
+	assert.Equal(t, map[string]string{
+		"DB_PASSWORD": Redacted,
+		"api_key":     Redacted,
+		"team":        "platform",
+	}, redacted)
+	assert.Nil(t, r.Tags(nil))
+}
+
+func TestValidTagKeyPattern(t *testing.T) {
+	assert.True(t, ValidTagKeyPattern("*secret*"))
+	assert.False(t, ValidTagKeyPattern("[secret"))
+}
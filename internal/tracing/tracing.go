@@ -0,0 +1,107 @@
+// Exports OpenTelemetry traces of each polling cycle (ECS API calls and reports to Anchore) to an OTLP collector
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/anchore/ecs-inventory/internal"
+	"github.com/anchore/ecs-inventory/internal/config"
+	"github.com/anchore/ecs-inventory/internal/version"
+)
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Attribute keys shared by the spans
+const (
+	Region     = attribute.Key("aws.region")
+	Cluster    = attribute.Key("aws.ecs.cluster.arn")
+	Clusters   = attribute.Key("ecs_inventory.clusters")
+	Tasks      = attribute.Key("ecs_inventory.tasks")
+	Services   = attribute.Key("ecs_inventory.services")
+	Containers = attribute.Key("ecs_inventory.containers")
+)
+
+// Tracer returns the tracer for the application, spans are dropped until Init is called with tracing enabled
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/anchore/ecs-inventory")
+}
+
+// Init configures the global tracer provider to export spans with OTLP. The returned function flushes any buffered
+// spans and must be called before exiting.
+func Init(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", internal.ApplicationName),
+		attribute.String("service.version", version.FromBuild().Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// newExporter creates an OTLP exporter for the configured protocol, an empty endpoint falls back to the standard
+// OTEL_EXPORTER_OTLP_* environment variables (or the collector default of localhost)
+func newExporter(ctx context.Context, cfg config.Tracing) (sdktrace.SpanExporter, error) {
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ProtocolHTTP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", cfg.Protocol)
+	}
+}
+
+// End records the error (if any) on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -4,7 +4,7 @@ package connection
 type AnchoreInfo struct {
 	URL      string     `mapstructure:"url"`
 	User     string     `mapstructure:"user"`
-	Password string     `mapstructure:"password"`
+	Password string     `mapstructure:"password" sensitive:"true"`
 	Account  string     `mapstructure:"account"`
 	HTTP     HTTPConfig `mapstructure:"http"`
 }
@@ -0,0 +1,238 @@
+// Checks that everything anchore-ecs-inventory needs (AWS credentials, IAM permissions and the Anchore connection) is
+// in place, and suggests fixes for anything that isn't
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/anchore/ecs-inventory/pkg/connection"
+	"github.com/anchore/ecs-inventory/pkg/inventory"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Check is the outcome of a single check, with a hint on how to fix it if it failed
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string
+}
+
+type Options struct {
+	Region  string
+	Cluster string
+	// Collect and Events select the optional features, only the actions used with them are checked, as listed in the
+	// IAM policy for them
+	Collect inventory.CollectOptions
+	Events  bool
+	// QueueURL and QueueEndpoint are the SQS queue (and SQS API endpoint, if not the regional one) ECS events are
+	// consumed from
+	QueueURL       string
+	QueueEndpoint  string
+	AnchoreDetails connection.AnchoreInfo
+	// DryRun resolves credentials and config only, without calling the AWS or Anchore APIs
+	DryRun bool
+}
+
+// Run runs every check, later checks are skipped where they depend on an earlier check that failed
+func Run(ctx context.Context, opts Options) []Check {
+	awsChecks, cfg, principal := checkAWS(ctx, opts)
+	checks := awsChecks
+
+	permissions := inventory.PolicyOptions{Collect: opts.Collect, Events: opts.Events}.Permissions()
+	if cfg == nil {
+		for _, permission := range permissions {
+			checks = append(checks, Check{Name: permission.Action, Status: StatusSkip, Detail: "AWS credentials are not available"})
+		}
+	} else {
+		clients := inventory.PermissionClients{
+			ECS:    ecs.NewFromConfig(*cfg),
+			Events: eventbridge.NewFromConfig(*cfg),
+			SQS: sqs.NewFromConfig(*cfg, func(o *sqs.Options) {
+				if opts.QueueEndpoint != "" {
+					o.BaseEndpoint = aws.String(opts.QueueEndpoint)
+				}
+			}),
+			QueueURL: opts.QueueURL,
+		}
+		permissionChecks := inventory.CheckPermissions(ctx, clients, permissions, opts.Cluster, opts.DryRun)
+		checks = append(checks, permissionResults(permissionChecks, principal)...)
+	}
+
+	return append(checks, checkAnchore(opts)...)
+}
+
+// checkAWS checks that a region is set and credentials can be resolved, returning the AWS config and the identity
+// the credentials belong to if they can
+func checkAWS(ctx context.Context, opts Options) ([]Check, *aws.Config, string) {
+	cfg, err := inventory.LoadAWSConfig(ctx, opts.Region)
+	if err != nil {
+		return []Check{{
+			Name:   "AWS config",
+			Status: StatusFail,
+			Detail: err.Error(),
+			Hint:   "check the AWS shared config file (~/.aws/config) and AWS_* environment variables",
+		}}, nil, ""
+	}
+	if cfg.Region == "" {
+		return []Check{{
+			Name:   "AWS region",
+			Status: StatusFail,
+			Detail: "no region set",
+			Hint:   "set the region with --region, ANCHORE_ECS_INVENTORY_REGION or region in the config file",
+		}}, nil, ""
+	}
+	checks := []Check{{Name: "AWS region", Status: StatusPass, Detail: cfg.Region}}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return append(checks, Check{
+			Name:   "AWS credentials",
+			Status: StatusFail,
+			Detail: err.Error(),
+			Hint: "provide credentials with AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, AWS_PROFILE, ~/.aws/credentials, " +
+				"or run with an ECS task role or EC2 instance profile",
+		}), nil, ""
+	}
+	if opts.DryRun {
+		return append(checks, Check{Name: "AWS credentials", Status: StatusPass, Detail: "resolved from " + creds.Source}), &cfg, ""
+	}
+
+	// GetCallerIdentity needs no permissions, so it only fails if the credentials themselves are invalid
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return append(checks, Check{
+			Name:   "AWS credentials",
+			Status: StatusFail,
+			Detail: fmt.Sprintf("credentials resolved from %s were rejected: %v", creds.Source, err),
+			Hint:   "check the credentials have not expired or been deactivated, and that the system clock is correct",
+		}), nil, ""
+	}
+	principal := aws.ToString(identity.Arn)
+	return append(checks, Check{
+		Name:   "AWS credentials",
+		Status: StatusPass,
+		Detail: fmt.Sprintf("resolved from %s as %s", creds.Source, principal),
+	}), &cfg, principal
+}
+
+func permissionResults(permissionChecks []inventory.PermissionCheck, principal string) []Check {
+	if principal == "" {
+		principal = "the IAM role or user anchore-ecs-inventory runs as"
+	}
+	checks := make([]Check, 0, len(permissionChecks))
+	for _, permissionCheck := range permissionChecks {
+		check := Check{Name: permissionCheck.Action, Detail: permissionCheck.Detail}
+		switch {
+		case permissionCheck.Skipped:
+			check.Status = StatusSkip
+		case permissionCheck.Err != nil:
+			check.Status = StatusFail
+			check.Detail = permissionCheck.Err.Error()
+			check.Hint = "check network access to the AWS API endpoints for the region"
+		case permissionCheck.Allowed:
+			check.Status = StatusPass
+		default:
+			check.Status = StatusFail
+			check.Detail = "access denied"
+			check.Hint = fmt.Sprintf("allow %s for %s, \"anchore-ecs-inventory iam-policy\" prints the full policy needed", permissionCheck.Action, principal)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func checkAnchore(opts Options) []Check {
+	if !opts.AnchoreDetails.IsValid() {
+		return []Check{{
+			Name:   "Anchore connection",
+			Status: StatusSkip,
+			Detail: "Anchore details not specified, inventory will not be reported",
+			Hint:   "set anchore.url, anchore.user and anchore.password to report inventory to Anchore",
+		}}
+	}
+	if opts.DryRun {
+		return []Check{{Name: "Anchore connection", Status: StatusSkip, Detail: "dry run, would contact " + opts.AnchoreDetails.URL}}
+	}
+
+	version, err := reporter.FetchVersion(opts.AnchoreDetails)
+	if err != nil {
+		return []Check{
+			{
+				Name:   "Anchore connection",
+				Status: StatusFail,
+				Detail: err.Error(),
+				Hint: "check anchore.url is correct and reachable from here, set anchore.http.insecure if Anchore " +
+					"uses a self-signed certificate",
+			},
+			{Name: "Anchore authentication", Status: StatusSkip, Detail: "Anchore is not reachable"},
+		}
+	}
+	checks := []Check{{
+		Name:   "Anchore connection",
+		Status: StatusPass,
+		Detail: fmt.Sprintf("%s, API version %s, service version %s", opts.AnchoreDetails.URL, version.API.Version, version.Service.Version),
+	}}
+
+	auth := Check{Name: "Anchore authentication", Status: StatusPass, Detail: "authenticated as " + opts.AnchoreDetails.User}
+	if err := reporter.CheckAuth(opts.AnchoreDetails, version); err != nil {
+		auth.Status = StatusFail
+		auth.Detail = err.Error()
+		switch {
+		case errors.Is(err, reporter.ErrUnauthorized):
+			auth.Hint = "check anchore.user and anchore.password"
+		case errors.Is(err, reporter.ErrForbidden):
+			auth.Hint = fmt.Sprintf("check anchore.account (%q) exists and %s is a member of it", opts.AnchoreDetails.Account, opts.AnchoreDetails.User)
+		}
+	}
+	return append(checks, auth)
+}
+
+// Failed reports whether any check failed
+func Failed(checks []Check) bool {
+	for _, check := range checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes each check, followed by a pass/fail summary
+func Print(w io.Writer, checks []Check) {
+	counts := map[Status]int{}
+	for _, check := range checks {
+		counts[check.Status]++
+		line := fmt.Sprintf("[%s] %s", check.Status, check.Name)
+		if check.Detail != "" {
+			line += ": " + check.Detail
+		}
+		fmt.Fprintln(w, line)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "       hint: %s\n", check.Hint)
+		}
+	}
+
+	result := "all checks passed"
+	if Failed(checks) {
+		result = "some checks failed"
+	}
+	fmt.Fprintf(w, "\n%s: %d passed, %d failed, %d skipped\n", result, counts[StatusPass], counts[StatusFail], counts[StatusSkip])
+}
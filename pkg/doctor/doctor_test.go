@@ -0,0 +1,144 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/pkg/connection"
+	"github.com/anchore/ecs-inventory/pkg/inventory"
+)
+
+var anchoreDetails = connection.AnchoreInfo{
+	URL:      "https://ancho.re",
+	User:     "admin",
+	Password: "foobar",
+	Account:  "test",
+	HTTP: connection.HTTPConfig{
+		TimeoutSeconds: 10,
+		Insecure:       true,
+	},
+}
+
+func Test_permissionResults(t *testing.T) {
+	checks := permissionResults([]inventory.PermissionCheck{
+		{Action: "ecs:ListClusters", Allowed: true},
+		{Action: "ecs:ListTasks"},
+		{Action: "ecs:ListServices", Err: errors.New("dial tcp: i/o timeout")},
+		{Action: "ecs:DescribeTasks", Skipped: true, Detail: "no cluster to check against"},
+	}, "arn:aws:sts::123456789012:assumed-role/inventory/task")
+
+	assert.Equal(t, []Check{
+		{Name: "ecs:ListClusters", Status: StatusPass},
+		{
+			Name:   "ecs:ListTasks",
+			Status: StatusFail,
+			Detail: "access denied",
+			Hint: "allow ecs:ListTasks for arn:aws:sts::123456789012:assumed-role/inventory/task, " +
+				"\"anchore-ecs-inventory iam-policy\" prints the full policy needed",
+		},
+		{
+			Name:   "ecs:ListServices",
+			Status: StatusFail,
+			Detail: "dial tcp: i/o timeout",
+			Hint:   "check network access to the AWS API endpoints for the region",
+		},
+		{Name: "ecs:DescribeTasks", Status: StatusSkip, Detail: "no cluster to check against"},
+	}, checks)
+}
+
+func Test_checkAnchore(t *testing.T) {
+	tests := []struct {
+		name           string
+		anchoreDetails connection.AnchoreInfo
+		dryRun         bool
+		mock           func()
+		want           []Status
+		wantHint       string
+	}{
+		{
+			name:           "not configured",
+			anchoreDetails: connection.AnchoreInfo{},
+			want:           []Status{StatusSkip},
+		},
+		{
+			name:           "dry run",
+			anchoreDetails: anchoreDetails,
+			dryRun:         true,
+			want:           []Status{StatusSkip},
+		},
+		{
+			name:           "reachable and authenticated",
+			anchoreDetails: anchoreDetails,
+			mock: func() {
+				gock.New("https://ancho.re").Get("/version").Reply(200).
+					JSON(map[string]interface{}{"api": map[string]interface{}{"version": "2"}})
+				gock.New("https://ancho.re").Get("/v2/account").Reply(200).JSON(map[string]interface{}{})
+			},
+			want: []Status{StatusPass, StatusPass},
+		},
+		{
+			name:           "unreachable",
+			anchoreDetails: anchoreDetails,
+			mock: func() {
+				gock.New("https://ancho.re").Get("/version").Reply(502)
+			},
+			want:     []Status{StatusFail, StatusSkip},
+			wantHint: "check anchore.url",
+		},
+		{
+			name:           "bad credentials",
+			anchoreDetails: anchoreDetails,
+			mock: func() {
+				gock.New("https://ancho.re").Get("/version").Reply(200).
+					JSON(map[string]interface{}{"api": map[string]interface{}{"version": "2"}})
+				gock.New("https://ancho.re").Get("/v2/account").Reply(401)
+			},
+			want:     []Status{StatusPass, StatusFail},
+			wantHint: "check anchore.user and anchore.password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			if tt.mock != nil {
+				tt.mock()
+			}
+
+			checks := checkAnchore(Options{AnchoreDetails: tt.anchoreDetails, DryRun: tt.dryRun})
+
+			var statuses []Status
+			var hints string
+			for _, check := range checks {
+				statuses = append(statuses, check.Status)
+				hints += check.Hint
+			}
+			assert.Equal(t, tt.want, statuses)
+			assert.Contains(t, hints, tt.wantHint)
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	checks := []Check{
+		{Name: "AWS region", Status: StatusPass, Detail: "us-east-1"},
+		{Name: "ecs:ListTasks", Status: StatusFail, Detail: "access denied", Hint: "allow ecs:ListTasks"},
+		{Name: "Anchore connection", Status: StatusSkip},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, checks)
+
+	assert.Equal(t, `[PASS] AWS region: us-east-1
+[FAIL] ecs:ListTasks: access denied
+       hint: allow ecs:ListTasks
+[SKIP] Anchore connection
+
+some checks failed: 1 passed, 1 failed, 1 skipped
+`, buf.String())
+	assert.True(t, Failed(checks))
+	assert.False(t, Failed(checks[:1]))
+}
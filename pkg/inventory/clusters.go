@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracker"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// containerInsightsSetting is the cluster setting that enables Container Insights
+const containerInsightsSetting = ecstypes.ClusterSettingNameContainerInsights
+
+func fetchClusterMetadata(ctx context.Context, client ECSAPI, cluster string) (*reporter.Cluster, error) {
+	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Fetching cluster metadata for cluster: %s", cluster))
+	results, err := client.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+		Clusters: []string{cluster},
+		Include:  []ecstypes.ClusterField{ecstypes.ClusterFieldTags, ecstypes.ClusterFieldSettings},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Clusters) == 0 {
+		reason := "cluster not found"
+		if len(results.Failures) > 0 {
+			reason = aws.ToString(results.Failures[0].Reason)
+		}
+		return nil, fmt.Errorf("unable to describe cluster %s: %s", cluster, reason)
+	}
+	return clusterMetadata(results.Clusters[0]), nil
+}
+
+func clusterMetadata(cluster ecstypes.Cluster) *reporter.Cluster {
+	c := &reporter.Cluster{
+		Name:                         aws.ToString(cluster.ClusterName),
+		Status:                       aws.ToString(cluster.Status),
+		Tags:                         map[string]string{},
+		CapacityProviders:            cluster.CapacityProviders,
+		RegisteredContainerInstances: int(cluster.RegisteredContainerInstancesCount),
+		RunningTasks:                 int(cluster.RunningTasksCount),
+		PendingTasks:                 int(cluster.PendingTasksCount),
+		ActiveServices:               int(cluster.ActiveServicesCount),
+	}
+	for _, tag := range cluster.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			c.Tags[*tag.Key] = *tag.Value
+		}
+	}
+	for _, setting := range cluster.Settings {
+		if setting.Name == containerInsightsSetting {
+			c.ContainerInsights = aws.ToString(setting.Value)
+		}
+	}
+	return c
+}
+
+// addClusterMetadata records the account and region of the cluster, along with the cluster's own metadata. The
+// account and region come from the cluster ARN, so they are recorded even when the cluster can't be described.
+func addClusterMetadata(ctx context.Context, client ECSAPI, report *reporter.Report) {
+	report.AccountID = accountFromARN(report.ClusterARN)
+	report.Region = regionFromARN(report.ClusterARN)
+
+	cluster, err := fetchClusterMetadata(ctx, client, report.ClusterARN)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Unable to describe cluster, cluster metadata will not be reported", "err", err)
+		return
+	}
+	report.Cluster = cluster
+}
+
+// regionFromARN returns the region of an ARN, or an empty string if it is not an ARN
+func regionFromARN(arn string) string {
+	arnParts := strings.SplitN(arn, ":", 6)
+	if len(arnParts) != 6 {
+		return ""
+	}
+	return arnParts[3]
+}
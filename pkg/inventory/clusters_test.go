@@ -0,0 +1,63 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_addClusterMetadata(t *testing.T) {
+	report := reporter.Report{ClusterARN: "arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"}
+
+	addClusterMetadata(context.Background(), &mockECSClient{}, &report)
+
+	assert.Equal(t, "123456789012", report.AccountID)
+	assert.Equal(t, "us-east-1", report.Region)
+	assert.Equal(t, &reporter.Cluster{
+		Name:                         "cluster-1",
+		Status:                       "ACTIVE",
+		Tags:                         map[string]string{"environment": "production"},
+		CapacityProviders:            []string{"FARGATE", "FARGATE_SPOT"},
+		ContainerInsights:            "enabled",
+		RegisteredContainerInstances: 1,
+		RunningTasks:                 2,
+		ActiveServices:               2,
+	}, report.Cluster)
+}
+
+func Test_addClusterMetadataIgnoresErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		client ECSAPI
+	}{
+		{
+			name:   "describe clusters error",
+			client: &mockECSClient{ErrorOnDescribeClusters: true},
+		},
+		{
+			name:   "cluster not found",
+			client: &mockECSClient{MissingClusters: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := reporter.Report{ClusterARN: "arn:aws:ecs:eu-west-2:123456789012:cluster/cluster-1"}
+
+			addClusterMetadata(context.Background(), tt.client, &report)
+
+			// the account and region come from the cluster ARN, so are always set
+			assert.Equal(t, "123456789012", report.AccountID)
+			assert.Equal(t, "eu-west-2", report.Region)
+			assert.Nil(t, report.Cluster)
+		})
+	}
+}
+
+func Test_regionFromARN(t *testing.T) {
+	assert.Equal(t, "us-east-1", regionFromARN("arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"))
+	assert.Equal(t, "cn-north-1", regionFromARN("arn:aws-cn:ecs:cn-north-1:123456789012:cluster/cluster-1"))
+	assert.Equal(t, "", regionFromARN("cluster-1"))
+}
@@ -0,0 +1,194 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracker"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// MinDigestAgentVersion is the first ECS agent version that reports image digests in the DescribeTasks API response
+const MinDigestAgentVersion = "1.70.0"
+
+// maxDescribeContainerInstances is the most container instances that can be described in a single call
+const maxDescribeContainerInstances = 100
+
+// attributes the ECS agent records on container instances
+const (
+	amiIDAttribute           = "ecs.ami-id"
+	cpuArchitectureAttribute = "ecs.cpu-architecture"
+	osFamilyAttribute        = "ecs.os-family"
+)
+
+func fetchContainerInstances(ctx context.Context, client ECSAPI, cluster string, containerInstanceARNs []string) (map[string]reporter.ContainerInstance, error) {
+	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Fetching container instances for cluster: %s", cluster))
+	instances := map[string]reporter.ContainerInstance{}
+	for start := 0; start < len(containerInstanceARNs); start += maxDescribeContainerInstances {
+		end := min(start+maxDescribeContainerInstances, len(containerInstanceARNs))
+		results, err := client.DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
+			Cluster:            aws.String(cluster),
+			ContainerInstances: containerInstanceARNs[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range results.ContainerInstances {
+			ci := containerInstanceMetadata(instance)
+			instances[ci.ARN] = ci
+		}
+	}
+	return instances, nil
+}
+
+func containerInstanceMetadata(instance ecstypes.ContainerInstance) reporter.ContainerInstance {
+	ci := reporter.ContainerInstance{
+		ARN:           aws.ToString(instance.ContainerInstanceArn),
+		EC2InstanceID: aws.ToString(instance.Ec2InstanceId),
+	}
+	if instance.VersionInfo != nil {
+		ci.AgentVersion = aws.ToString(instance.VersionInfo.AgentVersion)
+		ci.DockerVersion = aws.ToString(instance.VersionInfo.DockerVersion)
+	}
+	for _, attribute := range instance.Attributes {
+		switch aws.ToString(attribute.Name) {
+		case amiIDAttribute:
+			ci.AMIID = aws.ToString(attribute.Value)
+		case cpuArchitectureAttribute:
+			ci.CPUArchitecture = aws.ToString(attribute.Value)
+		case osFamilyAttribute:
+			ci.OSFamily = aws.ToString(attribute.Value)
+		}
+	}
+	return ci
+}
+
+// addContainerInstances records the container instance each task runs on, and the container instances whose agent
+// needs upgrading to report image digests. If the container instances can't be described, the tasks keep just the ARN
+// of their container instance and no agent upgrades are reported.
+func addContainerInstances(ctx context.Context, client ECSAPI, report *reporter.Report) {
+	log := logger.FromContext(ctx)
+
+	arns := []string{}
+	seen := map[string]bool{}
+	for _, task := range report.Tasks {
+		if task.ContainerInstance != nil && !seen[task.ContainerInstance.ARN] {
+			seen[task.ContainerInstance.ARN] = true
+			arns = append(arns, task.ContainerInstance.ARN)
+		}
+	}
+	if len(arns) == 0 {
+		return
+	}
+
+	instances, err := fetchContainerInstances(ctx, client, report.ClusterARN, arns)
+	if err != nil {
+		log.Warn("Unable to describe container instances, ECS agent versions will not be reported", "err", err)
+		return
+	}
+	for i, task := range report.Tasks {
+		if task.ContainerInstance == nil {
+			continue
+		}
+		if instance, ok := instances[task.ContainerInstance.ARN]; ok {
+			report.Tasks[i].ContainerInstance = &instance
+			// tasks on EC2 only report the platform they run on through their container instance
+			if task.CPUArchitecture == "" {
+				report.Tasks[i].CPUArchitecture = instance.CPUArchitecture
+			}
+			if task.OSFamily == "" {
+				report.Tasks[i].OSFamily = instance.OSFamily
+			}
+		}
+	}
+
+	report.AgentUpgrades = agentUpgrades(*report, instances)
+	if len(report.AgentUpgrades) > 0 {
+		outdated := make([]string, 0, len(report.AgentUpgrades))
+		for _, upgrade := range report.AgentUpgrades {
+			outdated = append(outdated, fmt.Sprintf("%s (%s)", upgrade.ARN, upgrade.AgentVersion))
+		}
+		log.Warn(
+			fmt.Sprintf("ECS agent on container instances is older than %s and does not report image digests, upgrade the agent to report them", MinDigestAgentVersion),
+			"instanceCount", len(outdated),
+			"instances", outdated,
+		)
+	}
+}
+
+// agentUpgrades returns the container instances whose ECS agent is older than MinDigestAgentVersion, along with the
+// number of containers on each that are missing an image digest
+func agentUpgrades(report reporter.Report, instances map[string]reporter.ContainerInstance) []reporter.AgentUpgrade {
+	instanceForTask := map[string]string{}
+	for _, task := range report.Tasks {
+		if task.ContainerInstance != nil {
+			instanceForTask[task.ARN] = task.ContainerInstance.ARN
+		}
+	}
+	missingDigests := map[string]int{}
+	for _, container := range report.Containers {
+		if container.ImageDigest == "" {
+			missingDigests[instanceForTask[container.TaskARN]]++
+		}
+	}
+
+	upgrades := []reporter.AgentUpgrade{}
+	for arn, instance := range instances {
+		if versionBefore(instance.AgentVersion, MinDigestAgentVersion) {
+			upgrades = append(upgrades, reporter.AgentUpgrade{
+				ContainerInstance:       instance,
+				ContainersMissingDigest: missingDigests[arn],
+			})
+		}
+	}
+	sort.Slice(upgrades, func(i, j int) bool {
+		return upgrades[i].ARN < upgrades[j].ARN
+	})
+	return upgrades
+}
+
+// versionBefore reports whether a version (e.g. an ECS agent version "1.68.2") is older than the minimum version.
+// Versions that can't be parsed are not reported as older, since there is nothing to act on.
+func versionBefore(version, minimum string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	m, ok := parseVersion(minimum)
+	if !ok {
+		return false
+	}
+	for i := range v {
+		if v[i] != m[i] {
+			return v[i] < m[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		// ignore any pre-release or build suffix, e.g. "1.70.0-beta"
+		part, _, _ = strings.Cut(part, "-")
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
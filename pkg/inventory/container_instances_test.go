@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_fetchContainerInstances(t *testing.T) {
+	arns := []string{}
+	for i := 0; i < 150; i++ {
+		arns = append(arns, fmt.Sprintf("container-instance-%d", i))
+	}
+	client := &mockECSClient{}
+
+	instances, err := fetchContainerInstances(context.Background(), client, "cluster-1", arns)
+
+	require.NoError(t, err)
+	assert.Len(t, instances, 150)
+	// container instances are described 100 at a time
+	assert.Equal(t, 2, client.DescribeContainerInstancesCalls)
+}
+
+func Test_fetchContainerInstancesMetadata(t *testing.T) {
+	instances, err := fetchContainerInstances(context.Background(), &mockECSClient{}, "cluster-1", []string{"container-instance-1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]reporter.ContainerInstance{
+		"container-instance-1": {
+			ARN:             "container-instance-1",
+			EC2InstanceID:   "i-0123456789abcdef0",
+			AgentVersion:    "1.80.0",
+			DockerVersion:   "DockerVersion: 20.10.25",
+			AMIID:           "ami-0123456789abcdef0",
+			CPUArchitecture: "x86_64",
+			OSFamily:        "LINUX",
+		},
+	}, instances)
+}
+
+func Test_addContainerInstances(t *testing.T) {
+	report := reporter.Report{
+		ClusterARN: "cluster-1",
+		Tasks: []reporter.Task{
+			{ARN: "task-1", ContainerInstance: &reporter.ContainerInstance{ARN: "old"}},
+			{ARN: "task-2", ContainerInstance: &reporter.ContainerInstance{ARN: "new"}},
+			{ARN: "task-3", ContainerInstance: &reporter.ContainerInstance{ARN: "old"}},
+			// fargate
+			{ARN: "task-4"},
+		},
+		Containers: []reporter.Container{
+			{ARN: "container-1", TaskARN: "task-1"},
+			{ARN: "container-2", TaskARN: "task-1", ImageDigest: "sha256:abc"},
+			{ARN: "container-3", TaskARN: "task-3"},
+			{ARN: "container-4", TaskARN: "task-4"},
+		},
+	}
+	client := &mockECSClient{AgentVersions: map[string]string{"old": "1.68.2", "new": "1.70.0"}}
+
+	addContainerInstances(context.Background(), client, &report)
+
+	assert.Equal(t, 1, client.DescribeContainerInstancesCalls)
+	assert.Equal(t, "1.68.2", report.Tasks[0].ContainerInstance.AgentVersion)
+	assert.Equal(t, "1.70.0", report.Tasks[1].ContainerInstance.AgentVersion)
+	assert.Nil(t, report.Tasks[3].ContainerInstance)
+	assert.Equal(t, []reporter.AgentUpgrade{
+		{
+			ContainerInstance: reporter.ContainerInstance{
+				ARN:             "old",
+				EC2InstanceID:   "i-0123456789abcdef0",
+				AgentVersion:    "1.68.2",
+				DockerVersion:   "DockerVersion: 20.10.25",
+				AMIID:           "ami-0123456789abcdef0",
+				CPUArchitecture: "x86_64",
+				OSFamily:        "LINUX",
+			},
+			ContainersMissingDigest: 2,
+		},
+	}, report.AgentUpgrades)
+}
+
+func Test_addContainerInstancesIgnoresErrors(t *testing.T) {
+	report := reporter.Report{
+		ClusterARN: "cluster-1",
+		Tasks:      []reporter.Task{{ARN: "task-1", ContainerInstance: &reporter.ContainerInstance{ARN: "instance-1"}}},
+	}
+
+	addContainerInstances(context.Background(), &mockECSClient{ErrorOnDescribeContainerInstances: true}, &report)
+
+	assert.Equal(t, &reporter.ContainerInstance{ARN: "instance-1"}, report.Tasks[0].ContainerInstance)
+	assert.Empty(t, report.AgentUpgrades)
+}
+
+func Test_versionBefore(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.68.2", want: true},
+		{version: "1.9.0", want: true},
+		{version: "0.99.99", want: true},
+		{version: "1.70.0", want: false},
+		{version: "1.70.0-beta", want: false},
+		{version: "v1.85.1", want: false},
+		{version: "2.0.0", want: false},
+		{version: "", want: false},
+		{version: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.want, versionBefore(tt.version, MinDigestAgentVersion))
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracker"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// sources of deployable images, see reporter.DeployableImage
+const (
+	DeployableSourceTaskDefinition = "task-definition"
+	DeployableSourceScheduledTask  = "scheduled-task"
+)
+
+// deployableImages are the images declared by the task definitions of a region, whether or not any task is running
+// them
+type deployableImages struct {
+	// families are the images of the latest revision of every ACTIVE task definition family, by family. Task
+	// definitions aren't tied to a cluster, see forCluster for the clusters they are reported with.
+	families map[string][]reporter.DeployableImage
+	// scheduled are the images of the tasks run by EventBridge rules, by the ARN of the cluster they run on
+	scheduled map[string][]reporter.DeployableImage
+
+	mu sync.Mutex
+	// run are the families run by a service of any cluster of the region
+	run map[string]bool
+}
+
+func newDeployableImages() *deployableImages {
+	return &deployableImages{
+		families:  map[string][]reporter.DeployableImage{},
+		scheduled: map[string][]reporter.DeployableImage{},
+		run:       map[string]bool{},
+	}
+}
+
+// addServices records the families run by the services of a cluster. It is called for every cluster of the region
+// before forCluster is called for any of them, and is safe to call for clusters concurrently.
+func (d *deployableImages) addServices(report reporter.Report) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for family := range serviceFamilies(report) {
+		d.run[family] = true
+	}
+}
+
+// forCluster returns the images that can be deployed to a cluster: those of the tasks scheduled on it, those of the
+// latest revision of each family its services run (in any deployment or task set), which the services move to when
+// they are next updated, and those of the families that no service of the region runs. The latter are run by RunTask,
+// Step Functions, Batch and the like, which can target any cluster, so they are reported with every cluster.
+func (d *deployableImages) forCluster(report reporter.Report) []reporter.DeployableImage {
+	if d == nil {
+		return nil
+	}
+	families := serviceFamilies(report)
+	images := append([]reporter.DeployableImage{}, d.scheduled[report.ClusterARN]...)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, family := range sortedKeys(d.families) {
+		if families[family] || !d.run[family] {
+			images = append(images, d.families[family]...)
+		}
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	return images
+}
+
+// serviceFamilies returns the task definition families the services of a cluster run
+func serviceFamilies(report reporter.Report) map[string]bool {
+	families := map[string]bool{}
+	for _, service := range report.Services {
+		for _, arn := range serviceTaskDefinitions(service) {
+			family, _ := splitTaskDefinition(arn)
+			families[family] = true
+		}
+	}
+	return families
+}
+
+// serviceTaskDefinitions returns the task definitions a service runs, in any of its deployments or task sets
+func serviceTaskDefinitions(service reporter.Service) []string {
+	arns := []string{service.TaskDefARN}
+	for _, deployment := range service.Deployments {
+		arns = append(arns, deployment.TaskDefARN)
+	}
+	for _, taskSet := range service.TaskSets {
+		arns = append(arns, taskSet.TaskDefARN)
+	}
+	return arns
+}
+
+// fetchDeployableImages finds the latest ACTIVE revision of each task definition family of the region and the ECS
+// tasks targeted by EventBridge rules, and returns the images their task definitions declare. Whatever could be
+// fetched is returned: a listing that fails is logged and leaves out the images it would have found, and a task
+// definition that can't be found or described leaves out just its own images.
+func fetchDeployableImages(ctx context.Context, client ECSAPI, events EventBridgeAPI) *deployableImages {
+	defer tracker.TrackFunctionTime(time.Now(), "Fetching deployable images")
+	log := logger.FromContext(ctx)
+	deployable := newDeployableImages()
+
+	families, err := fetchTaskDefinitionFamilies(ctx, client)
+	if err != nil {
+		log.Warn("Unable to list task definition families, the images of task definition families will not be reported as deployable", "err", err)
+	} else {
+		familyTaskDefinitions.retain(families)
+	}
+	definitions := newLatestTaskDefinitions(client)
+	for _, family := range families {
+		definitions.resolveLatest(ctx, family)
+	}
+	for _, family := range sortedKeys(definitions.latest) {
+		definition, ok := definitions.get(ctx, definitions.latest[family])
+		if !ok {
+			continue
+		}
+		deployable.families[family] = declaredImages(definition, DeployableSourceTaskDefinition, "")
+	}
+
+	targets, err := fetchScheduledTasks(ctx, events)
+	if err != nil {
+		log.Warn("Unable to list EventBridge rules, the images of scheduled tasks will not be reported as deployable", "err", err)
+	}
+	for _, target := range targets {
+		definition, ok := definitions.get(ctx, target.taskDefinition)
+		if !ok {
+			continue
+		}
+		deployable.scheduled[target.cluster] = append(deployable.scheduled[target.cluster], declaredImages(definition, DeployableSourceScheduledTask, target.ruleARN)...)
+	}
+
+	definitions.log(ctx)
+	log.Debug("Found deployable images", "taskDefinitionFamilies", len(families), "scheduledTasks", len(targets))
+	return deployable
+}
+
+// fetchTaskDefinitionFamilies returns the task definition families that have an ACTIVE revision
+func fetchTaskDefinitionFamilies(ctx context.Context, client ECSAPI) ([]string, error) {
+	var families []string
+	input := &ecs.ListTaskDefinitionFamiliesInput{Status: ecstypes.TaskDefinitionFamilyStatusActive}
+	for {
+		result, err := client.ListTaskDefinitionFamilies(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, result.Families...)
+		if aws.ToString(result.NextToken) == "" {
+			return families, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// fetchLatestTaskDefinition returns the ARN of the latest ACTIVE revision of a family, or an empty ARN if the family
+// no longer has one. Unlike for ListTaskDefinitionFamilies, the family prefix of ListTaskDefinitions is the full family
+// name, and revisions are listed newest first.
+func fetchLatestTaskDefinition(ctx context.Context, client ECSAPI, family string) (string, error) {
+	result, err := client.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
+		FamilyPrefix: aws.String(family),
+		Status:       ecstypes.TaskDefinitionStatusActive,
+		Sort:         ecstypes.SortOrderDesc,
+		MaxResults:   aws.Int32(1),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, arn := range result.TaskDefinitionArns {
+		if listed, _ := splitTaskDefinition(arn); listed == family {
+			return arn, nil
+		}
+	}
+	return "", nil
+}
+
+// splitTaskDefinition returns the family and revision of a task definition given by family, family:revision or ARN.
+// The revision is empty when the latest revision is meant.
+func splitTaskDefinition(name string) (string, string) {
+	if _, after, ok := strings.Cut(name, ":task-definition/"); ok {
+		name = after
+	}
+	family, revision, _ := strings.Cut(name, ":")
+	return family, revision
+}
+
+// scheduledTask is an ECS task run by an EventBridge rule
+type scheduledTask struct {
+	ruleARN string
+	cluster string
+	// taskDefinition is the task definition ARN the rule runs, it has no revision when the rule runs the latest
+	// revision of the family
+	taskDefinition string
+}
+
+// fetchScheduledTasks returns the ECS tasks targeted by the enabled rules on the default event bus, which is where
+// scheduled tasks created through ECS are kept
+func fetchScheduledTasks(ctx context.Context, client EventBridgeAPI) ([]scheduledTask, error) {
+	var tasks []scheduledTask
+	input := &eventbridge.ListRulesInput{}
+	for {
+		result, err := client.ListRules(ctx, input)
+		if err != nil {
+			return tasks, err
+		}
+		for _, rule := range result.Rules {
+			if rule.State == eventbridgetypes.RuleStateDisabled {
+				continue
+			}
+			targets, err := fetchRuleTasks(ctx, client, rule)
+			if err != nil {
+				return tasks, err
+			}
+			tasks = append(tasks, targets...)
+		}
+		if aws.ToString(result.NextToken) == "" {
+			return tasks, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+func fetchRuleTasks(ctx context.Context, client EventBridgeAPI, rule eventbridgetypes.Rule) ([]scheduledTask, error) {
+	var tasks []scheduledTask
+	input := &eventbridge.ListTargetsByRuleInput{Rule: rule.Name, EventBusName: rule.EventBusName}
+	for {
+		result, err := client.ListTargetsByRule(ctx, input)
+		if err != nil {
+			return tasks, fmt.Errorf("unable to list targets of rule %s: %w", aws.ToString(rule.Name), err)
+		}
+		for _, target := range result.Targets {
+			// the target of an ECS task is the cluster it runs on
+			if target.EcsParameters == nil {
+				continue
+			}
+			tasks = append(tasks, scheduledTask{
+				ruleARN:        aws.ToString(rule.Arn),
+				cluster:        aws.ToString(target.Arn),
+				taskDefinition: aws.ToString(target.EcsParameters.TaskDefinitionArn),
+			})
+		}
+		if aws.ToString(result.NextToken) == "" {
+			return tasks, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// declaredImages returns the image of each container in a task definition
+func declaredImages(definition *ecstypes.TaskDefinition, source, ruleARN string) []reporter.DeployableImage {
+	images := make([]reporter.DeployableImage, 0, len(definition.ContainerDefinitions))
+	for _, container := range definition.ContainerDefinitions {
+		if aws.ToString(container.Image) == "" {
+			continue
+		}
+		images = append(images, reporter.DeployableImage{
+			Image:      aws.ToString(container.Image),
+			TaskDefARN: aws.ToString(definition.TaskDefinitionArn),
+			Container:  aws.ToString(container.Name),
+			Source:     source,
+			RuleARN:    ruleARN,
+		})
+	}
+	return images
+}
+
+// familyRevisionRefreshInterval is how often the latest revision of a family is listed again, the default reconcile
+// interval of daemon mode. A new revision is reported as deployable at most this long after it is registered.
+const familyRevisionRefreshInterval = time.Hour
+
+// familyTaskDefinitionCache holds the latest revision of each task definition family described so far, by family, so
+// that a family is only described again once a newer revision is registered
+type familyTaskDefinitionCache struct {
+	mu          sync.Mutex
+	definitions map[string]*ecstypes.TaskDefinition
+	// revisions are the ARN of the latest revision of each family, as last listed
+	revisions map[string]listedRevision
+	now       func() time.Time
+}
+
+// listedRevision is the latest revision of a family, an empty ARN when the family had none, and when it was listed
+type listedRevision struct {
+	arn      string
+	listedAt time.Time
+}
+
+func newFamilyTaskDefinitionCache() *familyTaskDefinitionCache {
+	return &familyTaskDefinitionCache{
+		definitions: map[string]*ecstypes.TaskDefinition{},
+		revisions:   map[string]listedRevision{},
+		now:         time.Now,
+	}
+}
+
+// familyTaskDefinitions is kept across polling cycles, most families don't get a new revision between two cycles.
+// Families that no longer have an ACTIVE revision are dropped each cycle, and like the task definition cache it is
+// emptied when it is full.
+var familyTaskDefinitions = newFamilyTaskDefinitionCache()
+
+// latest returns the ARN of the latest ACTIVE revision of a family, listing it again only once it was last listed
+// longer ago than familyRevisionRefreshInterval, rather than once per family every polling cycle
+func (c *familyTaskDefinitionCache) latest(ctx context.Context, client ECSAPI, family string) (string, error) {
+	now := c.now()
+	c.mu.Lock()
+	revision, ok := c.revisions[family]
+	c.mu.Unlock()
+	if ok && now.Sub(revision.listedAt) < familyRevisionRefreshInterval {
+		return revision.arn, nil
+	}
+
+	arn, err := fetchLatestTaskDefinition(ctx, client, family)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.revisions[family]; !ok && len(c.revisions) >= maxCachedTaskDefinitions {
+		c.revisions = map[string]listedRevision{}
+	}
+	c.revisions[family] = listedRevision{arn: arn, listedAt: now}
+	return arn, nil
+}
+
+// get returns the task definition with the given ARN, the latest revision of family, describing it unless it is the
+// revision already cached for the family
+func (c *familyTaskDefinitionCache) get(ctx context.Context, client ECSAPI, family, arn string) (*ecstypes.TaskDefinition, error) {
+	c.mu.Lock()
+	definition, ok := c.definitions[family]
+	c.mu.Unlock()
+	if ok && aws.ToString(definition.TaskDefinitionArn) == arn {
+		return definition, nil
+	}
+
+	definition, err := describeTaskDefinition(ctx, client, arn)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.definitions[family]; !ok && len(c.definitions) >= maxCachedTaskDefinitions {
+		c.definitions = map[string]*ecstypes.TaskDefinition{}
+	}
+	c.definitions[family] = definition
+	return definition, nil
+}
+
+// retain drops the families that no longer have an ACTIVE revision
+func (c *familyTaskDefinitionCache) retain(families []string) {
+	active := make(map[string]bool, len(families))
+	for _, family := range families {
+		active[family] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for family := range c.definitions {
+		if !active[family] {
+			delete(c.definitions, family)
+		}
+	}
+	for family := range c.revisions {
+		if !active[family] {
+			delete(c.revisions, family)
+		}
+	}
+}
+
+// latestTaskDefinitions describes task definitions by family, or by ARN with or without a revision, for a single
+// polling cycle. A family (or an ARN without a revision) resolves to its latest ACTIVE revision as last listed, see
+// familyRevisionRefreshInterval. The latest revision of each family is kept across polling cycles in familyTaskDefinitions, and other
+// revisions in the shared task definition cache, since a revision can't change once registered.
+type latestTaskDefinitions struct {
+	client ECSAPI
+	// latest is the ARN of the latest revision of each family
+	latest      map[string]string
+	definitions map[string]*ecstypes.TaskDefinition
+	failed      map[string]error
+}
+
+func newLatestTaskDefinitions(client ECSAPI) *latestTaskDefinitions {
+	return &latestTaskDefinitions{
+		client:      client,
+		latest:      map[string]string{},
+		definitions: map[string]*ecstypes.TaskDefinition{},
+		failed:      map[string]error{},
+	}
+}
+
+// resolveLatest finds the latest ACTIVE revision of a family, a family whose latest revision can't be found is
+// recorded as failed
+func (l *latestTaskDefinitions) resolveLatest(ctx context.Context, family string) {
+	arn, err := familyTaskDefinitions.latest(ctx, l.client, family)
+	switch {
+	case err != nil:
+		l.failed[family] = err
+	case arn != "":
+		l.latest[family] = arn
+	}
+}
+
+func (l *latestTaskDefinitions) get(ctx context.Context, name string) (*ecstypes.TaskDefinition, bool) {
+	family, revision := splitTaskDefinition(name)
+	if arn, ok := l.latest[family]; ok && revision == "" {
+		name = arn
+	}
+	if definition, ok := l.definitions[name]; ok {
+		return definition, true
+	}
+	if _, ok := l.failed[name]; ok || name == "" {
+		return nil, false
+	}
+
+	var definition *ecstypes.TaskDefinition
+	var err error
+	switch {
+	case l.latest[family] == name:
+		definition, err = familyTaskDefinitions.get(ctx, l.client, family, name)
+	case revision != "":
+		definition, err = taskDefinitions.get(ctx, l.client, name)
+	default:
+		// the family wasn't listed, so its latest revision isn't known
+		definition, err = describeTaskDefinition(ctx, l.client, name)
+	}
+	if err != nil {
+		l.failed[name] = err
+		return nil, false
+	}
+	l.definitions[name] = definition
+	return definition, true
+}
+
+func (l *latestTaskDefinitions) log(ctx context.Context) {
+	if len(l.failed) == 0 {
+		return
+	}
+	names := sortedKeys(l.failed)
+	logger.FromContext(ctx).Warn(
+		"Unable to find or describe task definitions, their images will not be reported as deployable",
+		"taskDefinitionCount", len(names),
+		"taskDefinitions", names,
+		"err", l.failed[names[0]],
+	)
+}
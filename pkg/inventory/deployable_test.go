@@ -0,0 +1,300 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+const (
+	cluster1ARN = "arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"
+	cluster2ARN = "arn:aws:ecs:us-east-1:123456789012:cluster/cluster-2"
+)
+
+// mockEventBridgeClient returns the rules over two pages: a nightly rule running a task on cluster-1, a disabled
+// rule, and a rule that targets a Lambda function rather than an ECS task
+type mockEventBridgeClient struct {
+	ErrorOnListRules         bool
+	ErrorOnListTargetsByRule bool
+}
+
+func (m *mockEventBridgeClient) ListRules(_ context.Context, input *eventbridge.ListRulesInput, _ ...func(*eventbridge.Options)) (*eventbridge.ListRulesOutput, error) {
+	if m.ErrorOnListRules {
+		return nil, errors.New("list rules error")
+	}
+	if input.NextToken == nil {
+		return &eventbridge.ListRulesOutput{
+			Rules: []eventbridgetypes.Rule{
+				{Name: aws.String("nightly"), Arn: aws.String("arn:aws:events:us-east-1:123456789012:rule/nightly"), State: eventbridgetypes.RuleStateEnabled},
+				{Name: aws.String("disabled"), Arn: aws.String("arn:aws:events:us-east-1:123456789012:rule/disabled"), State: eventbridgetypes.RuleStateDisabled},
+			},
+			NextToken: aws.String("page-2"),
+		}, nil
+	}
+	return &eventbridge.ListRulesOutput{
+		Rules: []eventbridgetypes.Rule{
+			{Name: aws.String("lambda"), Arn: aws.String("arn:aws:events:us-east-1:123456789012:rule/lambda"), State: eventbridgetypes.RuleStateEnabled},
+		},
+	}, nil
+}
+
+func (m *mockEventBridgeClient) ListTargetsByRule(_ context.Context, input *eventbridge.ListTargetsByRuleInput, _ ...func(*eventbridge.Options)) (*eventbridge.ListTargetsByRuleOutput, error) {
+	if m.ErrorOnListTargetsByRule {
+		return nil, errors.New("list targets by rule error")
+	}
+	switch aws.ToString(input.Rule) {
+	case "nightly":
+		return &eventbridge.ListTargetsByRuleOutput{
+			Targets: []eventbridgetypes.Target{
+				{
+					Arn: aws.String(cluster1ARN),
+					EcsParameters: &eventbridgetypes.EcsParameters{
+						TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/nightly-job"),
+					},
+				},
+			},
+		}, nil
+	case "disabled":
+		return &eventbridge.ListTargetsByRuleOutput{
+			Targets: []eventbridgetypes.Target{
+				{
+					Arn: aws.String(cluster2ARN),
+					EcsParameters: &eventbridgetypes.EcsParameters{
+						TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/disabled-job:1"),
+					},
+				},
+			},
+		}, nil
+	default:
+		return &eventbridge.ListTargetsByRuleOutput{
+			Targets: []eventbridgetypes.Target{
+				{Arn: aws.String("arn:aws:lambda:us-east-1:123456789012:function:cleanup")},
+			},
+		}, nil
+	}
+}
+
+// useFamilyTaskDefinitionCache replaces the cache of the latest revision of each family with an empty one for the
+// duration of the test
+func useFamilyTaskDefinitionCache(t *testing.T) {
+	previous := familyTaskDefinitions
+	familyTaskDefinitions = newFamilyTaskDefinitionCache()
+	t.Cleanup(func() {
+		familyTaskDefinitions = previous
+	})
+}
+
+func Test_fetchDeployableImages(t *testing.T) {
+	useFamilyTaskDefinitionCache(t)
+	deployable := fetchDeployableImages(context.Background(), &mockECSClient{}, &mockEventBridgeClient{})
+
+	// only the latest revision of each family is reported
+	assert.Equal(t, map[string][]reporter.DeployableImage{
+		"task-definition-1": {
+			{
+				Image:      "image-1",
+				TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:2",
+				Container:  "container-1",
+				Source:     DeployableSourceTaskDefinition,
+			},
+		},
+		"task-definition-2": {
+			{
+				Image:      "image-1",
+				TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-2:1",
+				Container:  "container-1",
+				Source:     DeployableSourceTaskDefinition,
+			},
+		},
+	}, deployable.families)
+	assert.Equal(t, map[string][]reporter.DeployableImage{
+		cluster1ARN: {
+			{
+				Image:      "image-1",
+				TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/nightly-job",
+				Container:  "container-1",
+				Source:     DeployableSourceScheduledTask,
+				RuleARN:    "arn:aws:events:us-east-1:123456789012:rule/nightly",
+			},
+		},
+	}, deployable.scheduled)
+}
+
+func Test_fetchDeployableImagesCachesFamilies(t *testing.T) {
+	useFamilyTaskDefinitionCache(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	familyTaskDefinitions.now = func() time.Time { return now }
+	client := &mockECSClient{}
+	fetchDeployableImages(context.Background(), client, &mockEventBridgeClient{})
+	// only the latest revision of each family is listed, rather than every revision in the region
+	assert.Equal(t, 2, client.ListTaskDefinitionsCalls)
+	client.DescribedTaskDefinitions = nil
+
+	// the latest revisions are not listed again until the refresh interval has passed, and the families are not
+	// described again while their latest revision is the same. The scheduled task runs the latest revision of a family
+	// that isn't listed so it can't be told whether it changed.
+	now = now.Add(familyRevisionRefreshInterval / 2)
+	fetchDeployableImages(context.Background(), client, &mockEventBridgeClient{})
+	assert.Equal(t, 2, client.ListTaskDefinitionsCalls)
+	assert.Equal(t, []string{"arn:aws:ecs:us-east-1:123456789012:task-definition/nightly-job"}, client.DescribedTaskDefinitions)
+	client.DescribedTaskDefinitions = nil
+
+	// a family that is no longer listed is dropped, and a new revision is only found once the refresh interval has
+	// passed
+	client.TaskDefinitionPages = [][]string{{"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:3"}}
+	deployable := fetchDeployableImages(context.Background(), client, &mockEventBridgeClient{})
+	assert.Equal(t, 2, client.ListTaskDefinitionsCalls)
+	assert.Equal(t, "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:2", deployable.families["task-definition-1"][0].TaskDefARN)
+	assert.Len(t, deployable.families, 1)
+	assert.Equal(t, []string{"task-definition-1"}, sortedKeys(familyTaskDefinitions.definitions))
+	assert.Equal(t, []string{"task-definition-1"}, sortedKeys(familyTaskDefinitions.revisions))
+	client.DescribedTaskDefinitions = nil
+
+	now = now.Add(familyRevisionRefreshInterval)
+	deployable = fetchDeployableImages(context.Background(), client, &mockEventBridgeClient{})
+	assert.Equal(t, 3, client.ListTaskDefinitionsCalls)
+	assert.Equal(t, []string{
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:3",
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/nightly-job",
+	}, client.DescribedTaskDefinitions)
+	assert.Equal(t, "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:3", deployable.families["task-definition-1"][0].TaskDefARN)
+}
+
+func Test_fetchDeployableImagesIgnoresErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		client        ECSAPI
+		events        EventBridgeAPI
+		wantFamilies  int
+		wantScheduled int
+		wantWarnings  []string
+	}{
+		{
+			name:          "list task definition families error",
+			client:        &mockECSClient{ErrorOnListTaskDefinitionFamilies: true},
+			events:        &mockEventBridgeClient{},
+			wantScheduled: 1,
+			wantWarnings:  []string{"Unable to list task definition families, the images of task definition families will not be reported as deployable"},
+		},
+		{
+			name:          "list task definitions error",
+			client:        &mockECSClient{ErrorOnListTaskDefinitions: true},
+			events:        &mockEventBridgeClient{},
+			wantScheduled: 1,
+			wantWarnings:  []string{"Unable to find or describe task definitions, their images will not be reported as deployable"},
+		},
+		{
+			name:         "list rules error",
+			client:       &mockECSClient{},
+			events:       &mockEventBridgeClient{ErrorOnListRules: true},
+			wantFamilies: 2,
+			wantWarnings: []string{"Unable to list EventBridge rules, the images of scheduled tasks will not be reported as deployable"},
+		},
+		{
+			name:         "list targets by rule error",
+			client:       &mockECSClient{},
+			events:       &mockEventBridgeClient{ErrorOnListTargetsByRule: true},
+			wantFamilies: 2,
+			wantWarnings: []string{"Unable to list EventBridge rules, the images of scheduled tasks will not be reported as deployable"},
+		},
+		{
+			name: "describe task definition error",
+			// the task definition families are listed over two pages
+			client: &mockECSClient{
+				TaskDefinitionPages: [][]string{
+					{"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:1"},
+					{"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-2:1"},
+				},
+				ErrorOnDescribeTaskDefinitionOf: "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-2:1",
+			},
+			events:        &mockEventBridgeClient{},
+			wantFamilies:  1,
+			wantScheduled: 1,
+			wantWarnings:  []string{"Unable to find or describe task definitions, their images will not be reported as deployable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFamilyTaskDefinitionCache(t)
+			log := &recordingLogger{}
+			ctx := logger.NewContext(context.Background(), log)
+
+			deployable := fetchDeployableImages(ctx, tt.client, tt.events)
+
+			assert.Len(t, deployable.families, tt.wantFamilies)
+			assert.Len(t, deployable.scheduled[cluster1ARN], tt.wantScheduled)
+			assert.Equal(t, tt.wantWarnings, log.warnings)
+		})
+	}
+}
+
+func Test_deployableImagesForCluster(t *testing.T) {
+	web := reporter.DeployableImage{Image: "web:2", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3", Container: "web", Source: DeployableSourceTaskDefinition}
+	worker := reporter.DeployableImage{Image: "worker:1", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/worker:1", Container: "worker", Source: DeployableSourceTaskDefinition}
+	scheduled := reporter.DeployableImage{Image: "job:1", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/nightly-job:1", Container: "job", Source: DeployableSourceScheduledTask, RuleARN: "rule"}
+	unused := reporter.DeployableImage{Image: "unused:1", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/unused:1", Container: "unused", Source: DeployableSourceTaskDefinition}
+	deployable := newDeployableImages()
+	deployable.families = map[string][]reporter.DeployableImage{"web": {web}, "worker": {worker}, "unused": {unused}}
+	deployable.scheduled = map[string][]reporter.DeployableImage{cluster1ARN: {scheduled}}
+
+	// the images of a family are reported with the clusters whose services run it, in any deployment
+	cluster1 := reporter.Report{
+		ClusterARN: cluster1ARN,
+		Services: []reporter.Service{
+			{
+				ARN:        "web",
+				TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2",
+				Deployments: []reporter.Deployment{
+					{ID: "ecs-svc/1", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2"},
+					{ID: "ecs-svc/2", TaskDefARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/worker:1"},
+				},
+			},
+			{ARN: unknown},
+		},
+	}
+	cluster2 := reporter.Report{
+		ClusterARN: cluster2ARN,
+		Services:   []reporter.Service{{ARN: "worker", TaskSets: []reporter.TaskSet{{ID: "ecs-svc/3", TaskDefARN: "worker:1"}}}},
+	}
+	deployable.addServices(cluster1)
+	deployable.addServices(cluster2)
+
+	// families that no service runs, such as those run by RunTask, are reported with every cluster
+	assert.Equal(t, []reporter.DeployableImage{scheduled, unused, web, worker}, deployable.forCluster(cluster1))
+	assert.Equal(t, []reporter.DeployableImage{unused, worker}, deployable.forCluster(cluster2))
+	assert.Equal(t, []reporter.DeployableImage{unused}, deployable.forCluster(reporter.Report{ClusterARN: "arn:aws:ecs:us-east-1:123456789012:cluster/empty"}))
+
+	// nil when deployable images are not collected
+	var notCollected *deployableImages
+	notCollected.addServices(cluster1)
+	assert.Nil(t, notCollected.forCluster(cluster1))
+}
+
+func Test_splitTaskDefinition(t *testing.T) {
+	tests := []struct {
+		name         string
+		wantFamily   string
+		wantRevision string
+	}{
+		{name: "web", wantFamily: "web"},
+		{name: "web:3", wantFamily: "web", wantRevision: "3"},
+		{name: "arn:aws:ecs:us-east-1:123456789012:task-definition/web", wantFamily: "web"},
+		{name: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3", wantFamily: "web", wantRevision: "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			family, revision := splitTaskDefinition(tt.name)
+			assert.Equal(t, tt.wantFamily, family)
+			assert.Equal(t, tt.wantRevision, revision)
+		})
+	}
+}
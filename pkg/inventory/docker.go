@@ -0,0 +1,106 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dockerImage is the part of the response of the image inspect endpoint of the Docker Engine API that is used
+type dockerImage struct {
+	// RepoDigests are the repository and digest of each registry the image was pulled from, e.g.
+	// "nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// newDockerClient returns a client of the Docker Engine API listening on the unix socket at path
+func newDockerClient(path string) *http.Client {
+	return &http.Client{
+		Timeout: introspectionTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+// resolveImageDigests returns the repository digest of the image of each container that doesn't reference its image
+// by digest, by image ID. The ECS agent only has the ID of the image it pulled, which identifies its configuration
+// rather than its manifest, so the digest is found by inspecting the image with the Docker daemon on the socket at
+// path. Images that were not pulled from a registry have no digest, and images that can't be inspected are left out;
+// the error of the first one is returned along with the digests that could be resolved.
+func resolveImageDigests(ctx context.Context, path string, tasks []agentTask) (map[string]string, error) {
+	digests := map[string]string{}
+	if path == "" {
+		return digests, nil
+	}
+	client := newDockerClient(path)
+	var firstErr error
+	for _, task := range tasks {
+		for _, container := range task.Containers {
+			if strings.Contains(container.Image, "@") || container.ImageID == "" {
+				continue
+			}
+			if _, ok := digests[container.ImageID]; ok {
+				continue
+			}
+			image, err := inspectDockerImage(ctx, client, container.ImageID)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			digests[container.ImageID] = repoDigest(container.Image, image.RepoDigests)
+		}
+	}
+	return digests, firstErr
+}
+
+func inspectDockerImage(ctx context.Context, client *http.Client, imageID string) (dockerImage, error) {
+	// the host is ignored, requests are sent to the socket
+	endpoint := "http://docker/images/" + url.PathEscape(imageID) + "/json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return dockerImage{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return dockerImage{}, fmt.Errorf("unable to reach the Docker daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dockerImage{}, fmt.Errorf("unexpected status inspecting image %s with the Docker daemon: %s", imageID, resp.Status)
+	}
+	var image dockerImage
+	if err := json.NewDecoder(resp.Body).Decode(&image); err != nil {
+		return dockerImage{}, fmt.Errorf("unable to parse the inspection of image %s by the Docker daemon: %w", imageID, err)
+	}
+	return image, nil
+}
+
+// repoDigest returns the digest of the repository an image reference was pulled from, an image can have a digest for
+// each repository it was pulled from under another name. An image with a single repository digest is taken to be from
+// that repository, as Docker names images of Docker Hub differently than they may be referenced.
+func repoDigest(image string, repoDigests []string) string {
+	repository := image
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	for _, repoDigest := range repoDigests {
+		if repo, digest, ok := strings.Cut(repoDigest, "@"); ok && repo == repository {
+			return digest
+		}
+	}
+	if len(repoDigests) == 1 {
+		_, digest, _ := strings.Cut(repoDigests[0], "@")
+		return digest
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nginxDigest = "sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+
+// newDockerServer serves the Docker Engine API on a unix socket, knowing the image of the nginx container of
+// agentTasksResponse, and returns the path of the socket
+func newDockerServer(t *testing.T) string {
+	// the path of a unix socket is limited to about a hundred characters, too short for some test directories
+	dir, err := os.MkdirTemp("", "docker")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	path := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6/json":
+			_, _ = w.Write([]byte(`{"Id": "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6", "RepoDigests": ["nginx@` + nginxDigest + `"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return path
+}
+
+func Test_resolveImageDigests(t *testing.T) {
+	path := newDockerServer(t)
+	tasks := []agentTask{
+		{Containers: []agentContainer{
+			{Image: "nginx:1.25", ImageID: "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6"},
+			// images referenced by digest are not inspected
+			{Image: "sidecar@sha256:1234", ImageID: "sha256:5b4a3c2d1e0f"},
+			{Image: "unknown:1", ImageID: "sha256:unknown"},
+		}},
+	}
+
+	digests, err := resolveImageDigests(context.Background(), path, tasks)
+	assert.ErrorContains(t, err, "unexpected status inspecting image sha256:unknown with the Docker daemon")
+	assert.Equal(t, map[string]string{"sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6": nginxDigest}, digests)
+
+	_, err = resolveImageDigests(context.Background(), filepath.Join(t.TempDir(), "missing.sock"), tasks)
+	assert.ErrorContains(t, err, "unable to reach the Docker daemon")
+}
+
+func Test_repoDigest(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		repoDigests []string
+		want        string
+	}{
+		{
+			name:        "single repository",
+			image:       "docker.io/library/nginx:1.25",
+			repoDigests: []string{"nginx@sha256:1111"},
+			want:        "sha256:1111",
+		},
+		{
+			name:        "repository of the reference",
+			image:       "localhost:5000/team/web:2",
+			repoDigests: []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com/web@sha256:1111", "localhost:5000/team/web@sha256:2222"},
+			want:        "sha256:2222",
+		},
+		{
+			name:        "untagged reference",
+			image:       "localhost:5000/team/web",
+			repoDigests: []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com/web@sha256:1111", "localhost:5000/team/web@sha256:2222"},
+			want:        "sha256:2222",
+		},
+		{
+			name:        "ambiguous",
+			image:       "web:2",
+			repoDigests: []string{"a/web@sha256:1111", "b/web@sha256:2222"},
+		},
+		{
+			name:  "built locally",
+			image: "web:2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, repoDigest(tt.image, tt.repoDigests))
+		})
+	}
+}
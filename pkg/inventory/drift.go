@@ -0,0 +1,134 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// driftGroup is the set of tasks whose containers are expected to run the same digest of an image: the tasks of a
+// service, or the tasks of a task definition that were not started by a service
+type driftGroup struct {
+	serviceARN string
+	taskDefARN string
+	image      string
+}
+
+// imageDrift returns the image references that the tasks of a service (or of a task definition) run more than one
+// digest of, which happens when a mutable tag is pushed again and tasks started at different times pull different
+// images. The digest of the most recently started task is taken as current, and the tasks running any other digest
+// are stale.
+func imageDrift(report reporter.Report) []reporter.ImageDrift {
+	tasks := map[string]reporter.Task{}
+	for _, task := range report.Tasks {
+		// stopped tasks no longer run their images
+		if !task.Stopped {
+			tasks[task.ARN] = task
+		}
+	}
+
+	// the tasks running each digest of each image, by group
+	groups := map[driftGroup]map[string]map[string]bool{}
+	for _, container := range report.Containers {
+		// images referenced by digest are pinned, so can't drift
+		if container.ImageDigest == "" || strings.HasSuffix(container.ImageTag, ":"+unknown) {
+			continue
+		}
+		task, ok := tasks[container.TaskARN]
+		if !ok {
+			continue
+		}
+		group := driftGroup{serviceARN: task.ServiceARN, image: container.ImageTag}
+		if group.serviceARN == "" {
+			group.taskDefARN = task.TaskDefARN
+		}
+		if groups[group] == nil {
+			groups[group] = map[string]map[string]bool{}
+		}
+		if groups[group][container.ImageDigest] == nil {
+			groups[group][container.ImageDigest] = map[string]bool{}
+		}
+		groups[group][container.ImageDigest][task.ARN] = true
+	}
+
+	drift := []reporter.ImageDrift{}
+	for group, digests := range groups {
+		if len(digests) < 2 {
+			continue
+		}
+		d := reporter.ImageDrift{
+			ServiceARN:    group.serviceARN,
+			TaskDefARN:    group.taskDefARN,
+			Image:         group.image,
+			CurrentDigest: currentDigest(digests, tasks),
+		}
+		for _, digest := range sortedKeys(digests) {
+			digestTasks := sortedKeys(digests[digest])
+			d.Digests = append(d.Digests, reporter.DigestTasks{Digest: digest, Tasks: digestTasks})
+			if digest != d.CurrentDigest {
+				d.StaleTasks = append(d.StaleTasks, digestTasks...)
+			}
+		}
+		sort.Strings(d.StaleTasks)
+		drift = append(drift, d)
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].ServiceARN != drift[j].ServiceARN {
+			return drift[i].ServiceARN < drift[j].ServiceARN
+		}
+		if drift[i].TaskDefARN != drift[j].TaskDefARN {
+			return drift[i].TaskDefARN < drift[j].TaskDefARN
+		}
+		return drift[i].Image < drift[j].Image
+	})
+	return drift
+}
+
+// currentDigest returns the digest run by the most recently started task. If the start times are not known, the digest
+// run by the most tasks is current.
+func currentDigest(digests map[string]map[string]bool, tasks map[string]reporter.Task) string {
+	var current, latestStart string
+	currentTasks := 0
+	for _, digest := range sortedKeys(digests) {
+		for taskARN := range digests[digest] {
+			// RFC3339 timestamps in UTC sort in time order
+			if startedAt := tasks[taskARN].StartedAt; startedAt > latestStart {
+				latestStart = startedAt
+				current = digest
+			}
+		}
+		if latestStart == "" && len(digests[digest]) > currentTasks {
+			current = digest
+			currentTasks = len(digests[digest])
+		}
+	}
+	return current
+}
+
+// warnImageDrift logs a single warning for the cluster naming the services (and task definitions) whose tasks run
+// more than one digest of the same image
+func warnImageDrift(ctx context.Context, drift []reporter.ImageDrift) {
+	if len(drift) == 0 {
+		return
+	}
+	drifted := make([]string, 0, len(drift))
+	staleTasks := 0
+	for _, d := range drift {
+		owner := d.ServiceARN
+		if owner == "" {
+			owner = d.TaskDefARN
+		}
+		drifted = append(drifted, fmt.Sprintf("%s (%s)", owner, d.Image))
+		staleTasks += len(d.StaleTasks)
+	}
+	logger.FromContext(ctx).Warn(
+		"Tasks are running more than one digest of the same image, the image tag was likely pushed again after they started, restart the stale tasks to run the current image",
+		"imageCount", len(drift),
+		"images", drifted,
+		"staleTaskCount", staleTasks,
+	)
+}
@@ -0,0 +1,91 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_imageDrift(t *testing.T) {
+	report := reporter.Report{
+		Tasks: []reporter.Task{
+			{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1", StartedAt: "2024-01-01T00:00:00Z"},
+			{ARN: "task-2", ServiceARN: "service-1", TaskDefARN: "web:1", StartedAt: "2024-01-01T00:00:00Z"},
+			{ARN: "task-3", ServiceARN: "service-1", TaskDefARN: "web:1", StartedAt: "2024-01-02T00:00:00Z"},
+			{ARN: "task-4", ServiceARN: "service-2", TaskDefARN: "api:1", StartedAt: "2024-01-01T00:00:00Z"},
+			{ARN: "task-5", ServiceARN: "service-2", TaskDefARN: "api:1", StartedAt: "2024-01-01T00:00:00Z"},
+			{ARN: "task-6", TaskDefARN: "batch:1"},
+			{ARN: "task-7", TaskDefARN: "batch:1"},
+			{ARN: "task-8", TaskDefARN: "batch:1"},
+		},
+		Containers: []reporter.Container{
+			// service-1 drifted when nginx:latest was pushed again, task-3 started since
+			{TaskARN: "task-1", ImageTag: "nginx:latest", ImageDigest: "sha256:old"},
+			{TaskARN: "task-2", ImageTag: "nginx:latest", ImageDigest: "sha256:old"},
+			{TaskARN: "task-3", ImageTag: "nginx:latest", ImageDigest: "sha256:new"},
+			// the sidecar has not drifted
+			{TaskARN: "task-1", ImageTag: "envoy:v1", ImageDigest: "sha256:envoy"},
+			{TaskARN: "task-3", ImageTag: "envoy:v1", ImageDigest: "sha256:envoy"},
+			// service-2 has no drift
+			{TaskARN: "task-4", ImageTag: "api:v2", ImageDigest: "sha256:api"},
+			{TaskARN: "task-5", ImageTag: "api:v2", ImageDigest: "sha256:api"},
+			// tasks not started by a service drift by task definition, without start times the most run digest is current
+			{TaskARN: "task-6", ImageTag: "batch:latest", ImageDigest: "sha256:b1"},
+			{TaskARN: "task-7", ImageTag: "batch:latest", ImageDigest: "sha256:b2"},
+			{TaskARN: "task-8", ImageTag: "batch:latest", ImageDigest: "sha256:b2"},
+			// images referenced by digest, or without a digest, can't drift
+			{TaskARN: "task-4", ImageTag: "worker:UNKNOWN", ImageDigest: "sha256:w1"},
+			{TaskARN: "task-5", ImageTag: "worker:UNKNOWN", ImageDigest: "sha256:w2"},
+			{TaskARN: "task-4", ImageTag: "old-agent:v1"},
+			{TaskARN: "task-5", ImageTag: "old-agent:v1"},
+		},
+	}
+
+	assert.Equal(t, []reporter.ImageDrift{
+		{
+			TaskDefARN:    "batch:1",
+			Image:         "batch:latest",
+			CurrentDigest: "sha256:b2",
+			Digests: []reporter.DigestTasks{
+				{Digest: "sha256:b1", Tasks: []string{"task-6"}},
+				{Digest: "sha256:b2", Tasks: []string{"task-7", "task-8"}},
+			},
+			StaleTasks: []string{"task-6"},
+		},
+		{
+			ServiceARN:    "service-1",
+			Image:         "nginx:latest",
+			CurrentDigest: "sha256:new",
+			Digests: []reporter.DigestTasks{
+				{Digest: "sha256:new", Tasks: []string{"task-3"}},
+				{Digest: "sha256:old", Tasks: []string{"task-1", "task-2"}},
+			},
+			StaleTasks: []string{"task-1", "task-2"},
+		},
+	}, imageDrift(report))
+}
+
+func Test_imageDriftNone(t *testing.T) {
+	report, err := GetInventoryReportForCluster(context.Background(), "cluster-1", &mockECSClient{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, report.ImageDrift)
+}
+
+func Test_warnImageDrift(t *testing.T) {
+	log := &recordingLogger{}
+	ctx := logger.NewContext(context.Background(), log)
+
+	warnImageDrift(ctx, nil)
+	assert.Empty(t, log.warnings)
+
+	warnImageDrift(ctx, []reporter.ImageDrift{
+		{ServiceARN: "service-1", Image: "nginx:latest", StaleTasks: []string{"task-1", "task-2"}},
+		{TaskDefARN: "batch:1", Image: "batch:latest", StaleTasks: []string{"task-6"}},
+	})
+	assert.Len(t, log.warnings, 1)
+}
@@ -3,20 +3,33 @@ package inventory
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 
 	"github.com/anchore/ecs-inventory/internal/logger"
 	"github.com/anchore/ecs-inventory/internal/tracker"
+	pkgLog "github.com/anchore/ecs-inventory/pkg/logger"
 	"github.com/anchore/ecs-inventory/pkg/reporter"
 )
 
 const unknown = "UNKNOWN"
 
+// LoadAWSConfig loads the AWS config from the environment, shared config files or the instance/task role, overriding
+// the region if one is given
+func LoadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{}
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
 // Check if AWS credentials are present in the loaded config
 func checkAWSCredentials(ctx context.Context, cfg aws.Config) error {
 	_, err := cfg.Credentials.Retrieve(ctx)
@@ -71,72 +84,151 @@ func fetchServicesFromCluster(ctx context.Context, client ECSAPI, cluster string
 
 func fetchContainersFromTasks(ctx context.Context, client ECSAPI, cluster string, tasks []string) ([]reporter.Container, error) {
 	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Fetching Containers from tasks for cluster: %s", cluster))
-	input := &ecs.DescribeTasksInput{
-		Cluster: aws.String(cluster),
-		Tasks:   tasks,
-	}
-
-	results, err := client.DescribeTasks(ctx, input)
+	results, err := describeTasks(ctx, client, cluster, tasks)
 	if err != nil {
 		return nil, err
 	}
-	containerTagMap := buildContainerTagMap(results.Tasks)
+	return containersFromTasks(ctx, results), nil
+}
+
+// containersFromTasks returns the containers of the tasks, logging a single warning for the containers that are
+// missing an image digest or tag
+func containersFromTasks(ctx context.Context, tasks []ecstypes.Task) []reporter.Container {
+	log := logger.FromContext(ctx)
+	warnings := newContainerWarnings()
+	containerTagMap := buildContainerTagMap(tasks)
 	containers := []reporter.Container{}
-	for _, task := range results.Tasks {
+	for _, task := range tasks {
+		taskARN := ""
+		if task.TaskArn != nil {
+			taskARN = *task.TaskArn
+		}
 		for _, container := range task.Containers {
+			containerARN := ""
+			if container.ContainerArn != nil {
+				containerARN = *container.ContainerArn
+			}
+
 			digest := ""
 			if container.ImageDigest != nil {
 				digest = *container.ImageDigest
 			} else {
-				if container.ContainerArn != nil {
-					logger.Log.Warnf("No image digest found for container: %s", *container.ContainerArn)
-				} else {
-					logger.Log.Warn("No image digest found for container (nil ARN)")
-				}
-				logger.Log.Warn("Ensure all ECS container hosts are running at least ECS Agent 1.70.0, which fixed a bug where image digests were not returned in the DescribeTasks API response.")
+				log.Debug("No image digest found for container", "container", containerARN, "task", taskARN)
+				warnings.missingDigest.add(taskARN)
 			}
-			containerImage := getContainerImageTag(containerTagMap, &container)
-			taskARN := ""
-			if task.TaskArn != nil {
-				taskARN = *task.TaskArn
-			}
-			containerARN := ""
-			if container.ContainerArn != nil {
-				containerARN = *container.ContainerArn
+			containerImage, found := getContainerImageTag(containerTagMap, &container)
+			if !found {
+				log.Debug("No image tag found for container, setting to UNKNOWN", "container", containerARN, "task", taskARN, "image", aws.ToString(container.Image))
+				warnings.unknownTag.add(taskARN)
 			}
 
-			containers = append(containers, reporter.Container{
+			c := reporter.Container{
 				ARN:         containerARN,
 				ImageTag:    containerImage,
 				ImageDigest: digest,
 				TaskARN:     taskARN,
-			})
+			}
+			addContainerLifecycle(&c, container)
+			containers = append(containers, c)
 		}
 	}
+	warnings.log(log)
 
-	return containers, nil
+	return containers
 }
 
-func getContainerImageTag(containerTagMap map[string]string, container *ecstypes.Container) string {
+// maxDescribeTasks is the most tasks that can be described in a single call
+const maxDescribeTasks = 100
+
+// describeTasks describes the tasks of a cluster, maxDescribeTasks at a time
+func describeTasks(ctx context.Context, client ECSAPI, cluster string, tasks []string) ([]ecstypes.Task, error) {
+	var described []ecstypes.Task
+	for start := 0; start < len(tasks); start += maxDescribeTasks {
+		end := min(start+maxDescribeTasks, len(tasks))
+		results, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   tasks[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		described = append(described, results.Tasks...)
+	}
+	return described, nil
+}
+
+// getContainerImageTag returns the image tag for the container, found is false if the container image was referenced
+// by digest and no other container in the cluster runs the same digest by tag, in which case the tag is UNKNOWN
+func getContainerImageTag(containerTagMap map[string]string, container *ecstypes.Container) (tag string, found bool) {
 	// Fix container image tag if it contains an @ symbol
 	if container.Image != nil && strings.Contains(*container.Image, "@") {
 		// replace the image tag with the correct one
 		if container.ImageDigest != nil {
 			if tag, ok := containerTagMap[*container.ImageDigest]; ok {
-				return tag
+				return tag, true
 			}
 		}
-		if container.Image != nil {
-			logger.Log.Warnf("No image tag found for container setting to UNKNOWN: %s", *container.Image)
-			parts := strings.Split(*container.Image, "@")
-			return parts[0] + ":UNKNOWN"
-		}
-		return unknown
+		parts := strings.Split(*container.Image, "@")
+		return parts[0] + ":UNKNOWN", false
 	}
 	if container.Image != nil {
-		return *container.Image
+		return *container.Image, true
+	}
+	return unknown, true
+}
+
+// taskCounts is the number of affected containers in each task
+type taskCounts map[string]int
+
+func (c taskCounts) add(taskARN string) {
+	c[taskARN]++
+}
+
+func (c taskCounts) containers() int {
+	total := 0
+	for _, count := range c {
+		total += count
+	}
+	return total
+}
+
+func (c taskCounts) tasks() []string {
+	tasks := make([]string, 0, len(c))
+	for task := range c {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	return tasks
+}
+
+// containerWarnings collects the problems found with the containers of a cluster, so that they are logged once for
+// every polling cycle rather than once for every container (the detail for each container is logged at debug level)
+type containerWarnings struct {
+	missingDigest taskCounts
+	unknownTag    taskCounts
+}
+
+func newContainerWarnings() containerWarnings {
+	return containerWarnings{missingDigest: taskCounts{}, unknownTag: taskCounts{}}
+}
+
+func (w containerWarnings) log(log pkgLog.Logger) {
+	if len(w.missingDigest) > 0 {
+		log.Warn(
+			"No image digest found for containers, ensure all ECS container hosts are running at least ECS Agent 1.70.0, which fixed a bug where image digests were not returned in the DescribeTasks API response",
+			"containerCount", w.missingDigest.containers(),
+			"taskCount", len(w.missingDigest),
+			"tasks", w.missingDigest.tasks(),
+		)
+	}
+	if len(w.unknownTag) > 0 {
+		log.Warn(
+			"No image tag found for containers referencing their image by digest, setting their tag to UNKNOWN",
+			"containerCount", w.unknownTag.containers(),
+			"taskCount", len(w.unknownTag),
+			"tasks", w.unknownTag.tasks(),
+		)
 	}
-	return unknown
 }
 
 // Build a map of container image digests to image tags
@@ -156,6 +248,15 @@ func buildContainerTagMap(tasks []ecstypes.Task) map[string]string {
 	return containerMap
 }
 
+// accountFromARN returns the AWS account ID from an ARN, or an empty string if the ARN can't be parsed
+func accountFromARN(arn string) string {
+	arnParts := strings.SplitN(arn, ":", 6)
+	if len(arnParts) != 6 {
+		return ""
+	}
+	return arnParts[4]
+}
+
 // Using the clusterARN and service name, construct the service ARN.
 // The DescribeTasks API does not return the service ARN only the service name.
 func constructServiceARN(clusterARN string, serviceName string) (string, error) {
@@ -179,59 +280,79 @@ func constructServiceARN(clusterARN string, serviceName string) (string, error)
 	return fmt.Sprintf("arn:aws:ecs:%s:%s:service/%s/%s", region, accountID, clusterName, serviceName), nil
 }
 
-func fetchTasksMetadata(ctx context.Context, client ECSAPI, cluster string, tasks []string) ([]reporter.Task, error) {
-	input := &ecs.DescribeTasksInput{
-		Cluster: aws.String(cluster),
-		Tasks:   tasks,
-	}
-
-	results, err := client.DescribeTasks(ctx, input)
+func fetchTasksMetadata(ctx context.Context, client ECSAPI, cluster string, tasks []string, definitions *taskDefinitionCollector) ([]reporter.Task, error) {
+	results, err := describeTasks(ctx, client, cluster, tasks)
 	if err != nil {
 		return nil, err
 	}
+	return tasksMetadata(ctx, client, results, definitions)
+}
 
+// tasksMetadata returns the metadata of described tasks
+func tasksMetadata(ctx context.Context, client ECSAPI, tasks []ecstypes.Task, definitions *taskDefinitionCollector) ([]reporter.Task, error) {
 	var tasksMetadata []reporter.Task
-	for _, task := range results.Tasks {
-		// Tags may not be present in the task response so we need to fetch them explicitly
-		taskARN := ""
-		if task.TaskArn != nil {
-			taskARN = *task.TaskArn
-		}
-		tagMap, err := fetchTagsForResource(ctx, client, taskARN)
+	for _, task := range tasks {
+		tMetadata, err := taskMetadata(ctx, client, task, definitions)
 		if err != nil {
 			return nil, err
 		}
+		tasksMetadata = append(tasksMetadata, tMetadata)
+	}
 
-		tMetadata := reporter.Task{
-			ARN:        taskARN,
-			TaskDefARN: "",
-			Tags:       tagMap,
-		}
-		if task.TaskDefinitionArn != nil {
-			tMetadata.TaskDefARN = *task.TaskDefinitionArn
-		}
+	return tasksMetadata, nil
+}
 
-		// Group may be nil
-		if task.Group != nil {
-			groupParts := strings.Split(*task.Group, ":")
-			if len(groupParts) != 2 {
-				return nil, fmt.Errorf("unable to parse task group: %s", *task.Group)
-			}
-			groupType := groupParts[0]
-			if groupType == "service" {
-				serviceName := groupParts[1]
-				serviceArn, err := constructServiceARN(*task.ClusterArn, serviceName)
-				if err != nil {
-					return nil, err
-				}
-				tMetadata.ServiceARN = serviceArn
+// taskMetadata returns the metadata of a described task, fetching its tags
+func taskMetadata(ctx context.Context, client ECSAPI, task ecstypes.Task, definitions *taskDefinitionCollector) (reporter.Task, error) {
+	// Tags may not be present in the task response so we need to fetch them explicitly
+	tagMap, err := fetchTagsForResource(ctx, client, aws.ToString(task.TaskArn))
+	if err != nil {
+		return reporter.Task{}, err
+	}
+	return taskMetadataWithTags(ctx, client, task, tagMap, definitions)
+}
+
+// taskMetadataWithTags returns the metadata of a task whose tags are already known
+func taskMetadataWithTags(
+	ctx context.Context,
+	client ECSAPI,
+	task ecstypes.Task,
+	tags map[string]string,
+	definitions *taskDefinitionCollector,
+) (reporter.Task, error) {
+	tMetadata := reporter.Task{
+		ARN:        aws.ToString(task.TaskArn),
+		TaskDefARN: "",
+		Tags:       tags,
+	}
+	if task.TaskDefinitionArn != nil {
+		tMetadata.TaskDefARN = *task.TaskDefinitionArn
+	}
+	if task.ContainerInstanceArn != nil {
+		tMetadata.ContainerInstance = &reporter.ContainerInstance{ARN: *task.ContainerInstanceArn}
+	}
+	addTaskPlacement(&tMetadata, task)
+	addTaskLifecycle(&tMetadata, task)
+	definitions.addTask(ctx, client, &tMetadata, task)
+
+	// Group may be nil
+	if task.Group != nil {
+		groupParts := strings.Split(*task.Group, ":")
+		if len(groupParts) != 2 {
+			return reporter.Task{}, fmt.Errorf("unable to parse task group: %s", *task.Group)
+		}
+		groupType := groupParts[0]
+		if groupType == "service" {
+			serviceName := groupParts[1]
+			serviceArn, err := constructServiceARN(*task.ClusterArn, serviceName)
+			if err != nil {
+				return reporter.Task{}, err
 			}
+			tMetadata.ServiceARN = serviceArn
 		}
-
-		tasksMetadata = append(tasksMetadata, tMetadata)
 	}
 
-	return tasksMetadata, nil
+	return tMetadata, nil
 }
 
 func fetchServicesMetadata(ctx context.Context, client ECSAPI, cluster string, services []string) ([]reporter.Service, error) {
@@ -257,10 +378,12 @@ func fetchServicesMetadata(ctx context.Context, client ECSAPI, cluster string, s
 			return nil, err
 		}
 
-		servicesMetadata = append(servicesMetadata, reporter.Service{
+		sMetadata := reporter.Service{
 			ARN:  serviceARN,
 			Tags: tagMap,
-		})
+		}
+		addServiceDetails(&sMetadata, service)
+		servicesMetadata = append(servicesMetadata, sMetadata)
 	}
 
 	return servicesMetadata, nil
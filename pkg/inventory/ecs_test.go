@@ -2,12 +2,17 @@ package inventory
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/anchore/ecs-inventory/internal/logger"
+	pkgLog "github.com/anchore/ecs-inventory/pkg/logger"
 	"github.com/anchore/ecs-inventory/pkg/reporter"
 )
 
@@ -262,7 +267,7 @@ func Test_fetchTasksMetadata(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := fetchTasksMetadata(context.Background(), tt.args.client, tt.args.cluster, tt.args.tasks)
+			got, err := fetchTasksMetadata(context.Background(), tt.args.client, tt.args.cluster, tt.args.tasks, nil)
 			if (err != nil) != tt.wantErr {
 				assert.Error(t, err)
 			}
@@ -441,6 +446,41 @@ func Test_fetchServicesMetadata(t *testing.T) {
 	}
 }
 
+// countingDescribeTasksECSClient counts the DescribeTasks calls, and the tasks described by each
+type countingDescribeTasksECSClient struct {
+	mockECSClient
+	calls []int
+}
+
+func (m *countingDescribeTasksECSClient) DescribeTasks(_ context.Context, input *ecs.DescribeTasksInput, _ ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	m.calls = append(m.calls, len(input.Tasks))
+	tasks := make([]ecstypes.Task, 0, len(input.Tasks))
+	for _, arn := range input.Tasks {
+		tasks = append(tasks, ecstypes.Task{TaskArn: aws.String(arn)})
+	}
+	return &ecs.DescribeTasksOutput{Tasks: tasks}, nil
+}
+
+func Test_describeTasks(t *testing.T) {
+	arns := []string{}
+	for i := 0; i < 250; i++ {
+		arns = append(arns, fmt.Sprintf("task-%d", i))
+	}
+	client := &countingDescribeTasksECSClient{}
+
+	tasks, err := describeTasks(context.Background(), client, "cluster-1", arns)
+
+	require.NoError(t, err)
+	assert.Len(t, tasks, 250)
+	// tasks are described 100 at a time
+	assert.Equal(t, []int{100, 100, 50}, client.calls)
+}
+
+func Test_accountFromARN(t *testing.T) {
+	assert.Equal(t, "123456789012", accountFromARN("arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"))
+	assert.Equal(t, "", accountFromARN("cluster-1"))
+}
+
 func Test_constructServiceARN(t *testing.T) {
 	type args struct {
 		clusterARN string
@@ -591,9 +631,10 @@ func Test_getContainerImageTag(t *testing.T) {
 		container       ecstypes.Container
 	}
 	tests := []struct {
-		name string
-		args args
-		want string
+		name      string
+		args      args
+		want      string
+		wantFound bool
 	}{
 		{
 			name: "return container image tag when it does not contain @ symbol",
@@ -607,7 +648,8 @@ func Test_getContainerImageTag(t *testing.T) {
 					ImageDigest: aws.String("sha256:1234567890123456789012345678901234567890123456789012345678901111"),
 				},
 			},
-			want: "image-1:latest",
+			want:      "image-1:latest",
+			wantFound: true,
 		},
 		{
 			name: "return container image tag from map when it does contain @ symbol",
@@ -621,7 +663,8 @@ func Test_getContainerImageTag(t *testing.T) {
 					ImageDigest: aws.String("sha256:1234567890123456789012345678901234567890123456789012345678901111"),
 				},
 			},
-			want: "image-1:latest",
+			want:      "image-1:latest",
+			wantFound: true,
 		},
 		{
 			name: "return UNKNOWN as the tag when image tag is not found in the map",
@@ -635,13 +678,85 @@ func Test_getContainerImageTag(t *testing.T) {
 					ImageDigest: aws.String("sha256:11"),
 				},
 			},
-			want: "image-1:UNKNOWN",
+			want:      "image-1:UNKNOWN",
+			wantFound: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getContainerImageTag(tt.args.containerTagMap, &tt.args.container)
+			got, found := getContainerImageTag(tt.args.containerTagMap, &tt.args.container)
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantFound, found)
 		})
 	}
 }
+
+// recordingLogger records the messages logged at warn and debug level
+type recordingLogger struct {
+	logger.NoOpLogger
+	warnings []string
+	infos    []string
+	debug    []string
+}
+
+func (l *recordingLogger) Info(msg string, _ ...interface{}) {
+	l.infos = append(l.infos, msg)
+}
+
+func (l *recordingLogger) Warn(msg string, _ ...interface{}) {
+	l.warnings = append(l.warnings, msg)
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) {
+	l.debug = append(l.debug, msg)
+}
+
+func (l *recordingLogger) With(...interface{}) pkgLog.Logger {
+	return l
+}
+
+// digestlessECSClient returns tasks whose containers reference their image by digest but have no digest recorded
+type digestlessECSClient struct {
+	mockECSClient
+}
+
+func (m *digestlessECSClient) DescribeTasks(_ context.Context, _ *ecs.DescribeTasksInput, _ ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	task := func(taskARN string, containers int) ecstypes.Task {
+		task := ecstypes.Task{TaskArn: aws.String(taskARN)}
+		for i := 0; i < containers; i++ {
+			task.Containers = append(task.Containers, ecstypes.Container{
+				ContainerArn: aws.String(fmt.Sprintf("%s/container-%d", taskARN, i)),
+				Image:        aws.String("image-1@sha256:1234"),
+			})
+		}
+		return task
+	}
+	return &ecs.DescribeTasksOutput{Tasks: []ecstypes.Task{task("task-1", 3), task("task-2", 2)}}, nil
+}
+
+func Test_fetchContainersFromTasksSummarizesWarnings(t *testing.T) {
+	log := &recordingLogger{}
+	ctx := logger.NewContext(context.Background(), log)
+
+	containers, err := fetchContainersFromTasks(ctx, &digestlessECSClient{}, "cluster-1", []string{"task-1", "task-2"})
+	require.NoError(t, err)
+	assert.Len(t, containers, 5)
+
+	// one summary of each problem for the cluster, rather than a warning for each container
+	assert.Len(t, log.warnings, 2)
+	assert.Len(t, log.debug, 10)
+}
+
+func Test_containerWarnings(t *testing.T) {
+	warnings := newContainerWarnings()
+	warnings.missingDigest.add("task-2")
+	warnings.missingDigest.add("task-1")
+	warnings.missingDigest.add("task-2")
+
+	assert.Equal(t, 3, warnings.missingDigest.containers())
+	assert.Equal(t, []string{"task-1", "task-2"}, warnings.missingDigest.tasks())
+
+	log := &recordingLogger{}
+	warnings.log(log)
+	assert.Len(t, log.warnings, 1)
+}
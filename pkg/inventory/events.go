@@ -0,0 +1,625 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/anchore/ecs-inventory/internal/health"
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/metrics"
+	"github.com/anchore/ecs-inventory/pkg/connection"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// detail types of the EventBridge events that are applied to the inventory
+const (
+	taskStateChangeEvent       = "ECS Task State Change"
+	deploymentStateChangeEvent = "ECS Deployment State Change"
+)
+
+const (
+	// maxReceiveMessages is the most messages that can be received from SQS in a single call
+	maxReceiveMessages = 10
+	// maxWaitTimeSeconds is the longest SQS can wait for a message to arrive before returning none
+	maxWaitTimeSeconds = 20
+)
+
+// EventOptions configures consuming ECS events from an SQS queue
+type EventOptions struct {
+	// QueueURL is the SQS queue that an EventBridge rule delivers the ECS events to
+	QueueURL string
+	// Endpoint is the URL of the SQS API, e.g. a local SQS compatible service, if empty the regional endpoint is used
+	Endpoint string
+	// FlushInterval is how long events are gathered for before the clusters they changed are reported
+	FlushInterval time.Duration
+	// ReconcileInterval is how often the whole region is polled, to pick up anything the events missed
+	ReconcileInterval time.Duration
+}
+
+// ConsumeEvents keeps an inventory of the region up to date from the ECS task and deployment state change events
+// delivered to an SQS queue, reporting the clusters that changed every flush interval. The region is polled in full
+// at the start, and then every reconcile interval. It runs until the context is cancelled.
+func ConsumeEvents(ctx context.Context, region string, anchoreDetails connection.AnchoreInfo, collect CollectOptions, opts EventOptions, quiet, dryRun bool) error {
+	log := logger.With(logger.FromContext(ctx), "region", region)
+	ctx = logger.NewContext(ctx, log)
+	log.Info("Consuming ECS events for region", "queue", opts.QueueURL)
+
+	cfg, err := LoadAWSConfig(ctx, region)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	if err := checkAWSCredentials(ctx, cfg); err != nil {
+		return err
+	}
+
+	ecsClient := ecs.NewFromConfig(cfg, func(o *ecs.Options) {
+		o.APIOptions = append(o.APIOptions, withMetrics, withTracing)
+	})
+	queue := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+	})
+	inventory := newEventInventory(region, ecsClient, queue, collect, opts)
+	if collect.DeployableImages {
+		inventory.events = eventbridge.NewFromConfig(cfg)
+	}
+	inventory.post = func(ctx context.Context, report reporter.Report) error {
+		return reportCluster(ctx, report, anchoreDetails, quiet, dryRun)
+	}
+	inventory.run(ctx)
+	return nil
+}
+
+// ecsEvent is an EventBridge event from ECS, as delivered to SQS
+type ecsEvent struct {
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Region     string          `json:"region"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// eventInventory is the inventory of a region, kept up to date by ECS events. It is only used from the goroutine
+// running it, so needs no locking.
+type eventInventory struct {
+	region  string
+	ecs     ECSAPI
+	queue   SQSAPI
+	events  EventBridgeAPI
+	collect CollectOptions
+	opts    EventOptions
+	// post reports the inventory of a cluster
+	post     func(ctx context.Context, report reporter.Report) error
+	now      func() time.Time
+	clusters map[string]*clusterInventory
+	// stoppedTasks records when the stopped tasks of each cluster were last listed
+	stoppedTasks *pollTimes
+
+	lastFlush     time.Time
+	lastReconcile time.Time
+}
+
+func newEventInventory(region string, ecsClient ECSAPI, queue SQSAPI, collect CollectOptions, opts EventOptions) *eventInventory {
+	return &eventInventory{
+		region:       region,
+		ecs:          ecsClient,
+		queue:        queue,
+		collect:      collect,
+		opts:         opts,
+		post:         func(context.Context, reporter.Report) error { return nil },
+		now:          time.Now,
+		clusters:     map[string]*clusterInventory{},
+		stoppedTasks: newPollTimes(),
+	}
+}
+
+// run reconciles the inventory, and then applies events as they arrive until the context is cancelled
+func (e *eventInventory) run(ctx context.Context) {
+	log := logger.FromContext(ctx)
+	e.reconcile(ctx)
+	for ctx.Err() == nil {
+		if err := e.receive(ctx); err != nil && ctx.Err() == nil {
+			log.Error("Failed to receive ECS events from the queue", err)
+			// wait before trying again, so an unreachable queue is not retried in a tight loop
+			select {
+			case <-ctx.Done():
+			case <-time.After(e.opts.FlushInterval):
+			}
+		}
+
+		now := e.now()
+		switch {
+		case now.Sub(e.lastReconcile) >= e.opts.ReconcileInterval:
+			e.reconcile(ctx)
+		case now.Sub(e.lastFlush) >= e.opts.FlushInterval:
+			e.flush(ctx)
+		}
+	}
+}
+
+// receive waits for a batch of events (no longer than the flush interval) and applies them. Events are deleted from
+// the queue once applied, an event that can't be applied is left on the queue to be received again once its visibility
+// timeout expires.
+func (e *eventInventory) receive(ctx context.Context) error {
+	result, err := e.queue.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(e.opts.QueueURL),
+		MaxNumberOfMessages: maxReceiveMessages,
+		WaitTimeSeconds:     int32(min(maxWaitTimeSeconds, int(e.opts.FlushInterval/time.Second))),
+	})
+	if err != nil {
+		return err
+	}
+	for _, message := range result.Messages {
+		e.handleMessage(ctx, message)
+	}
+	return nil
+}
+
+func (e *eventInventory) handleMessage(ctx context.Context, message sqstypes.Message) {
+	log := logger.With(logger.FromContext(ctx), "messageId", aws.ToString(message.MessageId))
+	var event ecsEvent
+	if err := json.Unmarshal([]byte(aws.ToString(message.Body)), &event); err != nil {
+		log.Warn("Ignoring message that is not an EventBridge event", "err", err)
+	} else if err := e.apply(ctx, event); err != nil {
+		metrics.EventFailures.WithLabelValues(event.DetailType).Inc()
+		log.Warn("Unable to apply ECS event, it will be retried", "type", event.DetailType, "err", err)
+		return
+	}
+
+	_, err := e.queue.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(e.opts.QueueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+	if err != nil {
+		log.Warn("Unable to delete ECS event from the queue, it will be applied again", "err", err)
+	}
+}
+
+// apply updates the inventory from an event. Events that are not for the region, or of other types, are ignored. An
+// error is only returned if applying the event could succeed if it is retried.
+func (e *eventInventory) apply(ctx context.Context, event ecsEvent) error {
+	log := logger.FromContext(ctx)
+	if event.Source != "aws.ecs" || (event.Region != "" && event.Region != e.region) {
+		log.Debug("Ignoring event that is not from ECS in the region", "source", event.Source, "eventRegion", event.Region)
+		return nil
+	}
+	metrics.Events.WithLabelValues(event.DetailType).Inc()
+	switch event.DetailType {
+	case taskStateChangeEvent:
+		return e.applyTaskEvent(ctx, event.Detail)
+	case deploymentStateChangeEvent:
+		return e.applyDeploymentEvent(ctx, event.Resources)
+	default:
+		log.Debug("Ignoring ECS event", "type", event.DetailType)
+		return nil
+	}
+}
+
+// taskStateChangeDetail is the detail of an ECS task state change event, with the fields of the task that are
+// reported. Unlike a task returned by DescribeTasks it never has the task's tags.
+type taskStateChangeDetail struct {
+	ClusterARN           string                       `json:"clusterArn"`
+	TaskARN              string                       `json:"taskArn"`
+	TaskDefinitionARN    string                       `json:"taskDefinitionArn"`
+	ContainerInstanceARN string                       `json:"containerInstanceArn"`
+	Group                string                       `json:"group"`
+	Version              int64                        `json:"version"`
+	LastStatus           string                       `json:"lastStatus"`
+	DesiredStatus        string                       `json:"desiredStatus"`
+	HealthStatus         string                       `json:"healthStatus"`
+	CreatedAt            *time.Time                   `json:"createdAt"`
+	StartedAt            *time.Time                   `json:"startedAt"`
+	StartedBy            string                       `json:"startedBy"`
+	StopCode             string                       `json:"stopCode"`
+	StoppedReason        string                       `json:"stoppedReason"`
+	StoppingAt           *time.Time                   `json:"stoppingAt"`
+	StoppedAt            *time.Time                   `json:"stoppedAt"`
+	LaunchType           string                       `json:"launchType"`
+	PlatformVersion      string                       `json:"platformVersion"`
+	PlatformFamily       string                       `json:"platformFamily"`
+	CapacityProviderName string                       `json:"capacityProviderName"`
+	AvailabilityZone     string                       `json:"availabilityZone"`
+	EnableExecuteCommand bool                         `json:"enableExecuteCommand"`
+	Attributes           []taskStateChangeAttribute   `json:"attributes"`
+	Containers           []containerStateChangeDetail `json:"containers"`
+}
+
+type taskStateChangeAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// containerStateChangeDetail is a container of a task state change event
+type containerStateChangeDetail struct {
+	ContainerARN string `json:"containerArn"`
+	Image        string `json:"image"`
+	ImageDigest  string `json:"imageDigest"`
+	LastStatus   string `json:"lastStatus"`
+	HealthStatus string `json:"healthStatus"`
+	RuntimeID    string `json:"runtimeId"`
+	ExitCode     *int32 `json:"exitCode"`
+}
+
+// task returns the task of the event as DescribeTasks would, so it is reported the same way as a polled task
+func (d taskStateChangeDetail) task() ecstypes.Task {
+	task := ecstypes.Task{
+		ClusterArn:           optionalString(d.ClusterARN),
+		TaskArn:              optionalString(d.TaskARN),
+		TaskDefinitionArn:    optionalString(d.TaskDefinitionARN),
+		ContainerInstanceArn: optionalString(d.ContainerInstanceARN),
+		Group:                optionalString(d.Group),
+		Version:              d.Version,
+		LastStatus:           optionalString(d.LastStatus),
+		DesiredStatus:        optionalString(d.DesiredStatus),
+		HealthStatus:         ecstypes.HealthStatus(d.HealthStatus),
+		CreatedAt:            d.CreatedAt,
+		StartedAt:            d.StartedAt,
+		StartedBy:            optionalString(d.StartedBy),
+		StopCode:             ecstypes.TaskStopCode(d.StopCode),
+		StoppedReason:        optionalString(d.StoppedReason),
+		StoppingAt:           d.StoppingAt,
+		StoppedAt:            d.StoppedAt,
+		LaunchType:           ecstypes.LaunchType(d.LaunchType),
+		PlatformVersion:      optionalString(d.PlatformVersion),
+		PlatformFamily:       optionalString(d.PlatformFamily),
+		CapacityProviderName: optionalString(d.CapacityProviderName),
+		AvailabilityZone:     optionalString(d.AvailabilityZone),
+		EnableExecuteCommand: d.EnableExecuteCommand,
+	}
+	for _, attribute := range d.Attributes {
+		task.Attributes = append(task.Attributes, ecstypes.Attribute{Name: aws.String(attribute.Name), Value: optionalString(attribute.Value)})
+	}
+	for _, container := range d.Containers {
+		task.Containers = append(task.Containers, ecstypes.Container{
+			ContainerArn: optionalString(container.ContainerARN),
+			TaskArn:      optionalString(d.TaskARN),
+			Image:        optionalString(container.Image),
+			ImageDigest:  optionalString(container.ImageDigest),
+			LastStatus:   optionalString(container.LastStatus),
+			HealthStatus: ecstypes.HealthStatus(container.HealthStatus),
+			RuntimeId:    optionalString(container.RuntimeID),
+			ExitCode:     container.ExitCode,
+		})
+	}
+	return task
+}
+
+// optionalString returns nil for an empty string, as the ECS API leaves out fields that are not set
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// applyTaskEvent records the state of the task in the event. Events don't carry the tags of the task, and listing
+// them for every event would be a call per state change, so a task keeps the tags it was last polled with, and a task
+// first seen in an event has none until the next reconcile.
+func (e *eventInventory) applyTaskEvent(ctx context.Context, raw json.RawMessage) error {
+	var detail taskStateChangeDetail
+	if err := json.Unmarshal(raw, &detail); err != nil {
+		logger.FromContext(ctx).Warn("Ignoring malformed ECS task state change event", "err", err)
+		return nil
+	}
+	clusterARN, taskARN := detail.ClusterARN, detail.TaskARN
+	if clusterARN == "" || taskARN == "" {
+		logger.FromContext(ctx).Warn("Ignoring ECS task state change event without a task or cluster ARN")
+		return nil
+	}
+	ctx = logger.NewContext(ctx, logger.With(logger.FromContext(ctx), "cluster", clusterARN, "task", taskARN))
+
+	cluster := e.cluster(ctx, clusterARN)
+	var tags map[string]string
+	if tracked, ok := cluster.tasks[taskARN]; ok {
+		// events can be delivered out of order, the version of a task is incremented each time its state changes
+		if detail.Version < tracked.version {
+			return nil
+		}
+		tags = tracked.task.Tags
+	}
+	task := detail.task()
+	t, err := taskMetadataWithTags(ctx, e.ecs, task, tags, newTaskDefinitionCollector(e.collect))
+	if err != nil {
+		// only the group of the task can't be parsed, which a retry won't change
+		logger.FromContext(ctx).Warn("Ignoring malformed ECS task state change event", "err", err)
+		return nil
+	}
+	cluster.addContainerInstance(&t)
+	containers := cluster.resolveImageTags(containersFromTasks(ctx, []ecstypes.Task{task}))
+	cluster.tasks[taskARN] = trackedTask{task: t, containers: containers, version: detail.Version}
+	cluster.changed = true
+	return nil
+}
+
+// applyDeploymentEvent describes the service whose deployment changed state, so the report has its current
+// deployments
+func (e *eventInventory) applyDeploymentEvent(ctx context.Context, resources []string) error {
+	for _, serviceARN := range resources {
+		clusterARN, ok := clusterARNFromServiceARN(serviceARN)
+		if !ok {
+			logger.FromContext(ctx).Warn("Ignoring ECS deployment state change event for a service ARN without its cluster, it will be picked up by the next reconcile", "service", serviceARN)
+			continue
+		}
+		ctx := logger.NewContext(ctx, logger.With(logger.FromContext(ctx), "cluster", clusterARN, "service", serviceARN))
+		services, err := fetchServicesMetadata(ctx, e.ecs, clusterARN, []string{serviceARN})
+		if err != nil {
+			return err
+		}
+		cluster := e.cluster(ctx, clusterARN)
+		cluster.setService(serviceARN, services)
+		cluster.changed = true
+	}
+	return nil
+}
+
+// cluster returns the inventory of a cluster, starting one for a cluster created since the last reconcile
+func (e *eventInventory) cluster(ctx context.Context, clusterARN string) *clusterInventory {
+	cluster, ok := e.clusters[clusterARN]
+	if !ok {
+		report := reporter.Report{ClusterARN: clusterARN}
+		addClusterMetadata(ctx, e.ecs, &report)
+		cluster = newClusterInventory(report)
+		e.clusters[clusterARN] = cluster
+	}
+	return cluster
+}
+
+// flush reports the clusters that changed since they were last reported. A cluster that fails to report is tried
+// again at the next flush.
+func (e *eventInventory) flush(ctx context.Context) {
+	now := e.now()
+	e.lastFlush = now
+	for _, arn := range sortedKeys(e.clusters) {
+		cluster := e.clusters[arn]
+		if !cluster.changed {
+			continue
+		}
+		ctx := logger.NewContext(ctx, logger.With(logger.FromContext(ctx), "cluster", arn, "account", accountFromARN(arn)))
+		report := ensureReferencedObjectsExist(ctx, cluster.current(now))
+		recordClusterMetrics(report)
+		if err := e.post(ctx, report); err != nil {
+			continue
+		}
+		cluster.changed = false
+		cluster.removeStopped()
+	}
+}
+
+// reconcile polls every cluster in the region, replacing the inventory built from events, and reports them all
+func (e *eventInventory) reconcile(ctx context.Context) {
+	start := e.now()
+	e.lastReconcile = start
+	health.Default.CycleStarted(e.region, e.opts.ReconcileInterval)
+	err := e.reconcileClusters(ctx)
+	health.Default.CycleFinished(err)
+	metrics.ObserveCycle(start, err)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to reconcile the inventory for region", err)
+	}
+	e.flush(ctx)
+}
+
+// reconcileClusters polls every cluster in the region. A cluster that can't be polled keeps its previous inventory, and
+// fails the reconcile so that it is counted as failed by the health checks and metrics, as a polling cycle is.
+func (e *eventInventory) reconcileClusters(ctx context.Context) error {
+	clusters, err := fetchClusters(ctx, e.ecs)
+	if err != nil {
+		return err
+	}
+	metrics.SetClusters(e.region, clusters)
+
+	var deployable *deployableImages
+	if e.collect.DeployableImages && e.events != nil {
+		deployable = fetchDeployableImages(ctx, e.ecs, e.events)
+	}
+
+	reports := make([]*reporter.Report, len(clusters))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log := logger.With(logger.FromContext(ctx), "cluster", cluster, "account", accountFromARN(cluster))
+			ctx := logger.NewContext(ctx, log)
+
+			status := health.ClusterStatus{ClusterARN: cluster}
+			defer func() {
+				health.Default.ClusterFinished(status)
+			}()
+
+			report, err := inventoryReportForCluster(ctx, cluster, e.ecs, e.collect, e.stoppedTasks)
+			if err != nil {
+				log.Error("Failed to get inventory report for cluster", err)
+				status.Error = err.Error()
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s: %w", cluster, err))
+				mu.Unlock()
+				return
+			}
+			deployable.addServices(report)
+			status.Tasks = len(report.Tasks)
+			status.Containers = len(report.Containers)
+			reports[i] = &report
+		}()
+	}
+	wg.Wait()
+
+	polled := make([]*clusterInventory, len(clusters))
+	for i, report := range reports {
+		if report != nil {
+			// the deployable images of a cluster depend on the services of the others, so are added once all are polled
+			report.DeployableImages = deployable.forCluster(*report)
+			polled[i] = newClusterInventory(*report)
+		}
+	}
+
+	current := make(map[string]*clusterInventory, len(clusters))
+	for i, cluster := range clusters {
+		previous, known := e.clusters[cluster]
+		switch {
+		case polled[i] != nil:
+			if known {
+				polled[i].carryOver(previous)
+			}
+			polled[i].changed = true
+			current[cluster] = polled[i]
+		case known:
+			// keep the inventory built from events for a cluster that could not be polled
+			current[cluster] = previous
+		}
+	}
+	e.clusters = current
+	return errors.Join(errs...)
+}
+
+// trackedTask is a task in the inventory and its containers, along with the version of the task they were recorded
+// from (0 when they were polled rather than from an event)
+type trackedTask struct {
+	task       reporter.Task
+	containers []reporter.Container
+	version    int64
+}
+
+// clusterInventory is the inventory of a cluster. The report holds everything but the tasks and containers, which are
+// updated individually by events.
+type clusterInventory struct {
+	report  reporter.Report
+	tasks   map[string]trackedTask
+	changed bool
+}
+
+func newClusterInventory(report reporter.Report) *clusterInventory {
+	cluster := &clusterInventory{tasks: map[string]trackedTask{}}
+	containers := map[string][]reporter.Container{}
+	for _, container := range report.Containers {
+		containers[container.TaskARN] = append(containers[container.TaskARN], container)
+	}
+	for _, task := range report.Tasks {
+		cluster.tasks[task.ARN] = trackedTask{task: task, containers: containers[task.ARN]}
+	}
+	report.Tasks, report.Containers = nil, nil
+	cluster.report = report
+	return cluster
+}
+
+// current returns the report for the cluster as it is now, ordered by task
+func (c *clusterInventory) current(now time.Time) reporter.Report {
+	report := c.report
+	report.Timestamp = now.UTC().Format(time.RFC3339)
+	for _, arn := range sortedKeys(c.tasks) {
+		report.Tasks = append(report.Tasks, c.tasks[arn].task)
+		report.Containers = append(report.Containers, c.tasks[arn].containers...)
+	}
+	addTaskDeployments(&report)
+	report.ImageDrift = imageDrift(report)
+	report.Findings = outdatedRevisionFindings(report, now)
+	return report
+}
+
+// removeStopped removes the tasks that have stopped once they have been reported, so a task that starts and stops
+// between two flushes is still reported once
+func (c *clusterInventory) removeStopped() {
+	for arn, tracked := range c.tasks {
+		if tracked.task.Stopped {
+			delete(c.tasks, arn)
+		}
+	}
+}
+
+// carryOver keeps what a poll can't know from the previous inventory of the cluster: the versions of the tasks events
+// were received for, so an older event still on the queue doesn't replace the polled state, and the tasks that stopped
+// since the previous inventory was last reported, which a poll no longer finds unless stopped tasks are collected
+func (c *clusterInventory) carryOver(previous *clusterInventory) {
+	for arn, tracked := range previous.tasks {
+		polled, ok := c.tasks[arn]
+		switch {
+		case ok:
+			polled.version = tracked.version
+			c.tasks[arn] = polled
+		case tracked.task.Stopped && previous.changed:
+			c.tasks[arn] = tracked
+		}
+	}
+}
+
+// addContainerInstance fills in the details of the container instance a task runs on from another task on the same
+// instance, the details are otherwise only known after the next reconcile
+func (c *clusterInventory) addContainerInstance(t *reporter.Task) {
+	if t.ContainerInstance == nil {
+		return
+	}
+	for _, tracked := range c.tasks {
+		instance := tracked.task.ContainerInstance
+		if instance == nil || instance.ARN != t.ContainerInstance.ARN || instance.AgentVersion == "" {
+			continue
+		}
+		known := *instance
+		t.ContainerInstance = &known
+		if t.CPUArchitecture == "" {
+			t.CPUArchitecture = known.CPUArchitecture
+		}
+		if t.OSFamily == "" {
+			t.OSFamily = known.OSFamily
+		}
+		return
+	}
+}
+
+// resolveImageTags replaces the UNKNOWN tag of containers referencing their image by digest with the tag of another
+// container in the cluster running the same digest, as is done for the containers of a poll
+func (c *clusterInventory) resolveImageTags(containers []reporter.Container) []reporter.Container {
+	tags := map[string]string{}
+	for _, tracked := range c.tasks {
+		for _, container := range tracked.containers {
+			if container.ImageDigest != "" && !strings.HasSuffix(container.ImageTag, ":"+unknown) {
+				tags[container.ImageDigest] = container.ImageTag
+			}
+		}
+	}
+	for i, container := range containers {
+		if tag, ok := tags[container.ImageDigest]; ok && strings.HasSuffix(container.ImageTag, ":"+unknown) {
+			containers[i].ImageTag = tag
+		}
+	}
+	return containers
+}
+
+// setService replaces a service with its current metadata, removing it if it no longer exists
+func (c *clusterInventory) setService(serviceARN string, described []reporter.Service) {
+	services := make([]reporter.Service, 0, len(c.report.Services)+len(described))
+	for _, service := range c.report.Services {
+		if service.ARN != serviceARN {
+			services = append(services, service)
+		}
+	}
+	c.report.Services = append(services, described...)
+}
+
+// clusterARNFromServiceARN returns the ARN of the cluster a service is in, which is only part of the service's ARN
+// in the long ARN format (arn:aws:ecs:region:account:service/cluster/service)
+func clusterARNFromServiceARN(serviceARN string) (string, bool) {
+	prefix, resource, ok := strings.Cut(serviceARN, ":service/")
+	if !ok {
+		return "", false
+	}
+	cluster, _, ok := strings.Cut(resource, "/")
+	if !ok || cluster == "" {
+		return "", false
+	}
+	return prefix + ":cluster/" + cluster, true
+}
@@ -0,0 +1,371 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+const (
+	queueURL       = "http://sqs.us-east-1.localhost/123456789012/ecs-events"
+	service1ARN    = "arn:aws:ecs:us-east-1:123456789012:service/cluster-1/service-1"
+	eventTaskARN   = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/from-event"
+	eventTaskImage = "nginx:1.25"
+)
+
+// taskStateChange returns an ECS task state change event, as EventBridge delivers it to SQS
+func taskStateChange(taskARN, lastStatus string, version int) string {
+	return fmt.Sprintf(`{
+  "version": "0",
+  "id": "3317b2af-7005-947d-b652-f55e762e571a",
+  "detail-type": "ECS Task State Change",
+  "source": "aws.ecs",
+  "account": "123456789012",
+  "time": "2024-01-02T03:04:05Z",
+  "region": "us-east-1",
+  "resources": ["%[1]s"],
+  "detail": {
+    "clusterArn": "%[2]s",
+    "taskArn": "%[1]s",
+    "taskDefinitionArn": "arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:1",
+    "group": "service:service-1",
+    "launchType": "FARGATE",
+    "platformVersion": "1.4.0",
+    "cpu": "256",
+    "memory": "512",
+    "createdAt": "2024-01-02T03:00:00.000Z",
+    "desiredStatus": "RUNNING",
+    "lastStatus": "%[3]s",
+    "version": %[4]d,
+    "containers": [
+      {
+        "containerArn": "%[1]s/web",
+        "name": "web",
+        "image": "%[5]s",
+        "imageDigest": "sha256:1234567890123456789012345678901234567890123456789012345678905555",
+        "lastStatus": "%[3]s",
+        "cpu": "0"
+      }
+    ]
+  }
+}`, taskARN, cluster1ARN, lastStatus, version, eventTaskImage)
+}
+
+func deploymentStateChange(serviceARN string) string {
+	return fmt.Sprintf(`{
+  "version": "0",
+  "detail-type": "ECS Deployment State Change",
+  "source": "aws.ecs",
+  "region": "us-east-1",
+  "resources": ["%s"],
+  "detail": {"eventType": "INFO", "eventName": "SERVICE_DEPLOYMENT_COMPLETED", "deploymentId": "ecs-svc/123"}
+}`, serviceARN)
+}
+
+// fakeSQS is a local SQS compatible endpoint, speaking the JSON protocol of the SQS API. Every message that hasn't
+// been deleted is returned each time messages are received.
+type fakeSQS struct {
+	mu       sync.Mutex
+	messages map[string]string
+	deleted  []string
+}
+
+func newFakeSQS(t *testing.T, bodies ...string) (*fakeSQS, *sqs.Client) {
+	fake := &fakeSQS{messages: map[string]string{}}
+	for i, body := range bodies {
+		fake.messages[fmt.Sprintf("receipt-%d", i)] = body
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := sqs.New(sqs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	return fake, client
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var input struct {
+		QueueURL      string `json:"QueueUrl"`
+		ReceiptHandle string
+	}
+	if err := json.Unmarshal(body, &input); err != nil || input.QueueURL != queueURL {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSQS.ReceiveMessage":
+		type message struct {
+			MessageID     string `json:"MessageId"`
+			ReceiptHandle string
+			Body          string
+		}
+		var messages []message
+		for _, receipt := range sortedKeys(f.messages) {
+			messages = append(messages, message{MessageID: "id-" + receipt, ReceiptHandle: receipt, Body: f.messages[receipt]})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Messages": messages})
+	case "AmazonSQS.DeleteMessage":
+		delete(f.messages, input.ReceiptHandle)
+		f.deleted = append(f.deleted, input.ReceiptHandle)
+		_, _ = w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func testEventInventory(client ECSAPI, queue SQSAPI) (*eventInventory, *[]reporter.Report) {
+	var posted []reporter.Report
+	inventory := newEventInventory("us-east-1", client, queue, CollectOptions{}, EventOptions{
+		QueueURL:          queueURL,
+		FlushInterval:     time.Second,
+		ReconcileInterval: time.Hour,
+	})
+	inventory.post = func(_ context.Context, report reporter.Report) error {
+		posted = append(posted, report)
+		return nil
+	}
+	inventory.now = func() time.Time { return previousPoll }
+	return inventory, &posted
+}
+
+func TestEventInventoryReceive(t *testing.T) {
+	fake, queue := newFakeSQS(t,
+		taskStateChange(eventTaskARN, "RUNNING", 2),
+		deploymentStateChange(service1ARN),
+		`not an event`,
+		strings.Replace(taskStateChange(eventTaskARN, "STOPPED", 3), `"region": "us-east-1"`, `"region": "eu-west-1"`, 1),
+	)
+	inventory, _ := testEventInventory(&mockECSClient{}, queue)
+
+	require.NoError(t, inventory.receive(context.Background()))
+
+	// every message is deleted, including those that are ignored
+	assert.ElementsMatch(t, []string{"receipt-0", "receipt-1", "receipt-2", "receipt-3"}, fake.deleted)
+	require.Contains(t, inventory.clusters, cluster1ARN)
+	cluster := inventory.clusters[cluster1ARN]
+	assert.True(t, cluster.changed)
+	require.Contains(t, cluster.tasks, eventTaskARN)
+	tracked := cluster.tasks[eventTaskARN]
+	assert.Equal(t, int64(2), tracked.version)
+	assert.False(t, tracked.task.Stopped)
+	assert.Equal(t, service1ARN, tracked.task.ServiceARN)
+	assert.Equal(t, "FARGATE", tracked.task.LaunchType)
+	assert.Equal(t, []reporter.Container{
+		{
+			ARN:         eventTaskARN + "/web",
+			ImageTag:    eventTaskImage,
+			ImageDigest: "sha256:1234567890123456789012345678901234567890123456789012345678905555",
+			TaskARN:     eventTaskARN,
+			LastStatus:  "RUNNING",
+		},
+	}, tracked.containers)
+	require.Len(t, cluster.report.Services, 1)
+	assert.Equal(t, service1ARN, cluster.report.Services[0].ARN)
+}
+
+func TestEventInventoryReceiveRetriesFailedEvents(t *testing.T) {
+	fake, queue := newFakeSQS(t, deploymentStateChange(service1ARN))
+	inventory, _ := testEventInventory(&mockECSClient{ErrorOnDescribeServices: true}, queue)
+	log := &recordingLogger{}
+	ctx := logger.NewContext(context.Background(), log)
+
+	require.NoError(t, inventory.receive(ctx))
+
+	// the event is left on the queue to be received again
+	assert.Empty(t, fake.deleted)
+	assert.Equal(t, []string{"Unable to apply ECS event, it will be retried"}, log.warnings)
+	assert.NotContains(t, inventory.clusters, cluster1ARN)
+}
+
+func Test_applyTaskEventKeepsPolledTags(t *testing.T) {
+	// listing tags fails, so the tags can only come from the poll
+	inventory, _ := testEventInventory(&mockECSClient{ErrorOnListTagsForResource: true}, nil)
+	cluster := inventory.cluster(context.Background(), cluster1ARN)
+	cluster.tasks[eventTaskARN] = trackedTask{task: reporter.Task{ARN: eventTaskARN, Tags: map[string]string{"team": "web"}}, version: 1}
+	apply := func(taskARN string) {
+		var event ecsEvent
+		require.NoError(t, json.Unmarshal([]byte(taskStateChange(taskARN, "RUNNING", 2)), &event))
+		require.NoError(t, inventory.apply(context.Background(), event))
+	}
+
+	apply(eventTaskARN)
+	apply(eventTaskARN + "-new")
+
+	assert.Equal(t, map[string]string{"team": "web"}, cluster.tasks[eventTaskARN].task.Tags)
+	assert.Equal(t, int64(2), cluster.tasks[eventTaskARN].version)
+	// a task first seen in an event has no tags until the next reconcile
+	assert.Empty(t, cluster.tasks[eventTaskARN+"-new"].task.Tags)
+}
+
+func TestEventInventoryReceiveError(t *testing.T) {
+	_, queue := newFakeSQS(t)
+	inventory, _ := testEventInventory(&mockECSClient{}, queue)
+	inventory.opts.QueueURL = "http://sqs.us-east-1.localhost/123456789012/unknown"
+
+	assert.Error(t, inventory.receive(context.Background()))
+}
+
+func Test_applyTaskEventIgnoresOlderVersions(t *testing.T) {
+	inventory, _ := testEventInventory(&mockECSClient{}, nil)
+	apply := func(lastStatus string, version int) {
+		var event ecsEvent
+		require.NoError(t, json.Unmarshal([]byte(taskStateChange(eventTaskARN, lastStatus, version)), &event))
+		require.NoError(t, inventory.apply(context.Background(), event))
+	}
+
+	apply("STOPPED", 5)
+	// delivered out of order
+	apply("RUNNING", 3)
+
+	tracked := inventory.clusters[cluster1ARN].tasks[eventTaskARN]
+	assert.Equal(t, int64(5), tracked.version)
+	assert.True(t, tracked.task.Stopped)
+}
+
+func TestEventInventoryFlush(t *testing.T) {
+	inventory, posted := testEventInventory(&mockECSClient{}, nil)
+	running := reporter.Task{ARN: runningTaskARN, TaskDefARN: "task-definition-1:1"}
+	stopped := reporter.Task{ARN: stoppedTaskARN, TaskDefARN: "batch-job:1", Stopped: true}
+	inventory.clusters = map[string]*clusterInventory{
+		cluster1ARN: {
+			report: reporter.Report{ClusterARN: cluster1ARN},
+			tasks: map[string]trackedTask{
+				stoppedTaskARN: {task: stopped, containers: []reporter.Container{{ARN: "job", TaskARN: stoppedTaskARN, ImageTag: "batch-job:latest"}}},
+				runningTaskARN: {task: running, containers: []reporter.Container{{ARN: "web", TaskARN: runningTaskARN, ImageTag: "image-1"}}},
+			},
+			changed: true,
+		},
+		cluster2ARN: {report: reporter.Report{ClusterARN: cluster2ARN}, tasks: map[string]trackedTask{}},
+	}
+
+	// a failed post is retried at the next flush
+	inventory.post = func(context.Context, reporter.Report) error { return errors.New("post error") }
+	inventory.flush(context.Background())
+	assert.True(t, inventory.clusters[cluster1ARN].changed)
+	assert.Len(t, inventory.clusters[cluster1ARN].tasks, 2)
+
+	inventory.post = func(_ context.Context, report reporter.Report) error {
+		*posted = append(*posted, report)
+		return nil
+	}
+	inventory.flush(context.Background())
+
+	// only the cluster that changed is reported, with its tasks in order
+	require.Len(t, *posted, 1)
+	report := (*posted)[0]
+	assert.Equal(t, cluster1ARN, report.ClusterARN)
+	assert.Equal(t, "2024-01-02T03:00:00Z", report.Timestamp)
+	assert.Equal(t, []reporter.Task{running, stopped}, report.Tasks)
+	assert.Equal(t, []string{"web", "job"}, []string{report.Containers[0].ARN, report.Containers[1].ARN})
+	// the stopped task has been reported, so is no longer tracked
+	assert.False(t, inventory.clusters[cluster1ARN].changed)
+	assert.Equal(t, []string{runningTaskARN}, sortedKeys(inventory.clusters[cluster1ARN].tasks))
+
+	inventory.flush(context.Background())
+	assert.Len(t, *posted, 1)
+}
+
+func TestEventInventoryReconcile(t *testing.T) {
+	inventory, posted := testEventInventory(&mockECSClient{}, nil)
+	stopped := trackedTask{task: reporter.Task{ARN: stoppedTaskARN, ServiceARN: service1ARN, TaskDefARN: "task-definition-1:1", Stopped: true}, version: 4}
+	inventory.clusters = map[string]*clusterInventory{
+		cluster1ARN: {
+			report: reporter.Report{ClusterARN: cluster1ARN},
+			tasks: map[string]trackedTask{
+				runningTaskARN: {task: reporter.Task{ARN: runningTaskARN}, version: 7},
+				stoppedTaskARN: stopped,
+			},
+			changed: true,
+		},
+		"arn:aws:ecs:us-east-1:123456789012:cluster/deleted": {tasks: map[string]trackedTask{}},
+	}
+
+	inventory.reconcile(context.Background())
+
+	assert.Equal(t, []string{cluster1ARN, cluster2ARN}, sortedKeys(inventory.clusters))
+	cluster := inventory.clusters[cluster1ARN]
+	// the polled task keeps the version of the last event
+	assert.Equal(t, int64(7), cluster.tasks[runningTaskARN].version)
+	assert.Len(t, cluster.report.Services, 2)
+	assert.Nil(t, cluster.report.Tasks)
+
+	// the stopped task that was never reported is reported along with the polled tasks, and then no longer tracked
+	require.Len(t, *posted, 2)
+	assert.Contains(t, (*posted)[0].Tasks, stopped.task)
+	assert.Len(t, (*posted)[0].Tasks, 3)
+	assert.Len(t, (*posted)[0].Containers, 4)
+	assert.NotContains(t, cluster.tasks, stoppedTaskARN)
+	assert.Equal(t, previousPoll, inventory.lastReconcile)
+}
+
+func TestEventInventoryReconcileKeepsClustersThatFail(t *testing.T) {
+	inventory, _ := testEventInventory(&mockECSClient{ErrorOnListTasks: true}, nil)
+	previous := &clusterInventory{report: reporter.Report{ClusterARN: cluster1ARN}, tasks: map[string]trackedTask{}}
+	inventory.clusters = map[string]*clusterInventory{cluster1ARN: previous}
+
+	err := inventory.reconcileClusters(context.Background())
+
+	// the reconcile fails, so it isn't counted as a successful cycle
+	assert.ErrorContains(t, err, "cluster "+cluster1ARN)
+	assert.ErrorContains(t, err, "cluster "+cluster2ARN)
+	assert.Equal(t, []string{cluster1ARN}, sortedKeys(inventory.clusters))
+	assert.Same(t, previous, inventory.clusters[cluster1ARN])
+}
+
+func Test_resolveImageTags(t *testing.T) {
+	cluster := &clusterInventory{tasks: map[string]trackedTask{
+		runningTaskARN: {containers: []reporter.Container{{ImageTag: "nginx:1.25", ImageDigest: "sha256:1111"}}},
+	}}
+
+	got := cluster.resolveImageTags([]reporter.Container{
+		{ImageTag: "nginx:UNKNOWN", ImageDigest: "sha256:1111"},
+		{ImageTag: "redis:UNKNOWN", ImageDigest: "sha256:2222"},
+	})
+
+	assert.Equal(t, []reporter.Container{
+		{ImageTag: "nginx:1.25", ImageDigest: "sha256:1111"},
+		{ImageTag: "redis:UNKNOWN", ImageDigest: "sha256:2222"},
+	}, got)
+}
+
+func Test_clusterARNFromServiceARN(t *testing.T) {
+	tests := []struct {
+		serviceARN string
+		want       string
+		wantOK     bool
+	}{
+		{serviceARN: service1ARN, want: cluster1ARN, wantOK: true},
+		// the short ARN format doesn't include the cluster
+		{serviceARN: "arn:aws:ecs:us-east-1:123456789012:service/service-1"},
+		{serviceARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serviceARN, func(t *testing.T) {
+			got, ok := clusterARNFromServiceARN(tt.serviceARN)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/internal/health"
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracker"
+	"github.com/anchore/ecs-inventory/pkg/connection"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// introspectionTimeout bounds each request to the ECS agent introspection API, which is served by the agent on the
+// same host so answers quickly when the agent is healthy
+const introspectionTimeout = 10 * time.Second
+
+var introspectionClient = &http.Client{Timeout: introspectionTimeout}
+
+// agentVersionPattern finds the version in the agent's description of itself, e.g.
+// "Amazon ECS Agent - v1.80.0 (a1b2c3d4)"
+var agentVersionPattern = regexp.MustCompile(`v([0-9]+\.[0-9]+\.[0-9]+)`)
+
+// agentMetadata is the response of the /v1/metadata endpoint of the ECS agent introspection API
+type agentMetadata struct {
+	// Cluster is the cluster the agent registered the container instance with, as configured on the agent (a name or
+	// an ARN)
+	Cluster              string `json:"Cluster"`
+	ContainerInstanceArn string `json:"ContainerInstanceArn"`
+	Version              string `json:"Version"`
+}
+
+// agentTasks is the response of the /v1/tasks endpoint of the ECS agent introspection API
+type agentTasks struct {
+	Tasks []agentTask `json:"Tasks"`
+}
+
+type agentTask struct {
+	Arn           string `json:"Arn"`
+	DesiredStatus string `json:"DesiredStatus"`
+	KnownStatus   string `json:"KnownStatus"`
+	// Family and Version are the task definition family and revision
+	Family     string           `json:"Family"`
+	Version    string           `json:"Version"`
+	Containers []agentContainer `json:"Containers"`
+}
+
+type agentContainer struct {
+	DockerID string `json:"DockerId"`
+	Name     string `json:"Name"`
+	Image    string `json:"Image"`
+	// ImageID is the ID of the image in the container runtime, not its repository digest
+	ImageID string `json:"ImageID"`
+}
+
+// ReportInstanceInventory reports the tasks running on the container instance the ECS agent at introspectionURL runs
+// on, as a partial report for its cluster, see GetInventoryReportForInstance. Partial reports are only sent to an
+// Anchore deployment serving the v2 API. A report that fails is returned, so the polling cycle fails as it would if the
+// inventory could not be read.
+func ReportInstanceInventory(
+	ctx context.Context,
+	introspectionURL, dockerSocket string,
+	anchoreDetails connection.AnchoreInfo,
+	quiet, dryRun bool,
+) error {
+	report, err := GetInventoryReportForInstance(ctx, introspectionURL, dockerSocket)
+	if err != nil {
+		return err
+	}
+	log := logger.With(logger.FromContext(ctx), "cluster", report.ClusterARN, "containerInstance", report.ContainerInstanceARN)
+	ctx = logger.NewContext(ctx, log)
+	log.Info("Found containers on container instance", "containerCount", len(report.Containers))
+
+	status := health.ClusterStatus{ClusterARN: report.ClusterARN, Tasks: len(report.Tasks), Containers: len(report.Containers)}
+	recordClusterMetrics(report)
+	if !dryRun {
+		err = checkPartialReports(anchoreDetails)
+	}
+	if err == nil {
+		err = reportCluster(ctx, report, anchoreDetails, quiet, dryRun)
+	}
+	if err != nil {
+		status.ReportError = err.Error()
+	}
+	health.Default.ClusterFinished(status)
+	return err
+}
+
+// GetInventoryReportForInstance returns the inventory of a single container instance, read from the ECS agent
+// introspection API on the instance rather than the ECS API. The report only includes the instance's tasks, so it is
+// marked as partial, with the container instance it covers. The digests of images referenced by tag are resolved with
+// the Docker daemon listening on dockerSocket, they are left empty when dockerSocket is empty.
+func GetInventoryReportForInstance(ctx context.Context, introspectionURL, dockerSocket string) (reporter.Report, error) {
+	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Getting Inventory Report for container instance: %s", introspectionURL))
+	var metadata agentMetadata
+	if err := getIntrospection(ctx, introspectionURL, "/v1/metadata", &metadata); err != nil {
+		return reporter.Report{}, err
+	}
+	if metadata.ContainerInstanceArn == "" {
+		return reporter.Report{}, fmt.Errorf("the ECS agent at %s has not registered its container instance yet", introspectionURL)
+	}
+	var tasks agentTasks
+	if err := getIntrospection(ctx, introspectionURL, "/v1/tasks", &tasks); err != nil {
+		return reporter.Report{}, err
+	}
+
+	digests, err := resolveImageDigests(ctx, dockerSocket, tasks.Tasks)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Unable to resolve image digests with the Docker daemon, the digests of images referenced by tag will not be reported", "err", err)
+	}
+
+	clusterARN := agentClusterARN(metadata)
+	report := reporter.Report{
+		Timestamp:            time.Now().UTC().Format(time.RFC3339),
+		ClusterARN:           clusterARN,
+		AccountID:            accountFromARN(clusterARN),
+		Region:               regionFromARN(clusterARN),
+		Partial:              true,
+		ContainerInstanceARN: metadata.ContainerInstanceArn,
+	}
+	instance := reporter.ContainerInstance{ARN: metadata.ContainerInstanceArn}
+	if match := agentVersionPattern.FindStringSubmatch(metadata.Version); match != nil {
+		instance.AgentVersion = match[1]
+	}
+
+	for _, task := range tasks.Tasks {
+		// only the tasks meant to be running are reported, as ListTasks does, the agent keeps stopped tasks until they
+		// are cleaned up
+		if task.DesiredStatus != string(ecstypes.DesiredStatusRunning) {
+			continue
+		}
+		t := reporter.Task{
+			ARN:        task.Arn,
+			TaskDefARN: agentTaskDefinitionARN(task),
+		}
+		addTaskLifecycle(&t, ecstypes.Task{
+			LastStatus:    aws.String(task.KnownStatus),
+			DesiredStatus: aws.String(task.DesiredStatus),
+		})
+		taskInstance := instance
+		t.ContainerInstance = &taskInstance
+		report.Tasks = append(report.Tasks, t)
+		report.Containers = append(report.Containers, agentTaskContainers(task, digests)...)
+	}
+	return report, nil
+}
+
+// agentTaskContainers returns the containers of a task. The introspection API doesn't have container ARNs, so each
+// container is given one from its task ARN and name, which is stable for the life of the task and unique in the region
+// like the ARN ECS gives it, see agentContainerARN. The digest of an image referenced by tag is taken from digests, by
+// image ID.
+func agentTaskContainers(task agentTask, digests map[string]string) []reporter.Container {
+	containers := make([]reporter.Container, 0, len(task.Containers))
+	for _, container := range task.Containers {
+		c := reporter.Container{
+			ARN:       agentContainerARN(task.Arn, container.Name),
+			Name:      container.Name,
+			TaskARN:   task.Arn,
+			RuntimeID: container.DockerID,
+		}
+		ecsContainer := ecstypes.Container{Image: aws.String(container.Image)}
+		if _, digest, ok := strings.Cut(container.Image, "@"); ok {
+			c.ImageDigest = digest
+			ecsContainer.ImageDigest = aws.String(digest)
+		} else {
+			c.ImageDigest = digests[container.ImageID]
+		}
+		c.ImageTag, _ = getContainerImageTag(map[string]string{}, &ecsContainer)
+		containers = append(containers, c)
+	}
+	return containers
+}
+
+// agentContainerARN returns the identity of a container read from the introspection API, e.g.
+// "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/0123456789abcdef/nginx". It is not an ECS ARN, ECS ARNs of
+// containers end with a generated ID the agent doesn't know.
+func agentContainerARN(taskARN, name string) string {
+	return taskARN + "/" + name
+}
+
+// partialReportsChecked is the URL of the Anchore deployment last found to serve the v2 API, so that the version is
+// only fetched again when the URL changes
+var partialReportsChecked struct {
+	mu  sync.Mutex
+	url string
+}
+
+// checkPartialReports returns an error unless Anchore serves the v2 API, see reporter.CheckPartialReports
+func checkPartialReports(anchoreDetails connection.AnchoreInfo) error {
+	partialReportsChecked.mu.Lock()
+	defer partialReportsChecked.mu.Unlock()
+	if partialReportsChecked.url == anchoreDetails.URL {
+		return nil
+	}
+	version, err := reporter.FetchVersion(anchoreDetails)
+	if err != nil {
+		return err
+	}
+	if err := reporter.CheckPartialReports(version); err != nil {
+		return err
+	}
+	partialReportsChecked.url = anchoreDetails.URL
+	return nil
+}
+
+// agentClusterARN returns the ARN of the container instance's cluster, the agent may be configured with just its name
+func agentClusterARN(metadata agentMetadata) string {
+	if strings.HasPrefix(metadata.Cluster, "arn:") {
+		return metadata.Cluster
+	}
+	prefix, _, _ := strings.Cut(metadata.ContainerInstanceArn, ":container-instance/")
+	return prefix + ":cluster/" + metadata.Cluster
+}
+
+// agentTaskDefinitionARN returns the ARN of a task's task definition, from its family and revision
+func agentTaskDefinitionARN(task agentTask) string {
+	prefix, _, ok := strings.Cut(task.Arn, ":task/")
+	if !ok || task.Family == "" {
+		return unknown
+	}
+	return fmt.Sprintf("%s:task-definition/%s:%s", prefix, task.Family, task.Version)
+}
+
+func getIntrospection(ctx context.Context, introspectionURL, path string, response any) error {
+	endpoint, err := url.JoinPath(introspectionURL, path)
+	if err != nil {
+		return fmt.Errorf("invalid ECS agent introspection URL %q: %w", introspectionURL, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := introspectionClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach the ECS agent introspection API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from the ECS agent introspection API %s: %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("unable to parse the response of the ECS agent introspection API %s: %w", endpoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/pkg/connection"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+const (
+	containerInstanceARN = "arn:aws:ecs:us-east-1:123456789012:container-instance/cluster-1/0123456789abcdef0123456789abcdef"
+	agentTaskARN         = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/90123456789abcdef0123456789abcdef"
+	stoppedAgentTaskARN  = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/stopped"
+)
+
+// agentMetadataResponse and agentTasksResponse are responses of the ECS agent introspection API: a task with a
+// container referencing its image by tag and one by digest, and a stopped task the agent has not cleaned up yet
+const (
+	agentMetadataResponse = `{
+  "Cluster": "cluster-1",
+  "ContainerInstanceArn": "` + containerInstanceARN + `",
+  "Version": "Amazon ECS Agent - v1.80.0 (a1b2c3d4)"
+}`
+	agentTasksResponse = `{
+  "Tasks": [
+    {
+      "Arn": "` + agentTaskARN + `",
+      "DesiredStatus": "RUNNING",
+      "KnownStatus": "RUNNING",
+      "Family": "web",
+      "Version": "7",
+      "Containers": [
+        {
+          "DockerId": "4b5c0d6e7f8a",
+          "DockerName": "ecs-web-7-nginx-a0b1c2d3e4f5",
+          "Name": "nginx",
+          "Image": "nginx:1.25",
+          "ImageID": "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6",
+          "Ports": [{"ContainerPort": 80, "Protocol": "tcp", "HostPort": 32768}]
+        },
+        {
+          "DockerId": "9f8e7d6c5b4a",
+          "DockerName": "ecs-web-7-sidecar-f5e4d3c2b1a0",
+          "Name": "sidecar",
+          "Image": "public.ecr.aws/aws-observability/aws-for-fluent-bit@sha256:1234567890123456789012345678901234567890123456789012345678906666",
+          "ImageID": "sha256:5b4a3c2d1e0f"
+        }
+      ]
+    },
+    {
+      "Arn": "` + stoppedAgentTaskARN + `",
+      "DesiredStatus": "STOPPED",
+      "KnownStatus": "STOPPED",
+      "Family": "batch-job",
+      "Version": "1",
+      "Containers": [{"DockerId": "0a1b2c3d4e5f", "Name": "job", "Image": "batch-job:latest"}]
+    }
+  ]
+}`
+)
+
+func newAgentServer(t *testing.T, metadata string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/metadata":
+			_, _ = w.Write([]byte(metadata))
+		case "/v1/tasks":
+			_, _ = w.Write([]byte(agentTasksResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetInventoryReportForInstance(t *testing.T) {
+	server := newAgentServer(t, agentMetadataResponse)
+	socket := newDockerServer(t)
+
+	report, err := GetInventoryReportForInstance(context.Background(), server.URL, socket)
+	require.NoError(t, err)
+
+	assert.Equal(t, cluster1ARN, report.ClusterARN)
+	assert.Equal(t, "123456789012", report.AccountID)
+	assert.Equal(t, "us-east-1", report.Region)
+	assert.True(t, report.Partial)
+	assert.Equal(t, containerInstanceARN, report.ContainerInstanceARN)
+	assert.Equal(t, []reporter.Task{
+		{
+			ARN:           agentTaskARN,
+			TaskDefARN:    "arn:aws:ecs:us-east-1:123456789012:task-definition/web:7",
+			LastStatus:    "RUNNING",
+			DesiredStatus: "RUNNING",
+			ContainerInstance: &reporter.ContainerInstance{
+				ARN:          containerInstanceARN,
+				AgentVersion: "1.80.0",
+			},
+		},
+	}, report.Tasks)
+	assert.Equal(t, []reporter.Container{
+		{
+			ARN:         agentTaskARN + "/nginx",
+			Name:        "nginx",
+			ImageTag:    "nginx:1.25",
+			ImageDigest: nginxDigest,
+			TaskARN:     agentTaskARN,
+			RuntimeID:   "4b5c0d6e7f8a",
+		},
+		{
+			ARN:         agentTaskARN + "/sidecar",
+			Name:        "sidecar",
+			ImageTag:    "public.ecr.aws/aws-observability/aws-for-fluent-bit:UNKNOWN",
+			ImageDigest: "sha256:1234567890123456789012345678901234567890123456789012345678906666",
+			TaskARN:     agentTaskARN,
+			RuntimeID:   "9f8e7d6c5b4a",
+		},
+	}, report.Containers)
+
+	// without the Docker daemon the digests of images referenced by tag are left out
+	report, err = GetInventoryReportForInstance(context.Background(), server.URL, "")
+	require.NoError(t, err)
+	assert.Empty(t, report.Containers[0].ImageDigest)
+}
+
+func TestGetInventoryReportForInstanceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name: "container instance not registered",
+			url: func(t *testing.T) string {
+				return newAgentServer(t, `{"Cluster": "cluster-1", "Version": "Amazon ECS Agent - v1.80.0 (a1b2c3d4)"}`).URL
+			},
+			wantErr: "has not registered its container instance yet",
+		},
+		{
+			name: "malformed response",
+			url: func(t *testing.T) string {
+				return newAgentServer(t, `not json`).URL
+			},
+			wantErr: "unable to parse the response of the ECS agent introspection API",
+		},
+		{
+			name: "not found",
+			url: func(t *testing.T) string {
+				return newAgentServer(t, agentMetadataResponse).URL + "/unknown"
+			},
+			wantErr: "unexpected status from the ECS agent introspection API",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetInventoryReportForInstance(context.Background(), tt.url(t), "")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestReportInstanceInventory(t *testing.T) {
+	server := newAgentServer(t, agentMetadataResponse)
+
+	err := ReportInstanceInventory(context.Background(), server.URL, "", connection.AnchoreInfo{}, true, true)
+	assert.NoError(t, err)
+
+	err = ReportInstanceInventory(context.Background(), server.URL+"/unknown", "", connection.AnchoreInfo{}, true, true)
+	assert.Error(t, err)
+}
+
+// useAnchoreVersion intercepts the requests to Anchore, which reports the given API version, for the duration of the
+// test
+func useAnchoreVersion(t *testing.T, apiVersion string) connection.AnchoreInfo {
+	t.Cleanup(gock.Off)
+	t.Cleanup(func() {
+		partialReportsChecked.url = ""
+	})
+	// only the requests to Anchore are intercepted
+	gock.EnableNetworking()
+	gock.NetworkingFilter(func(req *http.Request) bool {
+		return req.URL.Host != "ancho.re"
+	})
+	gock.New("https://ancho.re").
+		Get("/version").
+		Reply(200).
+		JSON(map[string]interface{}{"api": map[string]interface{}{"version": apiVersion}})
+	return connection.AnchoreInfo{URL: "https://ancho.re", User: "admin", Password: "foobar", Account: "test"}
+}
+
+func TestReportInstanceInventoryReportError(t *testing.T) {
+	server := newAgentServer(t, agentMetadataResponse)
+	anchore := useAnchoreVersion(t, "2")
+	gock.New("https://ancho.re").
+		Post("v2/ecs-inventory").
+		Reply(500)
+
+	err := ReportInstanceInventory(context.Background(), server.URL, "", anchore, true, false)
+	assert.ErrorContains(t, err, "unable to report Inventory to Anchore")
+	assert.Equal(t, "https://ancho.re", partialReportsChecked.url)
+}
+
+func TestReportInstanceInventoryNeedsV2API(t *testing.T) {
+	server := newAgentServer(t, agentMetadataResponse)
+	anchore := useAnchoreVersion(t, "1")
+
+	// nothing is posted to the v1 API
+	err := ReportInstanceInventory(context.Background(), server.URL, "", anchore, true, false)
+	assert.ErrorIs(t, err, reporter.ErrPartialReportsUnsupported)
+	assert.True(t, gock.IsDone())
+	assert.Empty(t, partialReportsChecked.url)
+}
+
+func Test_agentClusterARN(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+		want    string
+	}{
+		{name: "name", cluster: "cluster-1", want: cluster1ARN},
+		{name: "ARN", cluster: cluster2ARN, want: cluster2ARN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, agentClusterARN(agentMetadata{Cluster: tt.cluster, ContainerInstanceArn: containerInstanceARN}))
+		})
+	}
+}
+
+func Test_agentTaskDefinitionARN(t *testing.T) {
+	assert.Equal(t, "arn:aws-cn:ecs:cn-north-1:123456789012:task-definition/web:3",
+		agentTaskDefinitionARN(agentTask{Arn: "arn:aws-cn:ecs:cn-north-1:123456789012:task/cluster-1/abc", Family: "web", Version: "3"}))
+	assert.Equal(t, unknown, agentTaskDefinitionARN(agentTask{Arn: agentTaskARN}))
+}
+
+func Test_reportClusterSendsEmptyPartialReports(t *testing.T) {
+	defer gock.Off()
+	anchore := connection.AnchoreInfo{URL: "https://ancho.re", User: "admin", Password: "foobar", Account: "test"}
+	gock.New("https://ancho.re").
+		Post("v2/ecs-inventory").
+		BodyString(`"partial":true,"container_instance_arn":"` + containerInstanceARN + `"`).
+		Reply(201).
+		JSON(map[string]interface{}{})
+	report := reporter.Report{ClusterARN: cluster1ARN, Partial: true, ContainerInstanceARN: containerInstanceARN}
+
+	require.NoError(t, reportCluster(context.Background(), report, anchore, true, false))
+	assert.True(t, gock.IsDone())
+
+	// a full report of a cluster without containers is not sent
+	report.Partial, report.ContainerInstanceARN = false, ""
+	assert.NoError(t, reportCluster(context.Background(), report, anchore, true, false))
+}
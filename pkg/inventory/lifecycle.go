@@ -0,0 +1,46 @@
+package inventory
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// addTaskLifecycle records the state of a task, so that steady state tasks can be told apart from tasks that are
+// starting, draining, failing their health checks or have stopped
+func addTaskLifecycle(t *reporter.Task, task ecstypes.Task) {
+	t.LastStatus = aws.ToString(task.LastStatus)
+	t.DesiredStatus = aws.ToString(task.DesiredStatus)
+	t.HealthStatus = string(task.HealthStatus)
+	t.CreatedAt = formatTime(task.CreatedAt)
+	t.StartedAt = formatTime(task.StartedAt)
+	t.StartedBy = aws.ToString(task.StartedBy)
+	// a task that is still draining runs its images until its last status is STOPPED
+	t.Stopped = t.LastStatus == string(ecstypes.DesiredStatusStopped)
+	t.StopCode = string(task.StopCode)
+	t.StoppedReason = aws.ToString(task.StoppedReason)
+	t.StoppingAt = formatTime(task.StoppingAt)
+	t.StoppedAt = formatTime(task.StoppedAt)
+}
+
+// addContainerLifecycle records the state of a container, including its exit code once it has stopped
+func addContainerLifecycle(c *reporter.Container, container ecstypes.Container) {
+	c.LastStatus = aws.ToString(container.LastStatus)
+	c.HealthStatus = string(container.HealthStatus)
+	c.RuntimeID = aws.ToString(container.RuntimeId)
+	if container.ExitCode != nil {
+		exitCode := int(*container.ExitCode)
+		c.ExitCode = &exitCode
+	}
+}
+
+// formatTime formats a timestamp from the ECS API the same way as the report timestamp, unset timestamps are empty
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
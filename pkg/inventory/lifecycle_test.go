@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_addTaskLifecycle(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60))
+	started := created.Add(time.Minute)
+	task := ecstypes.Task{
+		LastStatus:    aws.String("RUNNING"),
+		DesiredStatus: aws.String("STOPPED"),
+		HealthStatus:  ecstypes.HealthStatusUnhealthy,
+		CreatedAt:     &created,
+		StartedAt:     &started,
+		StartedBy:     aws.String("ecs-svc/1234567890123456789"),
+	}
+
+	got := reporter.Task{}
+	addTaskLifecycle(&got, task)
+
+	assert.Equal(t, reporter.Task{
+		LastStatus:    "RUNNING",
+		DesiredStatus: "STOPPED",
+		HealthStatus:  "UNHEALTHY",
+		CreatedAt:     "2024-01-02T08:04:05Z",
+		StartedAt:     "2024-01-02T08:05:05Z",
+		StartedBy:     "ecs-svc/1234567890123456789",
+	}, got)
+}
+
+func Test_addTaskLifecyclePending(t *testing.T) {
+	got := reporter.Task{}
+	addTaskLifecycle(&got, ecstypes.Task{LastStatus: aws.String("PROVISIONING"), DesiredStatus: aws.String("RUNNING")})
+
+	assert.Equal(t, reporter.Task{LastStatus: "PROVISIONING", DesiredStatus: "RUNNING"}, got)
+}
+
+func Test_addTaskLifecycleStopped(t *testing.T) {
+	stopping := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stopped := stopping.Add(30 * time.Second)
+	got := reporter.Task{}
+	addTaskLifecycle(&got, ecstypes.Task{
+		LastStatus:    aws.String("STOPPED"),
+		DesiredStatus: aws.String("STOPPED"),
+		StopCode:      ecstypes.TaskStopCodeEssentialContainerExited,
+		StoppedReason: aws.String("Essential container in task exited"),
+		StoppingAt:    &stopping,
+		StoppedAt:     &stopped,
+	})
+
+	assert.Equal(t, reporter.Task{
+		LastStatus:    "STOPPED",
+		DesiredStatus: "STOPPED",
+		Stopped:       true,
+		StopCode:      "EssentialContainerExited",
+		StoppedReason: "Essential container in task exited",
+		StoppingAt:    "2024-01-02T03:04:05Z",
+		StoppedAt:     "2024-01-02T03:04:35Z",
+	}, got)
+}
+
+func Test_addContainerLifecycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		container ecstypes.Container
+		want      reporter.Container
+	}{
+		{
+			name: "running",
+			container: ecstypes.Container{
+				LastStatus:   aws.String("RUNNING"),
+				HealthStatus: ecstypes.HealthStatusHealthy,
+				RuntimeId:    aws.String("0123456789abcdef"),
+			},
+			want: reporter.Container{
+				LastStatus:   "RUNNING",
+				HealthStatus: "HEALTHY",
+				RuntimeID:    "0123456789abcdef",
+			},
+		},
+		{
+			name: "exited cleanly",
+			container: ecstypes.Container{
+				LastStatus: aws.String("STOPPED"),
+				ExitCode:   aws.Int32(0),
+			},
+			want: reporter.Container{
+				LastStatus: "STOPPED",
+				ExitCode:   aws.Int(0),
+			},
+		},
+		{
+			name: "crashed",
+			container: ecstypes.Container{
+				LastStatus: aws.String("STOPPED"),
+				ExitCode:   aws.Int32(137),
+			},
+			want: reporter.Container{
+				LastStatus: "STOPPED",
+				ExitCode:   aws.Int(137),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reporter.Container{}
+			addContainerLifecycle(&got, tt.container)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/anchore/ecs-inventory/internal/metrics"
+)
+
+// withMetrics instruments every ECS API call, recording calls, errors and duration once per call and throttling on
+// every attempt (the SDK retries throttled requests, so they are otherwise only visible as latency)
+func withMetrics(stack *middleware.Stack) error {
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+		"ECSInventoryMetrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			start := time.Now()
+
+			out, metadata, err := next.HandleInitialize(ctx, in)
+
+			metrics.ECSAPICalls.WithLabelValues(operation).Inc()
+			metrics.ECSAPIDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+			if err != nil {
+				metrics.ECSAPIErrors.WithLabelValues(operation).Inc()
+			}
+			return out, metadata, err
+		},
+	), middleware.After)
+	if err != nil {
+		return err
+	}
+
+	throttles := middleware.FinalizeMiddlewareFunc(
+		"ECSInventoryThrottleMetrics",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if err != nil && retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+				metrics.ECSAPIThrottles.WithLabelValues(awsmiddleware.GetOperationName(ctx)).Inc()
+			}
+			return out, metadata, err
+		},
+	)
+	// run within the retry loop so every throttled attempt is seen
+	if _, ok := stack.Finalize.Get((&retry.Attempt{}).ID()); ok {
+		return stack.Finalize.Insert(throttles, (&retry.Attempt{}).ID(), middleware.After)
+	}
+	return stack.Finalize.Add(throttles, middleware.After)
+}
@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/internal/metrics"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// sequenceHTTPClient replies with each response in turn
+type sequenceHTTPClient struct {
+	responses []*http.Response
+}
+
+func (c *sequenceHTTPClient) Do(_ *http.Request) (*http.Response, error) {
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.1"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	httpClient := &sequenceHTTPClient{responses: []*http.Response{
+		jsonResponse(400, `{"__type":"ThrottlingException","message":"Rate exceeded"}`),
+		jsonResponse(200, `{"clusterArns":["arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"]}`),
+		jsonResponse(400, `{"__type":"ClusterNotFoundException","message":"Cluster not found."}`),
+	}}
+	client := ecs.New(ecs.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  httpClient,
+		Retryer:     aws.NopRetryer{},
+		APIOptions:  []func(*middleware.Stack) error{withMetrics},
+	})
+	calls := testutil.ToFloat64(metrics.ECSAPICalls.WithLabelValues("ListClusters"))
+	errs := testutil.ToFloat64(metrics.ECSAPIErrors.WithLabelValues("ListClusters"))
+	throttles := testutil.ToFloat64(metrics.ECSAPIThrottles.WithLabelValues("ListClusters"))
+
+	_, err := client.ListClusters(context.Background(), &ecs.ListClustersInput{})
+	require.Error(t, err)
+	_, err = client.ListClusters(context.Background(), &ecs.ListClustersInput{})
+	require.NoError(t, err)
+	_, err = client.ListClusters(context.Background(), &ecs.ListClustersInput{})
+	require.Error(t, err)
+
+	assert.Equal(t, calls+3, testutil.ToFloat64(metrics.ECSAPICalls.WithLabelValues("ListClusters")))
+	assert.Equal(t, errs+2, testutil.ToFloat64(metrics.ECSAPIErrors.WithLabelValues("ListClusters")))
+	assert.Equal(t, throttles+1, testutil.ToFloat64(metrics.ECSAPIThrottles.WithLabelValues("ListClusters")))
+}
+
+func Test_recordClusterMetrics(t *testing.T) {
+	cluster := "arn:aws:ecs:us-east-1:123456789012:cluster/metrics"
+	recordClusterMetrics(reporter.Report{
+		ClusterARN: cluster,
+		Tasks:      []reporter.Task{{ARN: "task-1"}},
+		Containers: []reporter.Container{
+			{ARN: "container-1", ImageDigest: "sha256:abc"},
+			{ARN: "container-2"},
+		},
+	})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Tasks.WithLabelValues(cluster)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Containers.WithLabelValues(cluster)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ContainersMissingDigest.WithLabelValues(cluster)))
+}
@@ -3,6 +3,8 @@ package inventory
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
@@ -10,12 +12,36 @@ import (
 )
 
 type mockECSClient struct {
-	ErrorOnListCluster         bool
-	ErrorOnListTasks           bool
-	ErrorOnListServices        bool
-	ErrorOnDescribeTasks       bool
-	ErrorOnListTagsForResource bool
-	ErrorOnDescribeServices    bool
+	ErrorOnListCluster                bool
+	ErrorOnListTasks                  bool
+	ErrorOnListServices               bool
+	ErrorOnDescribeTasks              bool
+	ErrorOnListTagsForResource        bool
+	ErrorOnDescribeServices           bool
+	ErrorOnDescribeContainerInstances bool
+	ErrorOnDescribeClusters           bool
+	ErrorOnDescribeTaskDefinition     bool
+	ErrorOnListTaskDefinitions        bool
+	ErrorOnListTaskDefinitionFamilies bool
+	// ErrorOnDescribeTaskDefinitionOf fails to describe just the given task definition
+	ErrorOnDescribeTaskDefinitionOf string
+
+	// TaskDefinitionPages are the task definition ARNs listed, page by page, newest first. By default there are two
+	// families, task-definition-1 with two revisions and task-definition-2 with one.
+	TaskDefinitionPages [][]string
+	// TaskDefinitionEnvironment is the environment of the first container of every task definition described
+	TaskDefinitionEnvironment []ecstypes.KeyValuePair
+	// MissingClusters describes every cluster as missing
+	MissingClusters bool
+	// AgentVersions is the ECS agent version of each container instance, those not listed run 1.80.0
+	AgentVersions map[string]string
+
+	// DescribeContainerInstancesCalls counts the calls to DescribeContainerInstances
+	DescribeContainerInstancesCalls int
+	// DescribedTaskDefinitions records the task definitions described, in order
+	DescribedTaskDefinitions []string
+	// ListTaskDefinitionsCalls counts the calls to ListTaskDefinitions
+	ListTaskDefinitionsCalls int
 }
 
 func (m *mockECSClient) ListClusters(ctx context.Context, _ *ecs.ListClustersInput, _ ...func(*ecs.Options)) (*ecs.ListClustersOutput, error) {
@@ -188,3 +214,159 @@ func (m *mockECSClient) DescribeServices(ctx context.Context, input *ecs.Describ
 
 	return &ecs.DescribeServicesOutput{Services: services}, nil
 }
+
+func (m *mockECSClient) DescribeContainerInstances(ctx context.Context, input *ecs.DescribeContainerInstancesInput, _ ...func(*ecs.Options)) (*ecs.DescribeContainerInstancesOutput, error) {
+	m.DescribeContainerInstancesCalls++
+	if m.ErrorOnDescribeContainerInstances {
+		return nil, errors.New("describe container instances error")
+	}
+
+	instances := []ecstypes.ContainerInstance{}
+	for _, arn := range input.ContainerInstances {
+		agentVersion, ok := m.AgentVersions[arn]
+		if !ok {
+			agentVersion = "1.80.0"
+		}
+		instances = append(instances, ecstypes.ContainerInstance{
+			ContainerInstanceArn: aws.String(arn),
+			Ec2InstanceId:        aws.String("i-0123456789abcdef0"),
+			VersionInfo: &ecstypes.VersionInfo{
+				AgentVersion:  aws.String(agentVersion),
+				DockerVersion: aws.String("DockerVersion: 20.10.25"),
+			},
+			Attributes: []ecstypes.Attribute{
+				{Name: aws.String("ecs.ami-id"), Value: aws.String("ami-0123456789abcdef0")},
+				{Name: aws.String("ecs.cpu-architecture"), Value: aws.String("x86_64")},
+				{Name: aws.String("ecs.os-family"), Value: aws.String("LINUX")},
+			},
+		})
+	}
+
+	return &ecs.DescribeContainerInstancesOutput{ContainerInstances: instances}, nil
+}
+
+func (m *mockECSClient) DescribeClusters(ctx context.Context, input *ecs.DescribeClustersInput, _ ...func(*ecs.Options)) (*ecs.DescribeClustersOutput, error) {
+	if m.ErrorOnDescribeClusters {
+		return nil, errors.New("describe clusters error")
+	}
+	if m.MissingClusters {
+		failures := []ecstypes.Failure{}
+		for _, arn := range input.Clusters {
+			failures = append(failures, ecstypes.Failure{Arn: aws.String(arn), Reason: aws.String("MISSING")})
+		}
+		return &ecs.DescribeClustersOutput{Failures: failures}, nil
+	}
+
+	clusters := []ecstypes.Cluster{}
+	for _, arn := range input.Clusters {
+		clusters = append(clusters, ecstypes.Cluster{
+			ClusterArn:                        aws.String(arn),
+			ClusterName:                       aws.String(arn[strings.LastIndex(arn, "/")+1:]),
+			Status:                            aws.String("ACTIVE"),
+			CapacityProviders:                 []string{"FARGATE", "FARGATE_SPOT"},
+			RegisteredContainerInstancesCount: 1,
+			RunningTasksCount:                 2,
+			ActiveServicesCount:               2,
+			Tags: []ecstypes.Tag{
+				{Key: aws.String("environment"), Value: aws.String("production")},
+			},
+			Settings: []ecstypes.ClusterSetting{
+				{Name: ecstypes.ClusterSettingNameContainerInsights, Value: aws.String("enabled")},
+			},
+		})
+	}
+
+	return &ecs.DescribeClustersOutput{Clusters: clusters}, nil
+}
+
+func (m *mockECSClient) DescribeTaskDefinition(ctx context.Context, input *ecs.DescribeTaskDefinitionInput, _ ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error) {
+	m.DescribedTaskDefinitions = append(m.DescribedTaskDefinitions, aws.ToString(input.TaskDefinition))
+	if m.ErrorOnDescribeTaskDefinition || aws.ToString(input.TaskDefinition) == m.ErrorOnDescribeTaskDefinitionOf {
+		return nil, errors.New("describe task definition error")
+	}
+
+	return &ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: &ecstypes.TaskDefinition{
+			TaskDefinitionArn: input.TaskDefinition,
+			NetworkMode:       ecstypes.NetworkModeAwsvpc,
+			ContainerDefinitions: []ecstypes.ContainerDefinition{
+				{
+					Name:        aws.String("container-1"),
+					Image:       aws.String("image-1"),
+					Environment: m.TaskDefinitionEnvironment,
+				},
+			},
+		},
+	}, nil
+}
+
+func (m *mockECSClient) taskDefinitionPages() [][]string {
+	if m.TaskDefinitionPages != nil {
+		return m.TaskDefinitionPages
+	}
+	return [][]string{{
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-2:1",
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:2",
+		"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:1",
+	}}
+}
+
+// ListTaskDefinitions lists the task definitions page by page, or the latest revisions of a family when its full name
+// is given as the family prefix
+func (m *mockECSClient) ListTaskDefinitions(ctx context.Context, input *ecs.ListTaskDefinitionsInput, _ ...func(*ecs.Options)) (*ecs.ListTaskDefinitionsOutput, error) {
+	m.ListTaskDefinitionsCalls++
+	if m.ErrorOnListTaskDefinitions {
+		return nil, errors.New("list task definitions error")
+	}
+
+	pages := m.taskDefinitionPages()
+	if input.FamilyPrefix != nil {
+		output := &ecs.ListTaskDefinitionsOutput{}
+		for _, page := range pages {
+			for _, arn := range page {
+				if family, _ := splitTaskDefinition(arn); family == aws.ToString(input.FamilyPrefix) {
+					output.TaskDefinitionArns = append(output.TaskDefinitionArns, arn)
+				}
+			}
+		}
+		if input.MaxResults != nil && len(output.TaskDefinitionArns) > int(*input.MaxResults) {
+			output.TaskDefinitionArns = output.TaskDefinitionArns[:*input.MaxResults]
+		}
+		return output, nil
+	}
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(aws.ToString(input.NextToken))
+	}
+	output := &ecs.ListTaskDefinitionsOutput{TaskDefinitionArns: pages[page]}
+	if page+1 < len(pages) {
+		output.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
+
+// ListTaskDefinitionFamilies lists the families of the task definitions, one page of families for each page of task
+// definitions
+func (m *mockECSClient) ListTaskDefinitionFamilies(ctx context.Context, input *ecs.ListTaskDefinitionFamiliesInput, _ ...func(*ecs.Options)) (*ecs.ListTaskDefinitionFamiliesOutput, error) {
+	if m.ErrorOnListTaskDefinitionFamilies {
+		return nil, errors.New("list task definition families error")
+	}
+
+	pages := m.taskDefinitionPages()
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(aws.ToString(input.NextToken))
+	}
+	output := &ecs.ListTaskDefinitionFamiliesOutput{}
+	seen := map[string]bool{}
+	for _, arn := range pages[page] {
+		if family, _ := splitTaskDefinition(arn); !seen[family] {
+			seen[family] = true
+			output.Families = append(output.Families, family)
+		}
+	}
+	if page+1 < len(pages) {
+		output.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
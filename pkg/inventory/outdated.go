@@ -0,0 +1,149 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// finding types, see reporter.Finding
+const (
+	FindingOutdatedTaskDefinition = "outdated-task-definition"
+	FindingStuckDeployment        = "stuck-deployment"
+)
+
+// stuckDeploymentAge is how long a deployment can run alongside older deployments before it is reported as stuck.
+// Deployments normally finish replacing the older tasks within minutes.
+const stuckDeploymentAge = time.Hour
+
+// outdatedRevisionFindings returns the services with tasks running an older revision than the service's current task
+// definition, and the services whose latest deployment has failed or is stuck with tasks of older deployments still
+// running. Tasks of older revisions are expected while a deployment is rolling out, so they are only reported once
+// the deployment has finished or is stuck.
+func outdatedRevisionFindings(report reporter.Report, now time.Time) []reporter.Finding {
+	findings := []reporter.Finding{}
+	for _, service := range report.Services {
+		stuck, isStuck := stuckDeployment(service, now)
+		if !rollingOut(service) || isStuck {
+			if finding, ok := outdatedTaskDefinition(service, report.Tasks); ok {
+				findings = append(findings, finding)
+			}
+		}
+		if isStuck {
+			findings = append(findings, stuck)
+		}
+	}
+	return findings
+}
+
+// rollingOut reports whether the primary deployment of a service has not finished replacing the service's tasks.
+// Services deployed by CODE_DEPLOY or an EXTERNAL controller roll out with task sets instead, which keep the tasks of
+// the original task set running until the replacement has baked, so they are rolling out while a task set other than
+// the primary one still runs tasks or a task set has not stabilized.
+func rollingOut(service reporter.Service) bool {
+	for _, taskSet := range service.TaskSets {
+		if taskSet.StabilityStatus == "STABILIZING" || (taskSet.Status != "PRIMARY" && taskSet.RunningCount > 0) {
+			return true
+		}
+	}
+	for _, deployment := range service.Deployments {
+		if deployment.Status != "PRIMARY" {
+			continue
+		}
+		if deployment.RolloutState != "" {
+			return deployment.RolloutState == "IN_PROGRESS"
+		}
+		// the rollout state is only reported for some services, without it the deployment is rolling out until all of
+		// its tasks are running
+		return deployment.RunningCount < deployment.DesiredCount
+	}
+	return false
+}
+
+func outdatedTaskDefinition(service reporter.Service, tasks []reporter.Task) (reporter.Finding, bool) {
+	if service.TaskDefARN == "" {
+		return reporter.Finding{}, false
+	}
+	outdated := map[string]bool{}
+	var outdatedTasks []string
+	for _, task := range tasks {
+		if task.Stopped || task.ServiceARN != service.ARN || task.TaskDefARN == "" || task.TaskDefARN == service.TaskDefARN {
+			continue
+		}
+		outdated[task.TaskDefARN] = true
+		outdatedTasks = append(outdatedTasks, task.ARN)
+	}
+	if len(outdatedTasks) == 0 {
+		return reporter.Finding{}, false
+	}
+	sort.Strings(outdatedTasks)
+	return reporter.Finding{
+		Type:                FindingOutdatedTaskDefinition,
+		ServiceARN:          service.ARN,
+		TaskDefARN:          service.TaskDefARN,
+		OutdatedTaskDefARNs: sortedKeys(outdated),
+		Tasks:               outdatedTasks,
+		Message:             fmt.Sprintf("tasks running an older task definition than the service's current task definition: %d", len(outdatedTasks)),
+	}, true
+}
+
+// stuckDeployment reports a service whose primary deployment has failed, or has been running for longer than
+// stuckDeploymentAge, while tasks of older deployments are still running
+func stuckDeployment(service reporter.Service, now time.Time) (reporter.Finding, bool) {
+	var primary *reporter.Deployment
+	var outdated []string
+	for i, deployment := range service.Deployments {
+		switch {
+		case deployment.Status == "PRIMARY":
+			primary = &service.Deployments[i]
+		case deployment.RunningCount > 0:
+			outdated = append(outdated, deployment.TaskDefARN)
+		}
+	}
+	if primary == nil || len(outdated) == 0 {
+		return reporter.Finding{}, false
+	}
+
+	var reason string
+	switch {
+	case primary.RolloutState == "FAILED":
+		reason = "has failed"
+		if primary.RolloutStateReason != "" {
+			reason += ": " + primary.RolloutStateReason
+		}
+	default:
+		createdAt, err := time.Parse(time.RFC3339, primary.CreatedAt)
+		if err != nil || now.Sub(createdAt) < stuckDeploymentAge {
+			return reporter.Finding{}, false
+		}
+		reason = fmt.Sprintf("started %s ago and has not replaced the tasks of older deployments", now.Sub(createdAt).Round(time.Minute))
+	}
+	sort.Strings(outdated)
+	return reporter.Finding{
+		Type:                FindingStuckDeployment,
+		ServiceARN:          service.ARN,
+		TaskDefARN:          primary.TaskDefARN,
+		OutdatedTaskDefARNs: outdated,
+		DeploymentID:        primary.ID,
+		Message:             fmt.Sprintf("deployment %s %s", primary.ID, reason),
+	}, true
+}
+
+// warnOutdatedRevisions logs a single warning for the cluster naming the services with outdated revisions running
+func warnOutdatedRevisions(ctx context.Context, findings []reporter.Finding) {
+	services := map[string][]string{}
+	for _, finding := range findings {
+		services[finding.Type] = append(services[finding.Type], finding.ServiceARN)
+	}
+	log := logger.FromContext(ctx)
+	if outdated := services[FindingOutdatedTaskDefinition]; len(outdated) > 0 {
+		log.Warn("Services have tasks running an outdated task definition revision", "serviceCount", len(outdated), "services", outdated)
+	}
+	if stuck := services[FindingStuckDeployment]; len(stuck) > 0 {
+		log.Warn("Service deployments have failed or are stuck with older revisions still running", "serviceCount", len(stuck), "services", stuck)
+	}
+}
@@ -0,0 +1,226 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_outdatedRevisionFindings(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		service  reporter.Service
+		tasks    []reporter.Task
+		expected []reporter.Finding
+	}{
+		{
+			name:    "all tasks on the current revision",
+			service: reporter.Service{ARN: "service-1", TaskDefARN: "web:2"},
+			tasks: []reporter.Task{
+				{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:2"},
+				{ARN: "task-2", ServiceARN: "service-2", TaskDefARN: "web:1"},
+			},
+			expected: []reporter.Finding{},
+		},
+		{
+			name:    "stopped tasks on an older revision",
+			service: reporter.Service{ARN: "service-1", TaskDefARN: "web:2"},
+			tasks: []reporter.Task{
+				{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:2"},
+				{ARN: "task-2", ServiceARN: "service-1", TaskDefARN: "web:1", Stopped: true},
+			},
+			expected: []reporter.Finding{},
+		},
+		{
+			name: "tasks on an older revision after the deployment completed",
+			service: reporter.Service{
+				ARN:         "service-1",
+				TaskDefARN:  "web:3",
+				Deployments: []reporter.Deployment{{ID: "ecs-svc/3", Status: "PRIMARY", TaskDefARN: "web:3", RolloutState: "COMPLETED"}},
+			},
+			tasks: []reporter.Task{
+				{ARN: "task-3", ServiceARN: "service-1", TaskDefARN: "web:1"},
+				{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:3"},
+				{ARN: "task-2", ServiceARN: "service-1", TaskDefARN: "web:2"},
+			},
+			expected: []reporter.Finding{
+				{
+					Type:                "outdated-task-definition",
+					ServiceARN:          "service-1",
+					TaskDefARN:          "web:3",
+					OutdatedTaskDefARNs: []string{"web:1", "web:2"},
+					Tasks:               []string{"task-2", "task-3"},
+					Message:             "tasks running an older task definition than the service's current task definition: 2",
+				},
+			},
+		},
+		{
+			name: "tasks on an older revision while the deployment rolls out",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				Deployments: []reporter.Deployment{
+					{ID: "ecs-svc/2", Status: "PRIMARY", TaskDefARN: "web:2", RolloutState: "IN_PROGRESS", CreatedAt: "2024-01-01T11:55:00Z"},
+					{ID: "ecs-svc/1", Status: "ACTIVE", TaskDefARN: "web:1", RunningCount: 1},
+				},
+			},
+			tasks:    []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{},
+		},
+		{
+			name: "deployment without a rollout state still placing tasks",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				Deployments: []reporter.Deployment{
+					{ID: "ecs-svc/2", Status: "PRIMARY", TaskDefARN: "web:2", DesiredCount: 2, RunningCount: 1, CreatedAt: "2024-01-01T11:55:00Z"},
+					{ID: "ecs-svc/1", Status: "ACTIVE", TaskDefARN: "web:1", RunningCount: 1},
+				},
+			},
+			tasks:    []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{},
+		},
+		{
+			name: "deployment stuck rolling out",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				Deployments: []reporter.Deployment{
+					{ID: "ecs-svc/2", Status: "PRIMARY", TaskDefARN: "web:2", RolloutState: "IN_PROGRESS", CreatedAt: "2024-01-01T09:00:00Z"},
+					{ID: "ecs-svc/1", Status: "ACTIVE", TaskDefARN: "web:1", RunningCount: 1},
+				},
+			},
+			tasks: []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{
+				{
+					Type:                "outdated-task-definition",
+					ServiceARN:          "service-1",
+					TaskDefARN:          "web:2",
+					OutdatedTaskDefARNs: []string{"web:1"},
+					Tasks:               []string{"task-1"},
+					Message:             "tasks running an older task definition than the service's current task definition: 1",
+				},
+				{
+					Type:                "stuck-deployment",
+					ServiceARN:          "service-1",
+					TaskDefARN:          "web:2",
+					OutdatedTaskDefARNs: []string{"web:1"},
+					DeploymentID:        "ecs-svc/2",
+					Message:             "deployment ecs-svc/2 started 3h0m0s ago and has not replaced the tasks of older deployments",
+				},
+			},
+		},
+		{
+			name: "failed deployment",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				Deployments: []reporter.Deployment{
+					{
+						ID:                 "ecs-svc/2",
+						Status:             "PRIMARY",
+						TaskDefARN:         "web:2",
+						RolloutState:       "FAILED",
+						RolloutStateReason: "ECS deployment circuit breaker: tasks failed to start.",
+						CreatedAt:          "2024-01-01T11:55:00Z",
+					},
+					{ID: "ecs-svc/1", Status: "ACTIVE", TaskDefARN: "web:1", RunningCount: 2},
+				},
+			},
+			expected: []reporter.Finding{
+				{
+					Type:                "stuck-deployment",
+					ServiceARN:          "service-1",
+					TaskDefARN:          "web:2",
+					OutdatedTaskDefARNs: []string{"web:1"},
+					DeploymentID:        "ecs-svc/2",
+					Message:             "deployment ecs-svc/2 has failed: ECS deployment circuit breaker: tasks failed to start.",
+				},
+			},
+		},
+		{
+			name: "blue/green deployment baking with the original task set still running",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				TaskSets: []reporter.TaskSet{
+					{ID: "ecs-svc/green", Status: "PRIMARY", TaskDefARN: "web:2", RunningCount: 1, StabilityStatus: "STEADY_STATE"},
+					{ID: "ecs-svc/blue", Status: "ACTIVE", TaskDefARN: "web:1", RunningCount: 1, StabilityStatus: "STEADY_STATE"},
+				},
+			},
+			tasks:    []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{},
+		},
+		{
+			name: "task set stabilizing",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				TaskSets: []reporter.TaskSet{
+					{ID: "ecs-svc/green", Status: "PRIMARY", TaskDefARN: "web:2", RunningCount: 1, StabilityStatus: "STABILIZING"},
+				},
+			},
+			tasks:    []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{},
+		},
+		{
+			name: "tasks on an older revision after the blue/green deployment completed",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				TaskSets: []reporter.TaskSet{
+					{ID: "ecs-svc/green", Status: "PRIMARY", TaskDefARN: "web:2", RunningCount: 1, StabilityStatus: "STEADY_STATE"},
+					{ID: "ecs-svc/blue", Status: "DRAINING", TaskDefARN: "web:1", StabilityStatus: "STEADY_STATE"},
+				},
+			},
+			tasks: []reporter.Task{{ARN: "task-1", ServiceARN: "service-1", TaskDefARN: "web:1"}},
+			expected: []reporter.Finding{
+				{
+					Type:                "outdated-task-definition",
+					ServiceARN:          "service-1",
+					TaskDefARN:          "web:2",
+					OutdatedTaskDefARNs: []string{"web:1"},
+					Tasks:               []string{"task-1"},
+					Message:             "tasks running an older task definition than the service's current task definition: 1",
+				},
+			},
+		},
+		{
+			name: "old deployment being drained",
+			service: reporter.Service{
+				ARN:        "service-1",
+				TaskDefARN: "web:2",
+				Deployments: []reporter.Deployment{
+					{ID: "ecs-svc/2", Status: "PRIMARY", TaskDefARN: "web:2", RolloutState: "COMPLETED", CreatedAt: "2024-01-01T09:00:00Z"},
+					{ID: "ecs-svc/1", Status: "ACTIVE", TaskDefARN: "web:1"},
+				},
+			},
+			expected: []reporter.Finding{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := reporter.Report{Services: []reporter.Service{tt.service}, Tasks: tt.tasks}
+			assert.Equal(t, tt.expected, outdatedRevisionFindings(report, now))
+		})
+	}
+}
+
+func Test_warnOutdatedRevisions(t *testing.T) {
+	log := &recordingLogger{}
+	ctx := logger.NewContext(context.Background(), log)
+
+	warnOutdatedRevisions(ctx, []reporter.Finding{
+		{Type: FindingOutdatedTaskDefinition, ServiceARN: "service-1"},
+		{Type: FindingOutdatedTaskDefinition, ServiceARN: "service-2"},
+		{Type: FindingStuckDeployment, ServiceARN: "service-2"},
+	})
+
+	assert.Len(t, log.warnings, 2)
+}
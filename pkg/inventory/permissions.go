@@ -0,0 +1,394 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// probe names used for describe calls when the cluster has no tasks, services, task definitions or container instances
+// to describe (or there are no rules or messages), the API still authorizes the call and reports the resource as missing
+const (
+	probeTaskID        = "00000000000000000000000000000000"
+	probeServiceName   = "anchore-ecs-inventory-probe"
+	probeFamily        = "anchore-ecs-inventory-probe"
+	probeInstanceID    = "00000000000000000000000000000000"
+	probeRuleName      = "anchore-ecs-inventory-probe"
+	probeReceiptHandle = "anchore-ecs-inventory-probe"
+)
+
+// accessDeniedCodes are the API error codes returned when the caller is not authorized to perform an action
+var accessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+}
+
+// Permission is an IAM action that is used to build the inventory
+type Permission struct {
+	Action string
+	// Resources are the ARNs the action is used on, with {partition}, {region}, {account} and {cluster} placeholders.
+	// Actions that don't support resource level permissions have none, and can only be granted on "*".
+	Resources []string
+	// ClusterCondition is set for actions that can only be scoped to a cluster with the ecs:cluster condition key
+	ClusterCondition bool
+	// Needed reports whether the action is used with the given collect options, it is nil for actions that are always
+	// used
+	Needed func(collect CollectOptions) bool
+	// probe exercises the action against the target, returning a short description of what was found
+	probe func(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error)
+}
+
+// ECSPermissions lists every ECS action used (through ECSAPI) to build the inventory, in the order they are first used
+// for a cluster. Each method of ECSAPI must have an entry here, see NeededECSPermissions for the actions used with a
+// given set of collect options.
+var ECSPermissions = []Permission{
+	{
+		Action: "ecs:ListClusters",
+		probe:  probeListClusters,
+	},
+	{
+		Action:           "ecs:ListTasks",
+		ClusterCondition: true,
+		probe:            probeListTasks,
+	},
+	{
+		Action:           "ecs:ListServices",
+		ClusterCondition: true,
+		probe:            probeListServices,
+	},
+	{
+		Action:    "ecs:DescribeTasks",
+		Resources: []string{taskResource},
+		probe:     probeDescribeTasks,
+	},
+	{
+		Action:    "ecs:DescribeServices",
+		Resources: []string{serviceResource},
+		probe:     probeDescribeServices,
+	},
+	{
+		Action:    "ecs:ListTagsForResource",
+		Resources: []string{taskResource, serviceResource},
+		probe:     probeListTagsForResource,
+	},
+	{
+		Action: "ecs:DescribeTaskDefinition",
+		Needed: func(collect CollectOptions) bool {
+			return collect.TaskDefinitions || collect.EnvironmentSecrets || collect.DeployableImages
+		},
+		probe: probeDescribeTaskDefinition,
+	},
+	{
+		Action: "ecs:ListTaskDefinitionFamilies",
+		Needed: func(collect CollectOptions) bool { return collect.DeployableImages },
+		probe:  probeListTaskDefinitionFamilies,
+	},
+	{
+		Action: "ecs:ListTaskDefinitions",
+		Needed: func(collect CollectOptions) bool { return collect.DeployableImages },
+		probe:  probeListTaskDefinitions,
+	},
+	{
+		Action:    "ecs:DescribeContainerInstances",
+		Resources: []string{containerInstanceResource},
+		probe:     probeDescribeContainerInstances,
+	},
+	{
+		Action:    "ecs:DescribeClusters",
+		Resources: []string{clusterResource},
+		probe:     probeDescribeClusters,
+	},
+}
+
+// NeededECSPermissions returns the ECS actions used to build the inventory with the given collect options
+func NeededECSPermissions(collect CollectOptions) []Permission {
+	permissions := make([]Permission, 0, len(ECSPermissions))
+	for _, permission := range ECSPermissions {
+		if permission.Needed == nil || permission.Needed(collect) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// PermissionCheck is the outcome of checking a single IAM action
+type PermissionCheck struct {
+	Action string
+	// Allowed is true when the call was authorized, even if it failed for another reason (e.g. a missing cluster)
+	Allowed bool
+	// Skipped is true when the action was not called, either in a dry run or because there was nothing to call it on
+	Skipped bool
+	Detail  string
+	// Err is set when the call failed in a way that does not tell whether it was authorized (e.g. a network error)
+	Err error
+}
+
+// PermissionClients are the clients the actions are checked with. The EventBridge and SQS clients are only needed when
+// their actions are checked.
+type PermissionClients struct {
+	ECS    ECSAPI
+	Events EventBridgeAPI
+	SQS    SQSAPI
+	// QueueURL is the SQS queue the SQS actions are checked against
+	QueueURL string
+}
+
+// probeTarget is the cluster being checked, along with resources discovered while checking it, so that later actions
+// can be checked against real resources where there are any
+type probeTarget struct {
+	cluster     string
+	taskARNs    []string
+	serviceARNs []string
+	ruleNames   []string
+}
+
+// CheckPermissions calls each of the given actions (see PolicyOptions.Permissions for those used with a given config)
+// and reports which are allowed. The ECS actions are called against a single cluster, the first cluster found if none
+// is given. All calls are read only. In a dry run no calls are made, the checks that would be made are returned as
+// skipped.
+func CheckPermissions(ctx context.Context, clients PermissionClients, permissions []Permission, cluster string, dryRun bool) []PermissionCheck {
+	target := &probeTarget{cluster: cluster}
+	checks := make([]PermissionCheck, 0, len(permissions))
+	for _, permission := range permissions {
+		check := PermissionCheck{Action: permission.Action}
+		ecsAction := strings.HasPrefix(permission.Action, "ecs:")
+		switch {
+		case dryRun:
+			check.Skipped = true
+			check.Detail = fmt.Sprintf("dry run, would call %s", permission.Action)
+			if ecsAction && target.cluster != "" {
+				check.Detail += fmt.Sprintf(" against cluster %s", target.cluster)
+			}
+		case ecsAction && target.cluster == "" && permission.Action != "ecs:ListClusters":
+			check.Skipped = true
+			check.Detail = "no cluster to check against, specify one with --cluster"
+		default:
+			detail, err := permission.probe(ctx, clients, target)
+			check.Detail = detail
+			if errors.Is(err, errNothingToProbe) {
+				check.Skipped = true
+				break
+			}
+			check.Allowed, check.Err = classifyPermissionError(err)
+			var apiErr smithy.APIError
+			if check.Allowed && errors.As(err, &apiErr) {
+				check.Detail = fmt.Sprintf("authorized, but the call failed: %s", apiErr.ErrorMessage())
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// classifyPermissionError reports whether the error from a call means the action is allowed. Any error from the API
+// other than an access denied error means the call was authorized. Errors that don't come from the API are returned
+// since they don't tell either way.
+func classifyPermissionError(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return !accessDeniedCodes[apiErr.ErrorCode()], nil
+	}
+	return false, err
+}
+
+// errNothingToProbe is returned by a probe when there is no resource to call the action on
+var errNothingToProbe = errors.New("nothing to probe")
+
+func probeListClusters(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	result, err := clients.ECS.ListClusters(ctx, &ecs.ListClustersInput{MaxResults: aws.Int32(1)})
+	if err != nil {
+		return "", err
+	}
+	if target.cluster == "" {
+		if len(result.ClusterArns) == 0 {
+			return "no clusters found in region", nil
+		}
+		target.cluster = result.ClusterArns[0]
+		return fmt.Sprintf("checking against cluster %s", target.cluster), nil
+	}
+	return "", nil
+}
+
+func probeListTasks(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	result, err := clients.ECS.ListTasks(ctx, &ecs.ListTasksInput{Cluster: aws.String(target.cluster), MaxResults: aws.Int32(1)})
+	if err != nil {
+		return "", err
+	}
+	target.taskARNs = result.TaskArns
+	return "", nil
+}
+
+func probeListServices(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	result, err := clients.ECS.ListServices(ctx, &ecs.ListServicesInput{Cluster: aws.String(target.cluster), MaxResults: aws.Int32(1)})
+	if err != nil {
+		return "", err
+	}
+	target.serviceARNs = result.ServiceArns
+	return "", nil
+}
+
+func probeDescribeTasks(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	tasks := target.taskARNs
+	if len(tasks) == 0 {
+		tasks = []string{probeTaskID}
+	}
+	_, err := clients.ECS.DescribeTasks(ctx, &ecs.DescribeTasksInput{Cluster: aws.String(target.cluster), Tasks: tasks})
+	return "", err
+}
+
+func probeDescribeServices(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	services := target.serviceARNs
+	if len(services) == 0 {
+		services = []string{probeServiceName}
+	}
+	_, err := clients.ECS.DescribeServices(ctx, &ecs.DescribeServicesInput{Cluster: aws.String(target.cluster), Services: services})
+	return "", err
+}
+
+func probeListTagsForResource(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	var resourceARN string
+	switch {
+	case len(target.taskARNs) > 0:
+		resourceARN = target.taskARNs[0]
+	case len(target.serviceARNs) > 0:
+		resourceARN = target.serviceARNs[0]
+	case strings.HasPrefix(target.cluster, "arn:"):
+		resourceARN = target.cluster
+	default:
+		return "no task, service or cluster ARN to check against, specify the cluster ARN with --cluster", errNothingToProbe
+	}
+	_, err := clients.ECS.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{ResourceArn: aws.String(resourceARN)})
+	return "", err
+}
+
+func probeDescribeTaskDefinition(ctx context.Context, clients PermissionClients, _ *probeTarget) (string, error) {
+	_, err := clients.ECS.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(probeFamily)})
+	return "", err
+}
+
+func probeListTaskDefinitionFamilies(ctx context.Context, clients PermissionClients, _ *probeTarget) (string, error) {
+	_, err := clients.ECS.ListTaskDefinitionFamilies(ctx, &ecs.ListTaskDefinitionFamiliesInput{MaxResults: aws.Int32(1)})
+	return "", err
+}
+
+func probeListTaskDefinitions(ctx context.Context, clients PermissionClients, _ *probeTarget) (string, error) {
+	_, err := clients.ECS.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{MaxResults: aws.Int32(1)})
+	return "", err
+}
+
+func probeDescribeContainerInstances(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	_, err := clients.ECS.DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(target.cluster),
+		ContainerInstances: []string{probeInstanceID},
+	})
+	return "", err
+}
+
+func probeDescribeClusters(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	_, err := clients.ECS.DescribeClusters(ctx, &ecs.DescribeClustersInput{Clusters: []string{target.cluster}})
+	return "", err
+}
+
+func probeListRules(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	result, err := clients.Events.ListRules(ctx, &eventbridge.ListRulesInput{Limit: aws.Int32(1)})
+	if err != nil {
+		return "", err
+	}
+	for _, rule := range result.Rules {
+		target.ruleNames = append(target.ruleNames, aws.ToString(rule.Name))
+	}
+	return "", nil
+}
+
+func probeListTargetsByRule(ctx context.Context, clients PermissionClients, target *probeTarget) (string, error) {
+	rule := probeRuleName
+	if len(target.ruleNames) > 0 {
+		rule = target.ruleNames[0]
+	}
+	_, err := clients.Events.ListTargetsByRule(ctx, &eventbridge.ListTargetsByRuleInput{Rule: aws.String(rule)})
+	return "", err
+}
+
+// probeReceiveMessage receives at most one message, hiding it from other consumers for as short a time as possible (a
+// visibility timeout of 0 isn't sent by the SDK) so it is received again by the agent. It doesn't wait for a message,
+// even if the queue is set to long poll.
+func probeReceiveMessage(ctx context.Context, clients PermissionClients, _ *probeTarget) (string, error) {
+	if clients.QueueURL == "" {
+		return "no queue to check against, set events.queue-url", errNothingToProbe
+	}
+	_, err := clients.SQS.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(clients.QueueURL),
+		MaxNumberOfMessages: 1,
+		VisibilityTimeout:   1,
+		WaitTimeSeconds:     0,
+	}, withNoWaitTime)
+	return "", err
+}
+
+// withNoWaitTime sends the WaitTimeSeconds of 0 that the SDK leaves out as the zero value, without it SQS waits for as
+// long as the receive message wait time of the queue
+func withNoWaitTime(options *sqs.Options) {
+	options.APIOptions = append(options.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Serialize.Add(middleware.SerializeMiddlewareFunc(
+			"ECSInventoryNoWaitTime",
+			func(ctx context.Context, in middleware.SerializeInput, next middleware.SerializeHandler) (middleware.SerializeOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					req, err := setNoWaitTime(req)
+					if err != nil {
+						return middleware.SerializeOutput{}, middleware.Metadata{}, err
+					}
+					in.Request = req
+				}
+				return next.HandleSerialize(ctx, in)
+			},
+		), middleware.After)
+	})
+}
+
+// setNoWaitTime returns the serialized request with WaitTimeSeconds set to 0 in its JSON body
+func setNoWaitTime(req *smithyhttp.Request) (*smithyhttp.Request, error) {
+	input := map[string]json.RawMessage{}
+	if stream := req.GetStream(); stream != nil {
+		body, err := io.ReadAll(stream)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &input); err != nil {
+			return nil, fmt.Errorf("unable to set the wait time of the request: %w", err)
+		}
+	}
+	input["WaitTimeSeconds"] = json.RawMessage("0")
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return req.SetStream(bytes.NewReader(body))
+}
+
+// probeDeleteMessage deletes a message that doesn't exist, SQS still authorizes the call and rejects the receipt handle
+func probeDeleteMessage(ctx context.Context, clients PermissionClients, _ *probeTarget) (string, error) {
+	if clients.QueueURL == "" {
+		return "no queue to check against, set events.queue-url", errNothingToProbe
+	}
+	_, err := clients.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(clients.QueueURL),
+		ReceiptHandle: aws.String(probeReceiptHandle),
+	})
+	return "", err
+}
@@ -0,0 +1,235 @@
+package inventory
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deniedECSClient denies ListServices, DescribeServices and DescribeContainerInstances, and reports the cluster as missing for ListTasks
+type deniedECSClient struct {
+	mockECSClient
+}
+
+func (m *deniedECSClient) ListTasks(_ context.Context, _ *ecs.ListTasksInput, _ ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "ClusterNotFoundException", Message: "Cluster not found."}
+}
+
+func (m *deniedECSClient) ListServices(_ context.Context, _ *ecs.ListServicesInput, _ ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+}
+
+func (m *deniedECSClient) DescribeContainerInstances(_ context.Context, _ *ecs.DescribeContainerInstancesInput, _ ...func(*ecs.Options)) (*ecs.DescribeContainerInstancesOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+}
+
+func (m *deniedECSClient) DescribeServices(_ context.Context, _ *ecs.DescribeServicesInput, _ ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+}
+
+// probeSQSClient rejects the receipt handle of every message deleted, as SQS does for a message that doesn't exist
+type probeSQSClient struct {
+	received []*sqs.ReceiveMessageInput
+}
+
+func (m *probeSQSClient) ReceiveMessage(_ context.Context, input *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	m.received = append(m.received, input)
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (m *probeSQSClient) DeleteMessage(_ context.Context, _ *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "ReceiptHandleIsInvalid", Message: "The input receipt handle is invalid."}
+}
+
+func TestECSPermissionsCoverECSAPI(t *testing.T) {
+	actions := map[string]bool{}
+	for _, permission := range ECSPermissions {
+		actions[permission.Action] = true
+	}
+
+	api := reflect.TypeOf((*ECSAPI)(nil)).Elem()
+	for i := 0; i < api.NumMethod(); i++ {
+		assert.True(t, actions["ecs:"+api.Method(i).Name], "ECSAPI method %s has no entry in ECSPermissions", api.Method(i).Name)
+	}
+	assert.Len(t, ECSPermissions, api.NumMethod())
+}
+
+var allCollectOptions = CollectOptions{TaskDefinitions: true, EnvironmentSecrets: true, DeployableImages: true, StoppedTasks: true}
+
+func TestNeededECSPermissions(t *testing.T) {
+	actions := func(permissions []Permission) []string {
+		var names []string
+		for _, permission := range permissions {
+			names = append(names, permission.Action)
+		}
+		return names
+	}
+
+	assert.NotContains(t, actions(NeededECSPermissions(CollectOptions{})), "ecs:DescribeTaskDefinition")
+	assert.NotContains(t, actions(NeededECSPermissions(CollectOptions{})), "ecs:ListTaskDefinitionFamilies")
+	assert.NotContains(t, actions(NeededECSPermissions(CollectOptions{})), "ecs:ListTaskDefinitions")
+	assert.Contains(t, actions(NeededECSPermissions(CollectOptions{EnvironmentSecrets: true})), "ecs:DescribeTaskDefinition")
+	assert.NotContains(t, actions(NeededECSPermissions(CollectOptions{EnvironmentSecrets: true})), "ecs:ListTaskDefinitions")
+	assert.Equal(t, actions(ECSPermissions), actions(NeededECSPermissions(allCollectOptions)))
+}
+
+func TestCheckPermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  ECSAPI
+		cluster string
+		collect CollectOptions
+		dryRun  bool
+		check   func(t *testing.T, checks []PermissionCheck)
+	}{
+		{
+			name:    "all allowed against the first cluster",
+			client:  &mockECSClient{},
+			collect: allCollectOptions,
+			check: func(t *testing.T, checks []PermissionCheck) {
+				assert.Contains(t, checks[0].Detail, "arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1")
+				for _, check := range checks {
+					assert.True(t, check.Allowed, check.Action)
+					assert.False(t, check.Skipped, check.Action)
+					assert.NoError(t, check.Err, check.Action)
+				}
+			},
+		},
+		{
+			name:    "denied actions are reported and other api errors are allowed",
+			client:  &deniedECSClient{},
+			cluster: "cluster-1",
+			collect: allCollectOptions,
+			check: func(t *testing.T, checks []PermissionCheck) {
+				allowed := map[string]bool{}
+				for _, check := range checks {
+					assert.NoError(t, check.Err, check.Action)
+					allowed[check.Action] = check.Allowed
+				}
+				assert.Equal(t, map[string]bool{
+					"ecs:ListClusters":               true,
+					"ecs:ListTasks":                  true,
+					"ecs:ListServices":               false,
+					"ecs:DescribeTasks":              true,
+					"ecs:DescribeServices":           false,
+					"ecs:ListTagsForResource":        false,
+					"ecs:DescribeTaskDefinition":     true,
+					"ecs:ListTaskDefinitionFamilies": true,
+					"ecs:ListTaskDefinitions":        true,
+					"ecs:DescribeContainerInstances": false,
+					"ecs:DescribeClusters":           true,
+				}, allowed)
+				assert.Contains(t, checks[1].Detail, "Cluster not found.")
+				// there is no task, service or cluster ARN to list tags for
+				assert.True(t, checks[5].Skipped)
+			},
+		},
+		{
+			name:    "actions of optional details are only checked when collected",
+			client:  &mockECSClient{},
+			cluster: "cluster-1",
+			check: func(t *testing.T, checks []PermissionCheck) {
+				for _, check := range checks {
+					assert.NotEqual(t, "ecs:DescribeTaskDefinition", check.Action)
+					assert.NotEqual(t, "ecs:ListTaskDefinitionFamilies", check.Action)
+					assert.NotEqual(t, "ecs:ListTaskDefinitions", check.Action)
+				}
+			},
+		},
+		{
+			name:   "non api errors are returned",
+			client: &mockECSClient{ErrorOnListCluster: true},
+			check: func(t *testing.T, checks []PermissionCheck) {
+				assert.Error(t, checks[0].Err)
+				assert.False(t, checks[0].Allowed)
+				for _, check := range checks[1:] {
+					assert.True(t, check.Skipped, check.Action)
+				}
+			},
+		},
+		{
+			name:    "dry run makes no calls",
+			client:  &mockECSClient{ErrorOnListCluster: true, ErrorOnListTasks: true},
+			cluster: "cluster-1",
+			dryRun:  true,
+			check: func(t *testing.T, checks []PermissionCheck) {
+				for _, check := range checks {
+					assert.True(t, check.Skipped, check.Action)
+					assert.NoError(t, check.Err, check.Action)
+					assert.Contains(t, check.Detail, "against cluster cluster-1")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := CheckPermissions(context.Background(), PermissionClients{ECS: tt.client}, NeededECSPermissions(tt.collect), tt.cluster, tt.dryRun)
+			assert.Len(t, checks, len(NeededECSPermissions(tt.collect)))
+			tt.check(t, checks)
+		})
+	}
+}
+
+func TestCheckPermissionsOfOptionalFeatures(t *testing.T) {
+	permissions := PolicyOptions{Collect: CollectOptions{DeployableImages: true}, Events: true}.Permissions()
+	queue := &probeSQSClient{}
+	clients := PermissionClients{
+		ECS:      &mockECSClient{},
+		Events:   &mockEventBridgeClient{},
+		SQS:      queue,
+		QueueURL: queueURL,
+	}
+
+	checks := CheckPermissions(context.Background(), clients, permissions, "cluster-1", false)
+
+	allowed := map[string]bool{}
+	for _, check := range checks {
+		assert.NoError(t, check.Err, check.Action)
+		assert.False(t, check.Skipped, check.Action)
+		allowed[check.Action] = check.Allowed
+	}
+	assert.True(t, allowed["events:ListRules"])
+	assert.True(t, allowed["events:ListTargetsByRule"])
+	assert.True(t, allowed["sqs:ReceiveMessage"])
+	assert.True(t, allowed["sqs:DeleteMessage"])
+	// the message received is hidden for as short a time as possible
+	assert.Len(t, queue.received, 1)
+	assert.Equal(t, int32(1), queue.received[0].VisibilityTimeout)
+
+	clients.QueueURL = ""
+	for _, check := range CheckPermissions(context.Background(), clients, SQSPermissions, "cluster-1", false) {
+		assert.True(t, check.Skipped, check.Action)
+		assert.Contains(t, check.Detail, "events.queue-url")
+	}
+}
+
+func TestProbeReceiveMessageDoesNotWait(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client := sqs.New(sqs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	// the wait time of 0 is sent, rather than left out for the queue's own wait time to apply
+	_, err := probeReceiveMessage(context.Background(), PermissionClients{SQS: client, QueueURL: server.URL + "/123456789012/events"}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"WaitTimeSeconds":0`)
+	assert.Contains(t, string(body), `"VisibilityTimeout":1`)
+}
@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// MinFargateLinuxPlatformVersion is the oldest Fargate platform version for Linux that is still supported, tasks on
+// earlier versions (1.0.0 to 1.3.0) run on a platform that is end of life
+const MinFargateLinuxPlatformVersion = "1.4.0"
+
+// addTaskPlacement records where a task runs: its launch type, Fargate platform, capacity provider, CPU architecture,
+// OS family and availability zone
+func addTaskPlacement(t *reporter.Task, task ecstypes.Task) {
+	t.LaunchType = string(task.LaunchType)
+	t.PlatformVersion = aws.ToString(task.PlatformVersion)
+	t.CapacityProvider = aws.ToString(task.CapacityProviderName)
+	t.AvailabilityZone = aws.ToString(task.AvailabilityZone)
+	// the platform family is only set for tasks on Fargate, tasks on EC2 get it from their container instance
+	t.OSFamily = aws.ToString(task.PlatformFamily)
+	for _, attribute := range task.Attributes {
+		if aws.ToString(attribute.Name) == cpuArchitectureAttribute {
+			t.CPUArchitecture = aws.ToString(attribute.Value)
+		}
+	}
+	t.PlatformVersionEndOfLife = platformVersionEndOfLife(*t)
+}
+
+// platformVersionEndOfLife reports whether a task runs on a Fargate platform version that is no longer supported. Only
+// Linux has retired platform versions, every Windows platform version is still supported.
+func platformVersionEndOfLife(t reporter.Task) bool {
+	if t.LaunchType != string(ecstypes.LaunchTypeFargate) {
+		return false
+	}
+	if t.OSFamily != "" && !strings.EqualFold(t.OSFamily, "linux") {
+		return false
+	}
+	return versionBefore(t.PlatformVersion, MinFargateLinuxPlatformVersion)
+}
+
+// warnEndOfLifePlatformVersions logs a single warning for the cluster naming the tasks that run on a Fargate platform
+// version that is end of life
+func warnEndOfLifePlatformVersions(ctx context.Context, tasks []reporter.Task) {
+	eol := []string{}
+	for _, task := range tasks {
+		if task.PlatformVersionEndOfLife {
+			eol = append(eol, fmt.Sprintf("%s (%s)", task.ARN, task.PlatformVersion))
+		}
+	}
+	if len(eol) == 0 {
+		return
+	}
+	logger.FromContext(ctx).Warn(
+		fmt.Sprintf("Tasks are running on Fargate platform versions older than %s that are end of life, update the services to use a supported platform version", MinFargateLinuxPlatformVersion),
+		"taskCount", len(eol),
+		"tasks", eol,
+	)
+}
@@ -0,0 +1,110 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_addTaskPlacement(t *testing.T) {
+	tests := []struct {
+		name string
+		task ecstypes.Task
+		want reporter.Task
+	}{
+		{
+			name: "fargate",
+			task: ecstypes.Task{
+				LaunchType:           ecstypes.LaunchTypeFargate,
+				PlatformVersion:      aws.String("1.4.0"),
+				PlatformFamily:       aws.String("Linux"),
+				CapacityProviderName: aws.String("FARGATE_SPOT"),
+				AvailabilityZone:     aws.String("us-east-1a"),
+				Attributes: []ecstypes.Attribute{
+					{Name: aws.String("ecs.cpu-architecture"), Value: aws.String("arm64")},
+				},
+			},
+			want: reporter.Task{
+				LaunchType:       "FARGATE",
+				PlatformVersion:  "1.4.0",
+				CapacityProvider: "FARGATE_SPOT",
+				CPUArchitecture:  "arm64",
+				OSFamily:         "Linux",
+				AvailabilityZone: "us-east-1a",
+			},
+		},
+		{
+			name: "fargate platform version end of life",
+			task: ecstypes.Task{
+				LaunchType:      ecstypes.LaunchTypeFargate,
+				PlatformVersion: aws.String("1.3.0"),
+				PlatformFamily:  aws.String("Linux"),
+			},
+			want: reporter.Task{
+				LaunchType:               "FARGATE",
+				PlatformVersion:          "1.3.0",
+				PlatformVersionEndOfLife: true,
+				OSFamily:                 "Linux",
+			},
+		},
+		{
+			name: "fargate windows",
+			task: ecstypes.Task{
+				LaunchType:      ecstypes.LaunchTypeFargate,
+				PlatformVersion: aws.String("1.0.0"),
+				PlatformFamily:  aws.String("WINDOWS_SERVER_2019_CORE"),
+			},
+			want: reporter.Task{
+				LaunchType:      "FARGATE",
+				PlatformVersion: "1.0.0",
+				OSFamily:        "WINDOWS_SERVER_2019_CORE",
+			},
+		},
+		{
+			name: "ec2",
+			task: ecstypes.Task{
+				LaunchType:           ecstypes.LaunchTypeEc2,
+				CapacityProviderName: aws.String("asg-provider"),
+				AvailabilityZone:     aws.String("us-east-1b"),
+				Attributes: []ecstypes.Attribute{
+					{Name: aws.String("ecs.cpu-architecture"), Value: aws.String("x86_64")},
+				},
+			},
+			want: reporter.Task{
+				LaunchType:       "EC2",
+				CapacityProvider: "asg-provider",
+				CPUArchitecture:  "x86_64",
+				AvailabilityZone: "us-east-1b",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reporter.Task{}
+			addTaskPlacement(&got, tt.task)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_addContainerInstancesSetsPlatform(t *testing.T) {
+	report := reporter.Report{
+		ClusterARN: "cluster-1",
+		Tasks: []reporter.Task{
+			{ARN: "task-1", LaunchType: "EC2", ContainerInstance: &reporter.ContainerInstance{ARN: "instance-1"}},
+			{ARN: "task-2", LaunchType: "EC2", CPUArchitecture: "arm64", ContainerInstance: &reporter.ContainerInstance{ARN: "instance-1"}},
+		},
+	}
+	addContainerInstances(context.Background(), &mockECSClient{}, &report)
+
+	assert.Equal(t, "x86_64", report.Tasks[0].CPUArchitecture)
+	assert.Equal(t, "LINUX", report.Tasks[0].OSFamily)
+	// the task's own architecture is kept
+	assert.Equal(t, "arm64", report.Tasks[1].CPUArchitecture)
+	assert.Equal(t, "LINUX", report.Tasks[1].OSFamily)
+}
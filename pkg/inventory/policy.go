@@ -0,0 +1,160 @@
+package inventory
+
+import (
+	"strings"
+)
+
+const (
+	clusterResource           = "arn:{partition}:ecs:{region}:{account}:cluster/{cluster}"
+	taskResource              = "arn:{partition}:ecs:{region}:{account}:task/{cluster}/*"
+	serviceResource           = "arn:{partition}:ecs:{region}:{account}:service/{cluster}/*"
+	containerInstanceResource = "arn:{partition}:ecs:{region}:{account}:container-instance/{cluster}/*"
+	ruleResource              = "arn:{partition}:events:{region}:{account}:rule/*"
+	queueResource             = "arn:{partition}:sqs:{region}:{account}:*"
+)
+
+// EventBridgePermissions are needed to find the scheduled tasks whose images are reported as deployable
+var EventBridgePermissions = []Permission{
+	{Action: "events:ListRules", probe: probeListRules},
+	{Action: "events:ListTargetsByRule", Resources: []string{ruleResource}, probe: probeListTargetsByRule},
+}
+
+// SQSPermissions are needed to consume the ECS events delivered to an SQS queue in event mode
+var SQSPermissions = []Permission{
+	{Action: "sqs:ReceiveMessage", Resources: []string{queueResource}, probe: probeReceiveMessage},
+	{Action: "sqs:DeleteMessage", Resources: []string{queueResource}, probe: probeDeleteMessage},
+}
+
+// PolicyOptions selects the optional features to include in the policy, and what to scope it to
+type PolicyOptions struct {
+	// Account, Regions and Clusters scope the resources the policy applies to, any that are empty match everything
+	Account  string
+	Regions  []string
+	Clusters []string
+	// Collect adds the actions needed to collect the optional details, e.g. the EventBridge actions needed to find
+	// scheduled tasks when deployable images are collected
+	Collect CollectOptions
+	// Events adds the actions needed to consume ECS events from an SQS queue
+	Events bool
+}
+
+// PolicyDocument is an IAM policy document, see
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_grammar.html
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+type PolicyStatement struct {
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// Permissions returns every action used with the options, these are the actions the policy allows
+func (opts PolicyOptions) Permissions() []Permission {
+	permissions := NeededECSPermissions(opts.Collect)
+	if opts.Collect.DeployableImages {
+		permissions = append(permissions, EventBridgePermissions...)
+	}
+	if opts.Events {
+		permissions = append(permissions, SQSPermissions...)
+	}
+	return permissions
+}
+
+// Policy returns the least privilege IAM policy needed to build the inventory with the given options. Actions with
+// the same resources and conditions are grouped into a single statement.
+func Policy(opts PolicyOptions) PolicyDocument {
+	doc := PolicyDocument{Version: "2012-10-17"}
+	statements := map[string]int{}
+	for _, permission := range opts.Permissions() {
+		statement := opts.statementFor(permission)
+		key := strings.Join(statement.Resource, ",") + "|" + strings.Join(statement.Condition["ArnLike"]["ecs:cluster"], ",")
+		if i, ok := statements[key]; ok {
+			doc.Statement[i].Action = append(doc.Statement[i].Action, permission.Action)
+			continue
+		}
+		statements[key] = len(doc.Statement)
+		doc.Statement = append(doc.Statement, statement)
+	}
+	return doc
+}
+
+func (opts PolicyOptions) scoped() bool {
+	return opts.Account != "" || len(opts.Regions) > 0 || len(opts.Clusters) > 0
+}
+
+func (opts PolicyOptions) statementFor(permission Permission) PolicyStatement {
+	statement := PolicyStatement{Effect: "Allow", Action: []string{permission.Action}, Resource: []string{"*"}}
+	if !opts.scoped() {
+		return statement
+	}
+	switch {
+	case len(permission.Resources) > 0:
+		statement.Resource = opts.expand(permission.Resources)
+	case permission.ClusterCondition && len(opts.Clusters) > 0:
+		statement.Condition = map[string]map[string][]string{
+			"ArnLike": {"ecs:cluster": opts.expand([]string{clusterResource})},
+		}
+	}
+	return statement
+}
+
+// expand substitutes every combination of the configured region and cluster into each resource
+func (opts PolicyOptions) expand(resources []string) []string {
+	account := opts.Account
+	if account == "" {
+		account = "*"
+	}
+	regions := opts.Regions
+	if len(regions) == 0 {
+		regions = []string{"*"}
+	}
+	clusters := opts.Clusters
+	if len(clusters) == 0 {
+		clusters = []string{"*"}
+	}
+
+	var expanded []string
+	seen := map[string]bool{}
+	for _, resource := range resources {
+		for _, region := range regions {
+			for _, cluster := range clusters {
+				arn := strings.NewReplacer(
+					"{partition}", partitionFor(region),
+					"{region}", region,
+					"{account}", account,
+					"{cluster}", clusterName(cluster),
+				).Replace(resource)
+				// resources that aren't cluster specific (e.g. SQS queues) are the same for every cluster
+				if !seen[arn] {
+					seen[arn] = true
+					expanded = append(expanded, arn)
+				}
+			}
+		}
+	}
+	return expanded
+}
+
+// partitionFor returns the AWS partition a region is in
+func partitionFor(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// clusterName returns the name of a cluster given either its name or ARN
+func clusterName(cluster string) string {
+	if i := strings.LastIndex(cluster, ":cluster/"); i != -1 {
+		return cluster[i+len(":cluster/"):]
+	}
+	return cluster
+}
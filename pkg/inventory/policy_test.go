@@ -0,0 +1,206 @@
+package inventory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PolicyOptions
+		want string
+	}{
+		{
+			name: "unscoped",
+			opts: PolicyOptions{},
+			want: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListClusters", "ecs:ListTasks", "ecs:ListServices", "ecs:DescribeTasks", "ecs:DescribeServices", "ecs:ListTagsForResource", "ecs:DescribeContainerInstances", "ecs:DescribeClusters"],
+      "Resource": ["*"]
+    }
+  ]
+}`,
+		},
+		{
+			name: "task posture",
+			opts: PolicyOptions{Collect: CollectOptions{TaskDefinitions: true}},
+			want: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListClusters", "ecs:ListTasks", "ecs:ListServices", "ecs:DescribeTasks", "ecs:DescribeServices", "ecs:ListTagsForResource", "ecs:DescribeTaskDefinition", "ecs:DescribeContainerInstances", "ecs:DescribeClusters"],
+      "Resource": ["*"]
+    }
+  ]
+}`,
+		},
+		{
+			name: "scoped to clusters in a region with optional features",
+			opts: PolicyOptions{
+				Account:  "123456789012",
+				Regions:  []string{"us-east-1"},
+				Clusters: []string{"prod", "arn:aws:ecs:us-east-1:123456789012:cluster/staging"},
+				Collect:  CollectOptions{DeployableImages: true},
+			},
+			want: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListClusters", "ecs:DescribeTaskDefinition", "ecs:ListTaskDefinitionFamilies", "ecs:ListTaskDefinitions", "events:ListRules"],
+      "Resource": ["*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListTasks", "ecs:ListServices"],
+      "Resource": ["*"],
+      "Condition": {
+        "ArnLike": {
+          "ecs:cluster": [
+            "arn:aws:ecs:us-east-1:123456789012:cluster/prod",
+            "arn:aws:ecs:us-east-1:123456789012:cluster/staging"
+          ]
+        }
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeTasks"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:task/prod/*", "arn:aws:ecs:us-east-1:123456789012:task/staging/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeServices"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:service/prod/*", "arn:aws:ecs:us-east-1:123456789012:service/staging/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListTagsForResource"],
+      "Resource": [
+        "arn:aws:ecs:us-east-1:123456789012:task/prod/*",
+        "arn:aws:ecs:us-east-1:123456789012:task/staging/*",
+        "arn:aws:ecs:us-east-1:123456789012:service/prod/*",
+        "arn:aws:ecs:us-east-1:123456789012:service/staging/*"
+      ]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeContainerInstances"],
+      "Resource": [
+        "arn:aws:ecs:us-east-1:123456789012:container-instance/prod/*",
+        "arn:aws:ecs:us-east-1:123456789012:container-instance/staging/*"
+      ]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeClusters"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:cluster/prod", "arn:aws:ecs:us-east-1:123456789012:cluster/staging"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["events:ListTargetsByRule"],
+      "Resource": ["arn:aws:events:us-east-1:123456789012:rule/*"]
+    }
+  ]
+}`,
+		},
+		{
+			name: "scoped to a region only",
+			opts: PolicyOptions{Regions: []string{"cn-north-1"}},
+			want: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListClusters", "ecs:ListTasks", "ecs:ListServices"],
+      "Resource": ["*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeTasks"],
+      "Resource": ["arn:aws-cn:ecs:cn-north-1:*:task/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeServices"],
+      "Resource": ["arn:aws-cn:ecs:cn-north-1:*:service/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListTagsForResource"],
+      "Resource": ["arn:aws-cn:ecs:cn-north-1:*:task/*/*", "arn:aws-cn:ecs:cn-north-1:*:service/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeContainerInstances"],
+      "Resource": ["arn:aws-cn:ecs:cn-north-1:*:container-instance/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeClusters"],
+      "Resource": ["arn:aws-cn:ecs:cn-north-1:*:cluster/*"]
+    }
+  ]
+}`,
+		},
+		{
+			name: "event mode",
+			opts: PolicyOptions{Account: "123456789012", Regions: []string{"us-east-1"}, Events: true},
+			want: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListClusters", "ecs:ListTasks", "ecs:ListServices"],
+      "Resource": ["*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeTasks"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:task/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeServices"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:service/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:ListTagsForResource"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:task/*/*", "arn:aws:ecs:us-east-1:123456789012:service/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeContainerInstances"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:container-instance/*/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["ecs:DescribeClusters"],
+      "Resource": ["arn:aws:ecs:us-east-1:123456789012:cluster/*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["sqs:ReceiveMessage", "sqs:DeleteMessage"],
+      "Resource": ["arn:aws:sqs:us-east-1:123456789012:*"]
+    }
+  ]
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(Policy(tt.opts))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
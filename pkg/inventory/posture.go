@@ -0,0 +1,61 @@
+package inventory
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// taskPosture returns the security relevant configuration of a task, from its task definition
+func taskPosture(task ecstypes.Task, definition *ecstypes.TaskDefinition) *reporter.TaskPosture {
+	posture := &reporter.TaskPosture{
+		NetworkMode:    string(definition.NetworkMode),
+		PidMode:        string(definition.PidMode),
+		IpcMode:        string(definition.IpcMode),
+		ExecuteCommand: task.EnableExecuteCommand,
+	}
+
+	hostPaths := map[string]string{}
+	for _, volume := range definition.Volumes {
+		if volume.Host != nil && aws.ToString(volume.Host.SourcePath) != "" {
+			hostPaths[aws.ToString(volume.Name)] = aws.ToString(volume.Host.SourcePath)
+		}
+	}
+
+	for _, container := range definition.ContainerDefinitions {
+		user := aws.ToString(container.User)
+		c := reporter.ContainerPosture{
+			Name:                   aws.ToString(container.Name),
+			Privileged:             aws.ToBool(container.Privileged),
+			User:                   user,
+			RunsAsRoot:             isRootUser(user),
+			ReadonlyRootFilesystem: aws.ToBool(container.ReadonlyRootFilesystem),
+		}
+		if container.LinuxParameters != nil && container.LinuxParameters.Capabilities != nil {
+			c.CapabilitiesAdded = container.LinuxParameters.Capabilities.Add
+			c.CapabilitiesDropped = container.LinuxParameters.Capabilities.Drop
+		}
+		for _, mount := range container.MountPoints {
+			sourcePath, ok := hostPaths[aws.ToString(mount.SourceVolume)]
+			if !ok {
+				continue
+			}
+			c.HostMounts = append(c.HostMounts, reporter.HostMount{
+				SourcePath:    sourcePath,
+				ContainerPath: aws.ToString(mount.ContainerPath),
+				ReadOnly:      aws.ToBool(mount.ReadOnly),
+			})
+		}
+		posture.Containers = append(posture.Containers, c)
+	}
+	return posture
+}
+
+// isRootUser reports whether a container user (a user name or UID, optionally followed by a group, e.g. "0:0") is root
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "root" || name == "0"
+}
@@ -0,0 +1,179 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// useTaskDefinitionCache replaces the shared task definition cache with an empty one for the duration of the test
+func useTaskDefinitionCache(t *testing.T) {
+	previous := taskDefinitions
+	taskDefinitions = newTaskDefinitionCache()
+	t.Cleanup(func() {
+		taskDefinitions = previous
+	})
+}
+
+func Test_taskPosture(t *testing.T) {
+	task := ecstypes.Task{EnableExecuteCommand: true}
+	definition := &ecstypes.TaskDefinition{
+		NetworkMode: ecstypes.NetworkModeHost,
+		PidMode:     ecstypes.PidModeHost,
+		IpcMode:     ecstypes.IpcModeTask,
+		Volumes: []ecstypes.Volume{
+			{Name: aws.String("docker-socket"), Host: &ecstypes.HostVolumeProperties{SourcePath: aws.String("/var/run/docker.sock")}},
+			// a bind mount without a source path is managed by docker, not a path on the host
+			{Name: aws.String("scratch"), Host: &ecstypes.HostVolumeProperties{}},
+			{Name: aws.String("efs"), EfsVolumeConfiguration: &ecstypes.EFSVolumeConfiguration{FileSystemId: aws.String("fs-1234")}},
+		},
+		ContainerDefinitions: []ecstypes.ContainerDefinition{
+			{
+				Name:       aws.String("agent"),
+				Privileged: aws.Bool(true),
+				User:       aws.String("0:0"),
+				LinuxParameters: &ecstypes.LinuxParameters{
+					Capabilities: &ecstypes.KernelCapabilities{Add: []string{"SYS_ADMIN", "NET_ADMIN"}},
+				},
+				MountPoints: []ecstypes.MountPoint{
+					{SourceVolume: aws.String("docker-socket"), ContainerPath: aws.String("/var/run/docker.sock"), ReadOnly: aws.Bool(true)},
+					{SourceVolume: aws.String("scratch"), ContainerPath: aws.String("/tmp/scratch")},
+					{SourceVolume: aws.String("efs"), ContainerPath: aws.String("/data")},
+				},
+			},
+			{
+				Name:                   aws.String("app"),
+				User:                   aws.String("app"),
+				ReadonlyRootFilesystem: aws.Bool(true),
+				LinuxParameters: &ecstypes.LinuxParameters{
+					Capabilities: &ecstypes.KernelCapabilities{Drop: []string{"ALL"}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, &reporter.TaskPosture{
+		NetworkMode:    "host",
+		PidMode:        "host",
+		IpcMode:        "task",
+		ExecuteCommand: true,
+		Containers: []reporter.ContainerPosture{
+			{
+				Name:              "agent",
+				Privileged:        true,
+				User:              "0:0",
+				RunsAsRoot:        true,
+				CapabilitiesAdded: []string{"SYS_ADMIN", "NET_ADMIN"},
+				HostMounts: []reporter.HostMount{
+					{SourcePath: "/var/run/docker.sock", ContainerPath: "/var/run/docker.sock", ReadOnly: true},
+				},
+			},
+			{
+				Name:                   "app",
+				User:                   "app",
+				ReadonlyRootFilesystem: true,
+				CapabilitiesDropped:    []string{"ALL"},
+			},
+		},
+	}, taskPosture(task, definition))
+}
+
+func Test_isRootUser(t *testing.T) {
+	tests := []struct {
+		user string
+		want bool
+	}{
+		{user: "root", want: true},
+		{user: "0", want: true},
+		{user: "0:1000", want: true},
+		{user: "root:root", want: true},
+		{user: "1000", want: false},
+		{user: "1000:0", want: false},
+		{user: "nginx", want: false},
+		{user: "rooty", want: false},
+		// runs as the image's user
+		{user: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRootUser(tt.user))
+		})
+	}
+}
+
+func Test_fetchTasksMetadataPosture(t *testing.T) {
+	useTaskDefinitionCache(t)
+	client := &mockECSClient{}
+	tasks := []string{
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678-1234-1234-1234-000000000000",
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678-1234-1234-1234-111111111111",
+	}
+
+	got, err := fetchTasksMetadata(context.Background(), client, "cluster-1", tasks, newTaskDefinitionCollector(CollectOptions{TaskDefinitions: true}))
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for _, task := range got {
+		assert.Equal(t, &reporter.TaskPosture{
+			NetworkMode: "awsvpc",
+			Containers:  []reporter.ContainerPosture{{Name: "container-1"}},
+		}, task.Posture)
+	}
+	// both tasks run the same task definition, which is only described once, and not again in the next polling cycle
+	_, err = fetchTasksMetadata(context.Background(), client, "cluster-1", tasks, newTaskDefinitionCollector(CollectOptions{TaskDefinitions: true}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:1"}, client.DescribedTaskDefinitions)
+}
+
+func Test_fetchTasksMetadataPostureDisabled(t *testing.T) {
+	useTaskDefinitionCache(t)
+	client := &mockECSClient{}
+
+	got, err := fetchTasksMetadata(context.Background(), client, "cluster-1", []string{
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678-1234-1234-1234-000000000000",
+	}, newTaskDefinitionCollector(CollectOptions{}))
+
+	require.NoError(t, err)
+	assert.Nil(t, got[0].Posture)
+	assert.Empty(t, client.DescribedTaskDefinitions)
+}
+
+func Test_taskDefinitionCollectorLogsFailuresOnce(t *testing.T) {
+	useTaskDefinitionCache(t)
+	log := &recordingLogger{}
+	collector := newTaskDefinitionCollector(CollectOptions{TaskDefinitions: true, EnvironmentSecrets: true})
+	client := &mockECSClient{ErrorOnDescribeTaskDefinition: true}
+	task := ecstypes.Task{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/task-definition-1:1")}
+
+	for i := 0; i < 2; i++ {
+		got := reporter.Task{}
+		collector.addTask(context.Background(), client, &got, task)
+		assert.Nil(t, got.Posture)
+	}
+	collector.log(log)
+
+	assert.Len(t, client.DescribedTaskDefinitions, 1)
+	assert.Len(t, log.warnings, 1)
+	assert.Empty(t, collector.secretFindings())
+}
+
+func Test_taskDefinitionCacheIsBounded(t *testing.T) {
+	cache := newTaskDefinitionCache()
+	client := &mockECSClient{}
+	for i := 0; i < maxCachedTaskDefinitions; i++ {
+		_, err := cache.get(context.Background(), client, fmt.Sprintf("task-definition-%d:1", i))
+		require.NoError(t, err)
+	}
+	assert.Len(t, cache.definitions, maxCachedTaskDefinitions)
+
+	_, err := cache.get(context.Background(), client, "one-more")
+	require.NoError(t, err)
+	assert.Len(t, cache.definitions, 1)
+}
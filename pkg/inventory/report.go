@@ -3,15 +3,21 @@ package inventory
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/anchore/ecs-inventory/internal/health"
 	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/metrics"
+	"github.com/anchore/ecs-inventory/internal/redact"
+	"github.com/anchore/ecs-inventory/internal/tracing"
 	"github.com/anchore/ecs-inventory/internal/tracker"
 	"github.com/anchore/ecs-inventory/pkg/connection"
 	"github.com/anchore/ecs-inventory/pkg/reporter"
@@ -29,15 +35,27 @@ func reportToStdout(report reporter.Report) error {
 }
 
 func HandleReport(report reporter.Report, anchoreDetails connection.AnchoreInfo, quiet, dryRun bool) error {
+	return HandleReportWithContext(context.Background(), report, anchoreDetails, quiet, dryRun)
+}
+
+// HandleReportWithContext reports the inventory to Anchore, and shows it unless quiet, logging with the logger of the
+// context
+func HandleReportWithContext(ctx context.Context, report reporter.Report, anchoreDetails connection.AnchoreInfo, quiet, dryRun bool) error {
 	switch {
 	case dryRun:
-		logger.Log.Info("Dry run specified, not reporting inventory")
+		logger.FromContext(ctx).Info("Dry run specified, not reporting inventory")
 	case anchoreDetails.IsValid():
-		if err := reporter.Post(report, anchoreDetails); err != nil {
+		metrics.AnchorePosts.Inc()
+		start := time.Now()
+		err := reporter.PostWithContext(ctx, report, anchoreDetails)
+		metrics.AnchorePostDuration.Observe(time.Since(start).Seconds())
+		health.Default.AnchoreReported(err)
+		if err != nil {
+			metrics.AnchorePostFailures.Inc()
 			return fmt.Errorf("unable to report Inventory to Anchore: %w", err)
 		}
 	default:
-		logger.Log.Warn("Anchore details not specified, not reporting inventory")
+		logger.FromContext(ctx).Warn("Anchore details not specified, not reporting inventory")
 	}
 
 	if !quiet {
@@ -46,20 +64,61 @@ func HandleReport(report reporter.Report, anchoreDetails connection.AnchoreInfo,
 	return nil
 }
 
-// GetInventoryReportsForRegion collects inventory reports for a specified region.
+// CollectOptions selects the optional details that are collected in addition to the inventory
+type CollectOptions struct {
+	// TaskDefinitions describes the task definition of each task to report the task's security posture
+	TaskDefinitions bool
+	// EnvironmentSecrets describes the task definition of each task to report the environment variables that look like
+	// secrets, by name only
+	EnvironmentSecrets bool
+	// DeployableImages lists the task definition families and scheduled tasks of the region to report the images they
+	// declare as deployable
+	DeployableImages bool
+	// StoppedTasks also reports the tasks that stopped since the previous polling cycle, so tasks that run between two
+	// cycles are not missed
+	StoppedTasks bool
+}
+
+// RegionPoller reports the inventory of a region at every polling cycle. It remembers when the stopped tasks of each
+// cluster were last listed, so that a cycle only reports the tasks that stopped since the previous one.
+type RegionPoller struct {
+	stoppedTasks *pollTimes
+}
+
+func NewRegionPoller() *RegionPoller {
+	return &RegionPoller{stoppedTasks: newPollTimes()}
+}
+
+// GetInventoryReportsForRegion collects inventory reports for a specified region, without any of the optional details
 func GetInventoryReportsForRegion(region string, anchoreDetails connection.AnchoreInfo, quiet, dryRun bool) error {
-	ctx := context.Background()
+	return GetInventoryReportsForRegionWithContext(context.Background(), region, anchoreDetails, CollectOptions{}, quiet, dryRun)
+}
+
+// GetInventoryReportsForRegionWithContext collects inventory reports for a specified region, with the optional details
+// selected by collect, logging with the logger of the context and stopping once it is cancelled. Every stopped task ECS
+// still has is reported, use a RegionPoller to only report the tasks that stopped since the previous polling cycle.
+func GetInventoryReportsForRegionWithContext(
+	ctx context.Context,
+	region string,
+	anchoreDetails connection.AnchoreInfo,
+	collect CollectOptions,
+	quiet, dryRun bool,
+) error {
+	return NewRegionPoller().GetInventoryReports(ctx, region, anchoreDetails, collect, quiet, dryRun)
+}
+
+// GetInventoryReports collects inventory reports for a specified region
+func (p *RegionPoller) GetInventoryReports(ctx context.Context, region string, anchoreDetails connection.AnchoreInfo, collect CollectOptions, quiet, dryRun bool) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GetInventoryReportsForRegion", trace.WithAttributes(tracing.Region.String(region)))
+	defer func() { tracing.End(span, err) }()
 	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Getting Inventory Reports for region: %s", region))
-	logger.Log.Info("Getting Inventory Reports for region", "region", region)
+	log := logger.With(logger.FromContext(ctx), "region", region)
+	ctx = logger.NewContext(ctx, log)
+	log.Info("Getting Inventory Reports for region")
 
-	// Load AWS config
-	opts := []func(*config.LoadOptions) error{}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	cfg, err := LoadAWSConfig(ctx, region)
 	if err != nil {
-		logger.Log.Error("Failed to load AWS config", err)
+		log.Error("Failed to load AWS config", err)
 		return fmt.Errorf("failed to load aws config: %w", err)
 	}
 
@@ -68,41 +127,132 @@ func GetInventoryReportsForRegion(region string, anchoreDetails connection.Ancho
 		return err
 	}
 
-	ecsClient := ecs.NewFromConfig(cfg)
+	ecsClient := ecs.NewFromConfig(cfg, func(o *ecs.Options) {
+		o.APIOptions = append(o.APIOptions, withMetrics, withTracing)
+	})
 
 	clusters, err := fetchClusters(ctx, ecsClient)
 	if err != nil {
 		return err
 	}
+	metrics.SetClusters(region, clusters)
+	span.SetAttributes(tracing.Clusters.Int(len(clusters)))
 
+	var deployable *deployableImages
+	if collect.DeployableImages {
+		deployable = fetchDeployableImages(ctx, ecsClient, eventbridge.NewFromConfig(cfg))
+	}
+
+	return reportClusters(ctx, ecsClient, clusters, deployable, p.stoppedTasks, anchoreDetails, collect, quiet, dryRun)
+}
+
+// reportClusters reports the inventory of each cluster concurrently. A cluster whose inventory can't be collected
+// doesn't stop the others from being reported, but fails the polling cycle so that it is counted as such by the
+// metrics and the readiness check. Every cluster is inventoried before any is reported, since the deployable images
+// of a cluster depend on the services of the others.
+func reportClusters(
+	ctx context.Context,
+	ecsClient ECSAPI,
+	clusters []string,
+	deployable *deployableImages,
+	stoppedTasks *pollTimes,
+	anchoreDetails connection.AnchoreInfo,
+	collect CollectOptions,
+	quiet, dryRun bool,
+) error {
+	log := logger.FromContext(ctx)
 	var wg sync.WaitGroup
-	wg.Add(len(clusters))
+	var mu sync.Mutex
+	var errs []error
+	reports := make([]reporter.Report, len(clusters))
+	statuses := make([]health.ClusterStatus, len(clusters))
+	contexts := make([]context.Context, len(clusters))
 
-	for _, cluster := range clusters {
-		// capture cluster value
-		go func(cluster string) {
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func() {
 			defer wg.Done()
+			log := logger.With(log, "cluster", cluster, "account", accountFromARN(cluster))
+			ctx := logger.NewContext(ctx, log)
+			contexts[i] = ctx
+			statuses[i] = health.ClusterStatus{ClusterARN: cluster}
 
-			// You can reuse ecsClient; keeping same behavior as before
-			report, err := GetInventoryReportForCluster(ctx, cluster, ecsClient)
+			report, err := inventoryReportForCluster(ctx, cluster, ecsClient, collect, stoppedTasks)
 			if err != nil {
-				logger.Log.Error("Failed to get inventory report for cluster", err)
+				log.Error("Failed to get inventory report for cluster", err)
+				statuses[i].Error = err.Error()
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s: %w", cluster, err))
+				mu.Unlock()
+				return
 			}
+			reports[i] = ensureReferencedObjectsExist(ctx, report)
+			deployable.addServices(reports[i])
+		}()
+	}
+	wg.Wait()
 
-			// Only report if there are containers present in the cluster
-			if len(report.Containers) != 0 {
-				err = HandleReport(report, anchoreDetails, quiet, dryRun)
-				if err != nil {
-					logger.Log.Error("Failed to report inventory for cluster", err)
-					jsonReport, _ := json.Marshal(report)
-					logger.Log.Error("Failed payload", fmt.Errorf("report %s", jsonReport))
-				}
+	for i := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := statuses[i]
+			defer func() {
+				health.Default.ClusterFinished(status)
+			}()
+
+			report := reports[i]
+			if status.Error == "" {
+				report.DeployableImages = deployable.forCluster(report)
 			}
-		}(cluster)
-	}
+			status.Tasks = len(report.Tasks)
+			status.Containers = len(report.Containers)
 
+			if err := reportCluster(contexts[i], report, anchoreDetails, quiet, dryRun); err != nil {
+				status.ReportError = err.Error()
+			}
+		}()
+	}
 	wg.Wait()
-	return nil
+	return errors.Join(errs...)
+}
+
+// reportCluster reports the inventory of a cluster, if there are containers present in the cluster or images that can
+// be deployed to it. Partial reports are always sent, so a container instance that no longer runs any containers is
+// emptied. The payload of a report that fails is logged, with sensitive tags redacted.
+func reportCluster(ctx context.Context, report reporter.Report, anchoreDetails connection.AnchoreInfo, quiet, dryRun bool) error {
+	if !report.Partial && len(report.Containers) == 0 && len(report.DeployableImages) == 0 {
+		return nil
+	}
+	err := HandleReportWithContext(ctx, report, anchoreDetails, quiet, dryRun)
+	if err != nil {
+		log := logger.FromContext(ctx)
+		log.Error("Failed to report inventory for cluster", err)
+		jsonReport, _ := json.Marshal(redactReport(report))
+		log.Error("Failed payload", fmt.Errorf("report %s", jsonReport))
+	}
+	return err
+}
+
+// redactReport returns a copy of the report, safe for logging, with the values of sensitive tags redacted
+func redactReport(report reporter.Report) reporter.Report {
+	redacted := report
+	redacted.Tasks = make([]reporter.Task, len(report.Tasks))
+	for i, task := range report.Tasks {
+		task.Tags = redact.Default.Tags(task.Tags)
+		redacted.Tasks[i] = task
+	}
+	redacted.Services = make([]reporter.Service, len(report.Services))
+	for i, service := range report.Services {
+		service.Tags = redact.Default.Tags(service.Tags)
+		redacted.Services[i] = service
+	}
+	if report.Cluster != nil {
+		cluster := *report.Cluster
+		cluster.Tags = redact.Default.Tags(cluster.Tags)
+		redacted.Cluster = &cluster
+	}
+	return redacted
 }
 
 // ensures that the referenced objects in the report exist, and if not, creates them.
@@ -110,7 +260,8 @@ func GetInventoryReportsForRegion(region string, anchoreDetails connection.Ancho
 //
 // NOTE: in the future, this can be removed if the enterprise API is updated to accept reports with missing objects and create them on
 // the server side
-func ensureReferencedObjectsExist(report reporter.Report) reporter.Report {
+func ensureReferencedObjectsExist(ctx context.Context, report reporter.Report) reporter.Report {
+	log := logger.FromContext(ctx)
 	updatedReport := report
 
 	serviceARNs := map[string]bool{}
@@ -131,7 +282,7 @@ func ensureReferencedObjectsExist(report reporter.Report) reporter.Report {
 				updatedReport.Services = append(updatedReport.Services, reporter.Service{
 					ARN: task.ServiceARN,
 				})
-				logger.Log.Warn(
+				log.Warn(
 					"Service referenced in task not present in report, adding minimal service to report",
 					"service",
 					task.ServiceARN,
@@ -149,7 +300,7 @@ func ensureReferencedObjectsExist(report reporter.Report) reporter.Report {
 				TaskDefARN: unknown, // NOTE TaskDefARN is not a nullable field in the db, so we need to provide a value
 				ServiceARN: "",
 			})
-			logger.Log.Warn(
+			log.Warn(
 				"Task referenced in container not present in report, adding minimal task to report",
 				"task",
 				container.TaskARN,
@@ -179,17 +330,53 @@ func ensureReferencedObjectsExist(report reporter.Report) reporter.Report {
 
 // GetInventoryReportForCluster is an atomic method for getting in-use image results, for a cluster
 func GetInventoryReportForCluster(ctx context.Context, clusterARN string, ecsClient ECSAPI) (reporter.Report, error) {
+	return GetInventoryReportForClusterWithOptions(ctx, clusterARN, ecsClient, CollectOptions{})
+}
+
+// GetInventoryReportForClusterWithOptions gets the in-use image results for a cluster, with the optional details
+// selected by collect. Every stopped task ECS still has is reported.
+func GetInventoryReportForClusterWithOptions(ctx context.Context, clusterARN string, ecsClient ECSAPI, collect CollectOptions) (reporter.Report, error) {
+	report, err := inventoryReportForCluster(ctx, clusterARN, ecsClient, collect, newPollTimes())
+	if err != nil {
+		return reporter.Report{}, err
+	}
+	return ensureReferencedObjectsExist(ctx, report), nil
+}
+
+// inventoryReportForCluster returns the inventory of a cluster as found, without the placeholder services and tasks
+// added by ensureReferencedObjectsExist. The tasks that stopped since the stopped tasks of the cluster were last listed
+// are included when collected.
+func inventoryReportForCluster(
+	ctx context.Context,
+	clusterARN string,
+	ecsClient ECSAPI,
+	collect CollectOptions,
+	stoppedTasks *pollTimes,
+) (_ reporter.Report, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GetInventoryReportForCluster", trace.WithAttributes(tracing.Cluster.String(clusterARN)))
+	defer func() { tracing.End(span, err) }()
 	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Getting Inventory Report for cluster: %s", clusterARN))
-	logger.Log.Debug("Found cluster", "cluster", clusterARN)
+	log := logger.FromContext(ctx)
+	log.Debug("Found cluster")
 
 	report := reporter.Report{
 		Timestamp:  time.Now().UTC().Format(time.RFC3339),
 		ClusterARN: clusterARN,
 	}
-	tasks, err := fetchTasksFromCluster(ctx, ecsClient, clusterARN)
+	addClusterMetadata(ctx, ecsClient, &report)
+
+	taskARNs, err := fetchTasksFromCluster(ctx, ecsClient, clusterARN)
+	if err != nil {
+		return reporter.Report{}, err
+	}
+	// the tasks are described once for both their metadata and their containers
+	tasks, err := describeTasks(ctx, ecsClient, clusterARN, taskARNs)
 	if err != nil {
 		return reporter.Report{}, err
 	}
+	if collect.StoppedTasks {
+		tasks = stoppedTasks.addStoppedTasks(ctx, ecsClient, clusterARN, tasks)
+	}
 
 	servicesMeta := []reporter.Service{}
 	services, err := fetchServicesFromCluster(ctx, ecsClient, clusterARN)
@@ -197,7 +384,7 @@ func GetInventoryReportForCluster(ctx context.Context, clusterARN string, ecsCli
 		return reporter.Report{}, err
 	}
 	if len(services) == 0 {
-		logger.Log.Debug("No services found in cluster", "cluster", clusterARN)
+		log.Debug("No services found in cluster")
 	} else {
 		servicesMeta, err = fetchServicesMetadata(ctx, ecsClient, clusterARN, services)
 		if err != nil {
@@ -208,23 +395,48 @@ func GetInventoryReportForCluster(ctx context.Context, clusterARN string, ecsCli
 
 	// Must be at least one task to continue
 	if len(tasks) == 0 {
-		logger.Log.Debug("No tasks found in cluster", "cluster", clusterARN)
+		log.Debug("No tasks found in cluster")
 	} else {
-		logger.Log.Debug("Found tasks in cluster", "cluster", clusterARN, "taskCount", len(tasks))
+		log.Debug("Found tasks in cluster", "taskCount", len(tasks))
 
-		taskMeta, err := fetchTasksMetadata(ctx, ecsClient, clusterARN, tasks)
+		definitions := newTaskDefinitionCollector(collect)
+		taskMeta, err := tasksMetadata(ctx, ecsClient, tasks, definitions)
 		if err != nil {
 			return reporter.Report{}, err
 		}
 		report.Tasks = taskMeta
+		report.SecretFindings = definitions.secretFindings()
+		definitions.log(log)
+		addTaskDeployments(&report)
 
-		containers, err := fetchContainersFromTasks(ctx, ecsClient, clusterARN, tasks)
-		if err != nil {
-			return reporter.Report{}, err
-		}
-		report.Containers = containers
-		logger.Log.Info("Found containers in cluster", "cluster", clusterARN, "containerCount", len(containers))
+		report.Containers = containersFromTasks(ctx, tasks)
+		log.Info("Found containers in cluster", "containerCount", len(report.Containers))
+
+		addContainerInstances(ctx, ecsClient, &report)
+		warnEndOfLifePlatformVersions(ctx, report.Tasks)
+		report.ImageDrift = imageDrift(report)
+		warnImageDrift(ctx, report.ImageDrift)
 	}
+	report.Findings = outdatedRevisionFindings(report, time.Now())
+	warnOutdatedRevisions(ctx, report.Findings)
+
+	recordClusterMetrics(report)
+	span.SetAttributes(
+		tracing.Tasks.Int(len(report.Tasks)),
+		tracing.Services.Int(len(report.Services)),
+		tracing.Containers.Int(len(report.Containers)),
+	)
+	return report, nil
+}
 
-	return ensureReferencedObjectsExist(report), nil
+func recordClusterMetrics(report reporter.Report) {
+	missingDigests := 0
+	for _, container := range report.Containers {
+		if container.ImageDigest == "" {
+			missingDigests++
+		}
+	}
+	metrics.Tasks.WithLabelValues(report.ClusterARN).Set(float64(len(report.Tasks)))
+	metrics.Containers.WithLabelValues(report.ClusterARN).Set(float64(len(report.Containers)))
+	metrics.ContainersMissingDigest.WithLabelValues(report.ClusterARN).Set(float64(missingDigests))
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/redact"
 	"github.com/anchore/ecs-inventory/pkg/connection"
 	"github.com/anchore/ecs-inventory/pkg/reporter"
 )
@@ -29,6 +30,19 @@ func TestGetInventoryReportForCluster(t *testing.T) {
 	assert.Equal(t, 4, len(report.Containers))
 }
 
+func Test_reportClusters(t *testing.T) {
+	clusters := []string{cluster1ARN, cluster2ARN}
+
+	err := reportClusters(context.Background(), &mockECSClient{}, clusters, nil, newPollTimes(), connection.AnchoreInfo{}, CollectOptions{}, true, true)
+	assert.NoError(t, err)
+
+	// every cluster is still attempted, and the failure of each is returned so the cycle isn't counted as successful
+	err = reportClusters(context.Background(), &mockECSClient{ErrorOnListTasks: true}, clusters, nil, newPollTimes(), connection.AnchoreInfo{}, CollectOptions{}, true, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cluster "+cluster1ARN+": ")
+	assert.Contains(t, err.Error(), "cluster "+cluster2ARN+": ")
+}
+
 func TestHandleReport(t *testing.T) {
 	testReport := reporter.Report{
 		Timestamp:  "2024-01-01T00:00:00Z",
@@ -135,6 +149,41 @@ func Test_reportToStdout(t *testing.T) {
 	assert.Equal(t, "nginx:latest", decoded.Containers[0].ImageTag)
 }
 
+func Test_redactReport(t *testing.T) {
+	redact.Default.SetTagKeys([]string{"*secret*"})
+	t.Cleanup(func() {
+		redact.Default.SetTagKeys(nil)
+	})
+
+	report := reporter.Report{
+		ClusterARN: "arn:aws:ecs:us-east-1:123456789012:cluster/test",
+		Tasks: []reporter.Task{
+			{
+				ARN:  "arn:aws:ecs:us-east-1:123456789012:task/test/task1",
+				Tags: map[string]string{"db-secret": "hunter2", "team": "platform"},
+			},
+		},
+		Services: []reporter.Service{
+			{
+				ARN:  "arn:aws:ecs:us-east-1:123456789012:service/test/service1",
+				Tags: map[string]string{"SECRET_KEY": "abc123"},
+			},
+		},
+		Cluster: &reporter.Cluster{
+			Name: "test",
+			Tags: map[string]string{"cluster-secret": "s3cr3t", "environment": "production"},
+		},
+	}
+
+	redacted := redactReport(report)
+
+	assert.Equal(t, map[string]string{"db-secret": redact.Redacted, "team": "platform"}, redacted.Tasks[0].Tags)
+	assert.Equal(t, map[string]string{"SECRET_KEY": redact.Redacted}, redacted.Services[0].Tags)
+	assert.Equal(t, map[string]string{"cluster-secret": redact.Redacted, "environment": "production"}, redacted.Cluster.Tags)
+	assert.Equal(t, "s3cr3t", report.Cluster.Tags["cluster-secret"], "the original report must not be modified")
+	assert.Equal(t, "hunter2", report.Tasks[0].Tags["db-secret"], "the original report must not be modified")
+}
+
 func Test_ensureReferencedObjectsExist(t *testing.T) {
 	type args struct {
 		report reporter.Report
@@ -399,7 +448,7 @@ func Test_ensureReferencedObjectsExist(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ensureReferencedObjectsExist(tt.args.report)
+			got := ensureReferencedObjectsExist(context.Background(), tt.args.report)
 			assert.Equal(t, tt.want, got)
 		})
 	}
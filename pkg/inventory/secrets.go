@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// rule IDs reported for environment variables that look like secrets
+const (
+	ruleSecretName  = "secret-name"
+	ruleHighEntropy = "high-entropy"
+)
+
+// secretFormats are the formats of well known credentials, matched anywhere in an environment variable's value
+var secretFormats = []struct {
+	ruleID  string
+	pattern *regexp.Regexp
+}{
+	{ruleID: "aws-access-key-id", pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ruleID: "github-token", pattern: regexp.MustCompile(`\b(gh[pousr]_[0-9A-Za-z]{36,}|github_pat_[0-9A-Za-z_]{22,})\b`)},
+	{ruleID: "slack-token", pattern: regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`)},
+	{ruleID: "stripe-secret-key", pattern: regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{ruleID: "google-api-key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{ruleID: "private-key", pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{ruleID: "jwt", pattern: regexp.MustCompile(`\beyJ[0-9A-Za-z_-]{8,}\.eyJ[0-9A-Za-z_-]{8,}\.[0-9A-Za-z_-]{8,}`)},
+	{ruleID: "url-credentials", pattern: regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+var (
+	// secretNamePattern matches the names of environment variables that usually hold a secret
+	secretNamePattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIAL)`)
+	// notSecretNamePattern matches the names of environment variables that refer to a secret rather than holding it
+	// (e.g. SECRET_ARN or TOKEN_URL), or configure how it is used (e.g. TOKEN_TIMEOUT)
+	notSecretNamePattern = regexp.MustCompile(`(?i)_(ARN|NAME|FILE|PATH|URL|URI|ENDPOINT|HOST|PORT|REGION|LENGTH|TIMEOUT|TTL|EXPIRY|ENABLED)$`)
+	// entropyCharset matches values made up of the characters used by random tokens (base64 and base64url)
+	entropyCharset = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+	// hexCharset matches hex values, which are more often digests and commit hashes than secrets
+	hexCharset = regexp.MustCompile(`^[A-Fa-f0-9-]+$`)
+)
+
+const (
+	// minEntropyLength is the shortest value checked for high entropy, shorter random strings are too common (and too
+	// weak) to report
+	minEntropyLength = 20
+	// minEntropy is the entropy, in bits per character, above which a value is reported as a likely random secret
+	minEntropy = 4.0
+)
+
+// scanEnvironment returns the environment variables of the containers in a task definition that look like secrets, by
+// their name, their entropy or the format of a known credential. Only the names of the variables are returned.
+func scanEnvironment(taskDefARN string, definition *ecstypes.TaskDefinition) []reporter.SecretFinding {
+	var findings []reporter.SecretFinding
+	for _, container := range definition.ContainerDefinitions {
+		for _, variable := range container.Environment {
+			ruleIDs := secretRules(aws.ToString(variable.Name), aws.ToString(variable.Value))
+			if len(ruleIDs) == 0 {
+				continue
+			}
+			findings = append(findings, reporter.SecretFinding{
+				TaskDefARN: taskDefARN,
+				Container:  aws.ToString(container.Name),
+				Variable:   aws.ToString(variable.Name),
+				RuleIDs:    ruleIDs,
+			})
+		}
+	}
+	return findings
+}
+
+// secretRules returns the IDs of the rules an environment variable matches
+func secretRules(name, value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var ruleIDs []string
+	if secretNamePattern.MatchString(name) && !notSecretNamePattern.MatchString(name) && !isSecretReference(value) {
+		ruleIDs = append(ruleIDs, ruleSecretName)
+	}
+	if isHighEntropy(value) {
+		ruleIDs = append(ruleIDs, ruleHighEntropy)
+	}
+	for _, format := range secretFormats {
+		if format.pattern.MatchString(value) {
+			ruleIDs = append(ruleIDs, format.ruleID)
+		}
+	}
+	return ruleIDs
+}
+
+// isSecretReference reports whether a value is not itself a secret, such as a flag, a number, an ARN or a path to a
+// file holding the secret
+func isSecretReference(value string) bool {
+	if _, err := strconv.ParseBool(value); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return true
+	}
+	return strings.HasPrefix(value, "arn:") || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "ssm:")
+}
+
+// isHighEntropy reports whether a value looks like a randomly generated token. Hex values (including UUIDs) are not
+// checked, since they can't be told apart from digests, commit hashes and IDs by their entropy.
+func isHighEntropy(value string) bool {
+	if len(value) < minEntropyLength || !entropyCharset.MatchString(value) || hexCharset.MatchString(value) {
+		return false
+	}
+	return shannonEntropy(value) >= minEntropy
+}
+
+// shannonEntropy returns the entropy of a string in bits per character
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, r := range value {
+		counts[r]++
+	}
+	length := float64(len(value))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
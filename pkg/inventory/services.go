@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// addServiceDetails records the counts, deployment configuration, deployments and task sets of a service
+func addServiceDetails(s *reporter.Service, service ecstypes.Service) {
+	s.DesiredCount = int(service.DesiredCount)
+	s.RunningCount = int(service.RunningCount)
+	s.PendingCount = int(service.PendingCount)
+	s.TaskDefARN = aws.ToString(service.TaskDefinition)
+	if service.DeploymentController != nil {
+		s.DeploymentController = string(service.DeploymentController.Type)
+	}
+	s.SchedulingStrategy = string(service.SchedulingStrategy)
+
+	for _, deployment := range service.Deployments {
+		s.Deployments = append(s.Deployments, reporter.Deployment{
+			ID:                 aws.ToString(deployment.Id),
+			Status:             aws.ToString(deployment.Status),
+			TaskDefARN:         aws.ToString(deployment.TaskDefinition),
+			DesiredCount:       int(deployment.DesiredCount),
+			RunningCount:       int(deployment.RunningCount),
+			PendingCount:       int(deployment.PendingCount),
+			FailedTasks:        int(deployment.FailedTasks),
+			RolloutState:       string(deployment.RolloutState),
+			RolloutStateReason: aws.ToString(deployment.RolloutStateReason),
+			CreatedAt:          formatTime(deployment.CreatedAt),
+			UpdatedAt:          formatTime(deployment.UpdatedAt),
+		})
+	}
+	for _, taskSet := range service.TaskSets {
+		s.TaskSets = append(s.TaskSets, reporter.TaskSet{
+			ID:              aws.ToString(taskSet.Id),
+			ARN:             aws.ToString(taskSet.TaskSetArn),
+			Status:          aws.ToString(taskSet.Status),
+			TaskDefARN:      aws.ToString(taskSet.TaskDefinition),
+			ExternalID:      aws.ToString(taskSet.ExternalId),
+			DesiredCount:    int(taskSet.ComputedDesiredCount),
+			RunningCount:    int(taskSet.RunningCount),
+			PendingCount:    int(taskSet.PendingCount),
+			StabilityStatus: string(taskSet.StabilityStatus),
+		})
+	}
+}
+
+// addTaskDeployments records the deployment or task set each service task belongs to, so that during a rollout the
+// images of the old and new deployments can be told apart. ECS starts the tasks of a deployment or task set with its
+// ID as the task's startedBy.
+func addTaskDeployments(report *reporter.Report) {
+	deploymentIDs := map[string]map[string]bool{}
+	for _, service := range report.Services {
+		ids := map[string]bool{}
+		for _, deployment := range service.Deployments {
+			ids[deployment.ID] = true
+		}
+		for _, taskSet := range service.TaskSets {
+			ids[taskSet.ID] = true
+		}
+		deploymentIDs[service.ARN] = ids
+	}
+	for i, task := range report.Tasks {
+		if task.ServiceARN != "" && deploymentIDs[task.ServiceARN][task.StartedBy] {
+			report.Tasks[i].DeploymentID = task.StartedBy
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+func Test_addServiceDetails(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	service := ecstypes.Service{
+		DesiredCount:         3,
+		RunningCount:         2,
+		PendingCount:         1,
+		TaskDefinition:       aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:2"),
+		DeploymentController: &ecstypes.DeploymentController{Type: ecstypes.DeploymentControllerTypeEcs},
+		SchedulingStrategy:   ecstypes.SchedulingStrategyReplica,
+		Deployments: []ecstypes.Deployment{
+			{
+				Id:             aws.String("ecs-svc/2"),
+				Status:         aws.String("PRIMARY"),
+				TaskDefinition: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:2"),
+				DesiredCount:   3,
+				RunningCount:   1,
+				PendingCount:   1,
+				RolloutState:   ecstypes.DeploymentRolloutStateInProgress,
+				CreatedAt:      &created,
+				UpdatedAt:      &created,
+			},
+			{
+				Id:             aws.String("ecs-svc/1"),
+				Status:         aws.String("ACTIVE"),
+				TaskDefinition: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+				RunningCount:   1,
+				FailedTasks:    2,
+				RolloutState:   ecstypes.DeploymentRolloutStateCompleted,
+			},
+		},
+	}
+
+	got := reporter.Service{ARN: "service-1"}
+	addServiceDetails(&got, service)
+
+	assert.Equal(t, reporter.Service{
+		ARN:                  "service-1",
+		DesiredCount:         3,
+		RunningCount:         2,
+		PendingCount:         1,
+		TaskDefARN:           "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2",
+		DeploymentController: "ECS",
+		SchedulingStrategy:   "REPLICA",
+		Deployments: []reporter.Deployment{
+			{
+				ID:           "ecs-svc/2",
+				Status:       "PRIMARY",
+				TaskDefARN:   "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2",
+				DesiredCount: 3,
+				RunningCount: 1,
+				PendingCount: 1,
+				RolloutState: "IN_PROGRESS",
+				CreatedAt:    "2024-01-02T03:04:05Z",
+				UpdatedAt:    "2024-01-02T03:04:05Z",
+			},
+			{
+				ID:           "ecs-svc/1",
+				Status:       "ACTIVE",
+				TaskDefARN:   "arn:aws:ecs:us-east-1:123456789012:task-definition/web:1",
+				RunningCount: 1,
+				FailedTasks:  2,
+				RolloutState: "COMPLETED",
+			},
+		},
+	}, got)
+}
+
+func Test_addServiceDetailsTaskSets(t *testing.T) {
+	service := ecstypes.Service{
+		DeploymentController: &ecstypes.DeploymentController{Type: ecstypes.DeploymentControllerTypeCodeDeploy},
+		TaskSets: []ecstypes.TaskSet{
+			{
+				Id:                   aws.String("ecs-svc/blue"),
+				TaskSetArn:           aws.String("arn:aws:ecs:us-east-1:123456789012:task-set/cluster-1/service-1/ecs-svc/blue"),
+				Status:               aws.String("PRIMARY"),
+				TaskDefinition:       aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+				ComputedDesiredCount: 2,
+				RunningCount:         2,
+				StabilityStatus:      ecstypes.StabilityStatusSteadyState,
+			},
+			{
+				Id:                   aws.String("ecs-svc/green"),
+				Status:               aws.String("ACTIVE"),
+				TaskDefinition:       aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:2"),
+				ExternalId:           aws.String("d-ABCDEF123"),
+				ComputedDesiredCount: 2,
+				PendingCount:         2,
+				StabilityStatus:      ecstypes.StabilityStatusStabilizing,
+			},
+		},
+	}
+
+	got := reporter.Service{}
+	addServiceDetails(&got, service)
+
+	assert.Equal(t, "CODE_DEPLOY", got.DeploymentController)
+	assert.Empty(t, got.Deployments)
+	assert.Equal(t, []reporter.TaskSet{
+		{
+			ID:              "ecs-svc/blue",
+			ARN:             "arn:aws:ecs:us-east-1:123456789012:task-set/cluster-1/service-1/ecs-svc/blue",
+			Status:          "PRIMARY",
+			TaskDefARN:      "arn:aws:ecs:us-east-1:123456789012:task-definition/web:1",
+			DesiredCount:    2,
+			RunningCount:    2,
+			StabilityStatus: "STEADY_STATE",
+		},
+		{
+			ID:              "ecs-svc/green",
+			Status:          "ACTIVE",
+			TaskDefARN:      "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2",
+			ExternalID:      "d-ABCDEF123",
+			DesiredCount:    2,
+			PendingCount:    2,
+			StabilityStatus: "STABILIZING",
+		},
+	}, got.TaskSets)
+}
+
+func Test_addTaskDeployments(t *testing.T) {
+	report := reporter.Report{
+		Services: []reporter.Service{
+			{ARN: "service-1", Deployments: []reporter.Deployment{{ID: "ecs-svc/1"}, {ID: "ecs-svc/2"}}},
+			{ARN: "service-2", TaskSets: []reporter.TaskSet{{ID: "ecs-svc/blue"}}},
+		},
+		Tasks: []reporter.Task{
+			{ARN: "task-1", ServiceARN: "service-1", StartedBy: "ecs-svc/1"},
+			{ARN: "task-2", ServiceARN: "service-1", StartedBy: "ecs-svc/2"},
+			{ARN: "task-3", ServiceARN: "service-2", StartedBy: "ecs-svc/blue"},
+			// the deployment belongs to another service
+			{ARN: "task-4", ServiceARN: "service-2", StartedBy: "ecs-svc/1"},
+			// not started by a service
+			{ARN: "task-5", StartedBy: "ecs-svc/1"},
+		},
+	}
+
+	addTaskDeployments(&report)
+
+	got := map[string]string{}
+	for _, task := range report.Tasks {
+		got[task.ARN] = task.DeploymentID
+	}
+	assert.Equal(t, map[string]string{
+		"task-1": "ecs-svc/1",
+		"task-2": "ecs-svc/2",
+		"task-3": "ecs-svc/blue",
+		"task-4": "",
+		"task-5": "",
+	}, got)
+}
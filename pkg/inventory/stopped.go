@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracker"
+)
+
+// pollTimes records when the stopped tasks of each cluster were last listed. It is kept for as long as a region is
+// polled, so that each polling cycle only reports the tasks that stopped since the previous one.
+type pollTimes struct {
+	mu     sync.Mutex
+	listed map[string]time.Time
+}
+
+func newPollTimes() *pollTimes {
+	return &pollTimes{listed: map[string]time.Time{}}
+}
+
+// previous returns when the stopped tasks of a cluster were last listed, the zero time if they never have been
+func (p *pollTimes) previous(cluster string) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.listed[cluster]
+}
+
+func (p *pollTimes) record(cluster string, listed time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listed[cluster] = listed
+}
+
+// addStoppedTasks adds the tasks of a cluster that stopped since its stopped tasks were last listed to the described
+// running tasks, along with tasks that are still stopping. ECS keeps stopped tasks for about an hour, so the first time
+// a cluster is polled every stopped task ECS still has is added. The stopped tasks are supplementary to the inventory,
+// so failing to list them is logged rather than failing the report, and they are listed again in full next time.
+func (p *pollTimes) addStoppedTasks(ctx context.Context, client ECSAPI, cluster string, tasks []ecstypes.Task) []ecstypes.Task {
+	listed := time.Now()
+	stopped, err := fetchStoppedTasksFromCluster(ctx, client, cluster, p.previous(cluster))
+	if err != nil {
+		logger.FromContext(ctx).Warn("Unable to list stopped tasks, only running tasks will be reported", "err", err)
+		return tasks
+	}
+	p.record(cluster, listed)
+
+	seen := map[string]bool{}
+	for _, task := range tasks {
+		seen[aws.ToString(task.TaskArn)] = true
+	}
+	for _, task := range stopped {
+		// a task stopped between listing the running and stopped tasks is in both
+		if !seen[aws.ToString(task.TaskArn)] {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// fetchStoppedTasksFromCluster describes the tasks of a cluster whose desired status is STOPPED, and that either
+// stopped after the given time or have not finished stopping
+func fetchStoppedTasksFromCluster(ctx context.Context, client ECSAPI, cluster string, since time.Time) ([]ecstypes.Task, error) {
+	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Fetching stopped tasks from cluster: %s", cluster))
+	var arns []string
+	input := &ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		DesiredStatus: ecstypes.DesiredStatusStopped,
+	}
+	for {
+		result, err := client.ListTasks(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, result.TaskArns...)
+		if aws.ToString(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+	if len(arns) == 0 {
+		return nil, nil
+	}
+
+	// the stop time is only known once the tasks are described
+	tasks, err := describeTasks(ctx, client, cluster, arns)
+	if err != nil {
+		return nil, err
+	}
+	var stopped []ecstypes.Task
+	for _, task := range tasks {
+		if task.StoppedAt == nil || task.StoppedAt.After(since) {
+			stopped = append(stopped, task)
+		}
+	}
+	return stopped, nil
+}
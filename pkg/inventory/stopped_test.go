@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/internal/logger"
+)
+
+const (
+	runningTaskARN  = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678-1234-1234-1234-000000000000"
+	stoppedTaskARN  = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/stopped"
+	oldTaskARN      = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/stopped-before-previous-poll"
+	stoppingTaskARN = "arn:aws:ecs:us-east-1:123456789012:task/cluster-1/stopping"
+)
+
+// previousPoll is when the stopped tasks returned by stoppedTasksECSClient were previously listed
+var previousPoll = time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+
+// stoppedTasksECSClient returns the stopped tasks over two pages: one that stopped after the previous poll, one that
+// stopped before it, one that is still stopping, and a running task that stopped between listing the running and the
+// stopped tasks
+type stoppedTasksECSClient struct {
+	mockECSClient
+	ErrorOnListStoppedTasks bool
+}
+
+func (m *stoppedTasksECSClient) ListTasks(ctx context.Context, input *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	if input.DesiredStatus != ecstypes.DesiredStatusStopped {
+		return m.mockECSClient.ListTasks(ctx, input, optFns...)
+	}
+	if m.ErrorOnListStoppedTasks {
+		return nil, errors.New("list stopped tasks error")
+	}
+	if input.NextToken == nil {
+		return &ecs.ListTasksOutput{TaskArns: []string{stoppedTaskARN, oldTaskARN}, NextToken: aws.String("page-2")}, nil
+	}
+	return &ecs.ListTasksOutput{TaskArns: []string{stoppingTaskARN, runningTaskARN}}, nil
+}
+
+func (m *stoppedTasksECSClient) DescribeTasks(ctx context.Context, input *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	described, err := m.mockECSClient.DescribeTasks(ctx, input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	for _, arn := range input.Tasks {
+		task := ecstypes.Task{
+			TaskArn:           aws.String(arn),
+			ClusterArn:        aws.String(cluster1ARN),
+			TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/batch-job:1"),
+			DesiredStatus:     aws.String("STOPPED"),
+			LastStatus:        aws.String("STOPPED"),
+			Containers: []ecstypes.Container{
+				{
+					ContainerArn: aws.String(arn + "/job"),
+					Image:        aws.String("batch-job:latest"),
+					ImageDigest:  aws.String("sha256:1234567890123456789012345678901234567890123456789012345678904444"),
+					LastStatus:   aws.String("STOPPED"),
+					ExitCode:     aws.Int32(0),
+				},
+			},
+			StopCode:      ecstypes.TaskStopCodeEssentialContainerExited,
+			StoppedReason: aws.String("Essential container in task exited"),
+		}
+		switch arn {
+		case stoppedTaskARN:
+			task.StoppedAt = aws.Time(previousPoll.Add(time.Minute))
+		case oldTaskARN:
+			task.StoppedAt = aws.Time(previousPoll.Add(-time.Minute))
+		case stoppingTaskARN:
+			task.LastStatus = aws.String("DEACTIVATING")
+		default:
+			continue
+		}
+		described.Tasks = append(described.Tasks, task)
+	}
+	return described, nil
+}
+
+// taskARNs returns the ARNs of described tasks
+func taskARNs(tasks []ecstypes.Task) []string {
+	var arns []string
+	for _, task := range tasks {
+		arns = append(arns, aws.ToString(task.TaskArn))
+	}
+	return arns
+}
+
+func Test_fetchStoppedTasksFromCluster(t *testing.T) {
+	tests := []struct {
+		name  string
+		since time.Time
+		want  []string
+	}{
+		{
+			name:  "stopped since the previous poll",
+			since: previousPoll,
+			// the running task has not stopped yet
+			want: []string{runningTaskARN, stoppedTaskARN, stoppingTaskARN},
+		},
+		{
+			name: "first poll",
+			want: []string{runningTaskARN, stoppedTaskARN, oldTaskARN, stoppingTaskARN},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetchStoppedTasksFromCluster(context.Background(), &stoppedTasksECSClient{}, cluster1ARN, tt.since)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, taskARNs(got))
+		})
+	}
+}
+
+func Test_addStoppedTasks(t *testing.T) {
+	polls := newPollTimes()
+	polls.record(cluster1ARN, previousPoll)
+	start := time.Now()
+	client := &stoppedTasksECSClient{}
+	running, err := describeTasks(context.Background(), client, cluster1ARN, []string{runningTaskARN})
+	require.NoError(t, err)
+
+	tasks := polls.addStoppedTasks(context.Background(), client, cluster1ARN, running)
+
+	// the running task is not added again, and the stopped tasks are added as described
+	assert.Equal(t, []string{runningTaskARN, stoppedTaskARN, stoppingTaskARN}, taskARNs(tasks))
+	assert.Equal(t, "Essential container in task exited", aws.ToString(tasks[1].StoppedReason))
+	assert.False(t, polls.previous(cluster1ARN).Before(start))
+	assert.True(t, polls.previous(cluster2ARN).IsZero())
+}
+
+func Test_addStoppedTasksIgnoresErrors(t *testing.T) {
+	polls := newPollTimes()
+	polls.record(cluster1ARN, previousPoll)
+	log := &recordingLogger{}
+	ctx := logger.NewContext(context.Background(), log)
+	running := []ecstypes.Task{{TaskArn: aws.String(runningTaskARN)}}
+
+	tasks := polls.addStoppedTasks(ctx, &stoppedTasksECSClient{ErrorOnListStoppedTasks: true}, cluster1ARN, running)
+
+	assert.Equal(t, running, tasks)
+	assert.Equal(t, []string{"Unable to list stopped tasks, only running tasks will be reported"}, log.warnings)
+	// the tasks that stopped since the previous poll are listed again next time
+	assert.Equal(t, previousPoll, polls.previous(cluster1ARN))
+}
+
+func TestGetInventoryReportForClusterWithStoppedTasks(t *testing.T) {
+	polls := newPollTimes()
+	polls.record(cluster1ARN, previousPoll)
+	client := &describeCountingECSClient{stoppedTasksECSClient: stoppedTasksECSClient{}}
+
+	report, err := inventoryReportForCluster(context.Background(), cluster1ARN, client, CollectOptions{StoppedTasks: true}, polls)
+	require.NoError(t, err)
+
+	stopped := map[string]bool{}
+	for _, task := range report.Tasks {
+		stopped[task.ARN] = task.Stopped
+	}
+	assert.Equal(t, map[string]bool{
+		runningTaskARN: false,
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster-1/12345678-1234-1234-1234-111111111111": false,
+		stoppedTaskARN:  true,
+		stoppingTaskARN: false,
+	}, stopped)
+	assert.Len(t, report.Containers, 6)
+	for _, task := range report.Tasks {
+		if task.ARN == stoppedTaskARN {
+			assert.Equal(t, "EssentialContainerExited", task.StopCode)
+			assert.Equal(t, "Essential container in task exited", task.StoppedReason)
+			assert.Equal(t, "2024-01-02T03:01:00Z", task.StoppedAt)
+		}
+	}
+	// the running tasks and the stopped tasks are each described once
+	assert.Equal(t, 2, client.describeTasksCalls)
+
+	// the next poll only adds the tasks that stopped since this one
+	report, err = inventoryReportForCluster(context.Background(), cluster1ARN, client, CollectOptions{StoppedTasks: true}, polls)
+	require.NoError(t, err)
+	assert.Len(t, report.Tasks, 3)
+}
+
+// describeCountingECSClient counts the calls to DescribeTasks
+type describeCountingECSClient struct {
+	stoppedTasksECSClient
+	describeTasksCalls int
+}
+
+func (m *describeCountingECSClient) DescribeTasks(ctx context.Context, input *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	m.describeTasksCalls++
+	return m.stoppedTasksECSClient.DescribeTasks(ctx, input, optFns...)
+}
@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	pkgLog "github.com/anchore/ecs-inventory/pkg/logger"
+	"github.com/anchore/ecs-inventory/pkg/reporter"
+)
+
+// maxCachedTaskDefinitions bounds the task definition cache, when it is full it is emptied and refilled from the tasks
+// that are still running
+const maxCachedTaskDefinitions = 1000
+
+// taskDefinitionCache holds the task definitions described so far, by ARN. A task definition revision can't be
+// changed once it is registered, so a cached task definition never goes stale and is kept across polling cycles.
+type taskDefinitionCache struct {
+	mu          sync.Mutex
+	definitions map[string]*ecstypes.TaskDefinition
+}
+
+func newTaskDefinitionCache() *taskDefinitionCache {
+	return &taskDefinitionCache{definitions: map[string]*ecstypes.TaskDefinition{}}
+}
+
+// taskDefinitions is shared by every cluster and polling cycle, since many tasks (across clusters) run the same task
+// definition
+var taskDefinitions = newTaskDefinitionCache()
+
+// get returns the task definition with the given ARN, describing it if it is not already cached
+func (c *taskDefinitionCache) get(ctx context.Context, client ECSAPI, arn string) (*ecstypes.TaskDefinition, error) {
+	c.mu.Lock()
+	definition, ok := c.definitions[arn]
+	c.mu.Unlock()
+	if ok {
+		return definition, nil
+	}
+
+	definition, err := describeTaskDefinition(ctx, client, arn)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.definitions) >= maxCachedTaskDefinitions {
+		c.definitions = map[string]*ecstypes.TaskDefinition{}
+	}
+	c.definitions[arn] = definition
+	return definition, nil
+}
+
+// describeTaskDefinition describes a task definition given by family, family:revision or ARN
+func describeTaskDefinition(ctx context.Context, client ECSAPI, name string) (*ecstypes.TaskDefinition, error) {
+	result, err := client.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	if result.TaskDefinition == nil {
+		return nil, fmt.Errorf("task definition not found: %s", name)
+	}
+	return result.TaskDefinition, nil
+}
+
+// taskDefinitionCollector collects the details of a cluster that come from the task definitions of its tasks, when
+// enabled: the posture of each task, and the environment variables of each task definition that look like secrets.
+// Task definitions that can't be described are logged once for the cluster and the details that come from them are
+// not reported.
+type taskDefinitionCollector struct {
+	collect CollectOptions
+	failed  map[string]error
+	// secrets are the secret findings of each task definition scanned so far, by task definition ARN
+	secrets map[string][]reporter.SecretFinding
+}
+
+func newTaskDefinitionCollector(collect CollectOptions) *taskDefinitionCollector {
+	return &taskDefinitionCollector{
+		collect: collect,
+		failed:  map[string]error{},
+		secrets: map[string][]reporter.SecretFinding{},
+	}
+}
+
+func (c *taskDefinitionCollector) enabled() bool {
+	return c != nil && (c.collect.TaskDefinitions || c.collect.EnvironmentSecrets)
+}
+
+// addTask adds the details that come from the task's task definition to the task, and to the collector
+func (c *taskDefinitionCollector) addTask(ctx context.Context, client ECSAPI, t *reporter.Task, task ecstypes.Task) {
+	arn := aws.ToString(task.TaskDefinitionArn)
+	if !c.enabled() || arn == "" {
+		return
+	}
+	if _, ok := c.failed[arn]; ok {
+		return
+	}
+	definition, err := taskDefinitions.get(ctx, client, arn)
+	if err != nil {
+		c.failed[arn] = err
+		return
+	}
+	if c.collect.TaskDefinitions {
+		t.Posture = taskPosture(task, definition)
+	}
+	if _, ok := c.secrets[arn]; c.collect.EnvironmentSecrets && !ok {
+		c.secrets[arn] = scanEnvironment(arn, definition)
+	}
+}
+
+// secretFindings returns the secret findings of every task definition, ordered by task definition
+func (c *taskDefinitionCollector) secretFindings() []reporter.SecretFinding {
+	if c == nil {
+		return nil
+	}
+	var findings []reporter.SecretFinding
+	for _, arn := range sortedKeys(c.secrets) {
+		findings = append(findings, c.secrets[arn]...)
+	}
+	return findings
+}
+
+func (c *taskDefinitionCollector) log(log pkgLog.Logger) {
+	if c == nil {
+		return
+	}
+	if len(c.failed) > 0 {
+		arns := sortedKeys(c.failed)
+		log.Warn(
+			"Unable to describe task definitions, tasks running them will be reported without their task definition details",
+			"taskDefinitionCount", len(arns),
+			"taskDefinitions", arns,
+			"err", c.failed[arns[0]],
+		)
+	}
+	var withSecrets []string
+	for _, arn := range sortedKeys(c.secrets) {
+		if len(c.secrets[arn]) > 0 {
+			withSecrets = append(withSecrets, arn)
+		}
+	}
+	if len(withSecrets) > 0 {
+		log.Warn(
+			"Task definitions have environment variables that look like secrets, store them as secrets instead",
+			"taskDefinitionCount", len(withSecrets),
+			"taskDefinitions", withSecrets,
+		)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package inventory
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/anchore/ecs-inventory/internal/tracing"
+)
+
+// withTracing creates a span for every ECS API call, covering all attempts when a call is retried
+func withTracing(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+		"ECSInventoryTracing",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			ctx, span := tracing.Tracer().Start(ctx, "ECS."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", "ECS"),
+					attribute.String("rpc.method", operation),
+					tracing.Region.String(awsmiddleware.GetRegion(ctx)),
+				),
+			)
+
+			out, metadata, err := next.HandleInitialize(ctx, in)
+
+			if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+				span.SetAttributes(attribute.String("aws.request_id", requestID))
+			}
+			tracing.End(span, err)
+			return out, metadata, err
+		},
+	), middleware.After)
+}
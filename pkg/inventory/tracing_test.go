@@ -0,0 +1,97 @@
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/anchore/ecs-inventory/internal/tracing"
+)
+
+// recordSpans installs a tracer provider that records every span for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestWithTracing(t *testing.T) {
+	recorder := recordSpans(t)
+	httpClient := &sequenceHTTPClient{responses: []*http.Response{
+		jsonResponse(200, `{"clusterArns":["arn:aws:ecs:us-east-1:123456789012:cluster/cluster-1"]}`),
+		jsonResponse(400, `{"__type":"ClusterNotFoundException","message":"Cluster not found."}`),
+	}}
+	client := ecs.New(ecs.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  httpClient,
+		Retryer:     aws.NopRetryer{},
+		APIOptions:  []func(*middleware.Stack) error{withTracing},
+	})
+
+	_, err := client.ListClusters(context.Background(), &ecs.ListClustersInput{})
+	require.NoError(t, err)
+	_, err = client.ListTasks(context.Background(), &ecs.ListTasksInput{})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "ECS.ListClusters", spans[0].Name())
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "ListClusters", attrs["rpc.method"].AsString())
+	assert.Equal(t, "us-east-1", attrs[tracing.Region].AsString())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, "ECS.ListTasks", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestGetInventoryReportForClusterIsTraced(t *testing.T) {
+	recorder := recordSpans(t)
+
+	report, err := GetInventoryReportForCluster(context.Background(), "cluster-1", &mockECSClient{})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GetInventoryReportForCluster", spans[0].Name())
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "cluster-1", attrs[tracing.Cluster].AsString())
+	assert.Equal(t, int64(len(report.Containers)), attrs[tracing.Containers].AsInt64())
+}
+
+func TestGetInventoryReportForClusterTracesErrors(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, err := GetInventoryReportForCluster(context.Background(), "cluster-1", &mockECSClient{ErrorOnListTasks: true})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
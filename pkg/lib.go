@@ -1,8 +1,14 @@
 package pkg
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
+	"github.com/anchore/ecs-inventory/internal/health"
+	internalLogger "github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/metrics"
 	"github.com/anchore/ecs-inventory/pkg/connection"
 	"github.com/anchore/ecs-inventory/pkg/inventory"
 	"github.com/anchore/ecs-inventory/pkg/logger"
@@ -10,26 +16,113 @@ import (
 
 var log logger.Logger
 
+// Options configures what inventory is gathered and how it is reported
+type Options struct {
+	Region          string
+	PollingInterval time.Duration
+	AnchoreDetails  connection.AnchoreInfo
+	Collect         inventory.CollectOptions
+	// IntrospectionURL is the ECS agent introspection API to read the inventory of a single container instance from,
+	// when empty the inventory of the whole region is read from the ECS API
+	IntrospectionURL string
+	// DockerSocket is the Docker daemon socket the image digests of a single container instance are resolved with
+	DockerSocket string
+	Quiet            bool
+	DryRun           bool
+}
+
 // PeriodicallyGetInventoryReport periodically retrieve image results and report/output them according to the configuration.
 // Note: Errors do not cause the function to exit, since this is periodically running
+//
+// Deprecated: use PeriodicallyGetInventoryReportWithOptions, which can be stopped and picks up reloaded options.
 func PeriodicallyGetInventoryReport(
 	pollingIntervalSeconds int,
 	anchoreDetails connection.AnchoreInfo,
 	region string,
 	quiet, dryRun bool,
 ) {
+	opts := Options{
+		Region:          region,
+		PollingInterval: time.Duration(pollingIntervalSeconds) * time.Second,
+		AnchoreDetails:  anchoreDetails,
+		Quiet:           quiet,
+		DryRun:          dryRun,
+	}
+	PeriodicallyGetInventoryReportWithOptions(context.Background(), func() Options {
+		return opts
+	})
+}
+
+// PeriodicallyGetInventoryReportWithOptions periodically retrieve image results and report/output them according to
+// the options. The options are fetched once at the start of every polling cycle, so reloaded options take effect from the next
+// cycle onwards.
+// Note: Errors do not cause the function to exit, since this is periodically running. It returns once the context is
+// cancelled.
+func PeriodicallyGetInventoryReportWithOptions(ctx context.Context, getOptions func() Options) {
+	opts := getOptions()
+	pollingInterval := opts.PollingInterval
+	// the poller is kept across cycles, so that each cycle only reports the tasks that stopped since the previous one
+	poller := inventory.NewRegionPoller()
+
 	// Fire off a ticker that reports according to a configurable polling interval
-	ticker := time.NewTicker(time.Duration(pollingIntervalSeconds) * time.Second)
+	ticker := time.NewTicker(pollingInterval)
+	defer ticker.Stop()
 
 	for {
-		err := inventory.GetInventoryReportsForRegion(region, anchoreDetails, quiet, dryRun)
+		start := time.Now()
+		// every message logged during the cycle carries the run ID, so a cycle can be followed across clusters
+		cycleCtx := internalLogger.NewContext(ctx, internalLogger.With(log, "run_id", newRunID()))
+		health.Default.CycleStarted(opts.Region, pollingInterval)
+		err := getInventoryReports(cycleCtx, poller, opts)
+		if ctx.Err() != nil {
+			// stopped part way through the cycle
+			return
+		}
+		health.Default.CycleFinished(err)
+		metrics.ObserveCycle(start, err)
 		if err != nil {
 			log.Error("Failed to get Inventory Reports for region", err)
 		}
 
 		// Wait at least as long as the ticker
-		log.Debugf("Start new gather %s", <-ticker.C)
+		select {
+		case <-ctx.Done():
+			return
+		case tick := <-ticker.C:
+			log.Debugf("Start new gather %s", tick)
+		}
+
+		opts = getOptions()
+		if interval := opts.PollingInterval; interval != pollingInterval {
+			log.Info("Polling interval changed", "previous", pollingInterval.String(), "current", interval.String())
+			pollingInterval = interval
+			ticker.Reset(pollingInterval)
+		}
+	}
+}
+
+// getInventoryReports reports the inventory of the region, or in daemon mode of the container instance the agent runs on
+func getInventoryReports(ctx context.Context, poller *inventory.RegionPoller, opts Options) error {
+	if opts.IntrospectionURL != "" {
+		return inventory.ReportInstanceInventory(ctx, opts.IntrospectionURL, opts.DockerSocket, opts.AnchoreDetails, opts.Quiet, opts.DryRun)
 	}
+	return poller.GetInventoryReports(ctx, opts.Region, opts.AnchoreDetails, opts.Collect, opts.Quiet, opts.DryRun)
+}
+
+// ConsumeInventoryEvents keeps the inventory up to date from the ECS events delivered to the SQS queue of events,
+// reporting the clusters that changed as events arrive and polling the whole region every reconcile interval. The
+// options are only read at the start, so reloaded options take effect once restarted. It returns once the context is
+// cancelled, or if the ECS and SQS clients can't be created.
+func ConsumeInventoryEvents(ctx context.Context, opts Options, events inventory.EventOptions) error {
+	ctx = internalLogger.NewContext(ctx, log)
+	return inventory.ConsumeEvents(ctx, opts.Region, opts.AnchoreDetails, opts.Collect, events, opts.Quiet, opts.DryRun)
+}
+
+// newRunID returns a random identifier for a polling cycle
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func SetLogger(logger logger.Logger) {
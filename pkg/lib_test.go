@@ -1,7 +1,9 @@
 package pkg
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -22,3 +24,29 @@ func TestSetLogger(t *testing.T) {
 	SetLogger(mock)
 	assert.Equal(t, logger.Logger(mock), log)
 }
+
+func TestNewRunID(t *testing.T) {
+	id := newRunID()
+	assert.Len(t, id, 16)
+	assert.NotEqual(t, id, newRunID())
+}
+
+func TestPeriodicallyGetInventoryReportWithOptionsReturnsWhenCancelled(t *testing.T) {
+	SetLogger(&mockLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		PeriodicallyGetInventoryReportWithOptions(ctx, func() Options {
+			return Options{PollingInterval: time.Hour, IntrospectionURL: "http://localhost:0", DryRun: true, Quiet: true}
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("polling did not stop once the context was cancelled")
+	}
+}
@@ -8,3 +8,10 @@ type Logger interface {
 	Debug(msg string, args ...interface{})
 	Debugf(msg string, args ...interface{})
 }
+
+// FieldLogger is optionally implemented by a Logger that can add key-value pairs, such as the cluster being
+// inventoried, to every message. Messages logged with a Logger that doesn't implement it are logged without them.
+type FieldLogger interface {
+	// With returns a logger that adds the given key-value pairs to every message
+	With(args ...interface{}) Logger
+}
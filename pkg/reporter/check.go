@@ -0,0 +1,109 @@
+package reporter
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/h2non/gock"
+
+	"github.com/anchore/ecs-inventory/pkg/connection"
+)
+
+var (
+	ErrUnauthorized = errors.New("anchore rejected the credentials")
+	ErrForbidden    = errors.New("anchore user is not permitted to access the account")
+	// ErrPartialReportsUnsupported is returned for Anchore deployments that partial reports must not be sent to
+	ErrPartialReportsUnsupported = errors.New("partial reports need the v2 API of Anchore Enterprise 5.0 or later")
+)
+
+func newHTTPClient(anchoreDetails connection.AnchoreInfo) *http.Client {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
+	} // #nosec G402
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
+	}
+	gock.InterceptClient(client) // Required to use gock for testing custom client
+	return client
+}
+
+// FetchVersion retrieves the Anchore version information, the version endpoint does not require authentication so
+// this only checks that Anchore is reachable
+func FetchVersion(anchoreDetails connection.AnchoreInfo) (AnchoreVersion, error) {
+	versionEndpoint, err := url.JoinPath(anchoreDetails.URL, "version")
+	if err != nil {
+		return AnchoreVersion{}, fmt.Errorf("failed to parse API URL: %w", err)
+	}
+
+	resp, err := newHTTPClient(anchoreDetails).Get(versionEndpoint)
+	if err != nil {
+		return AnchoreVersion{}, fmt.Errorf("failed to contact Anchore API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return AnchoreVersion{}, fmt.Errorf("failed to retrieve Anchore API version: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AnchoreVersion{}, fmt.Errorf("failed to read Anchore API version: %w", err)
+	}
+
+	ver := AnchoreVersion{}
+	if err := json.Unmarshal(body, &ver); err != nil {
+		return AnchoreVersion{}, fmt.Errorf("failed to parse API version: %w", err)
+	}
+	return ver, nil
+}
+
+// CheckAuth verifies that the Anchore credentials are valid and can be used with the configured account, by fetching
+// the account of the authenticated user from the given API version
+func CheckAuth(anchoreDetails connection.AnchoreInfo, version AnchoreVersion) error {
+	apiVersion := "v1"
+	if version.API.Version == "2" {
+		apiVersion = "v2"
+	}
+	accountEndpoint, err := url.JoinPath(anchoreDetails.URL, apiVersion, "account")
+	if err != nil {
+		return fmt.Errorf("failed to parse API URL: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, accountEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request to check Anchore credentials: %w", err)
+	}
+	req.SetBasicAuth(anchoreDetails.User, anchoreDetails.Password)
+	req.Header.Set("x-anchore-account", anchoreDetails.Account)
+
+	resp, err := newHTTPClient(anchoreDetails).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact Anchore API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("failed to check Anchore credentials: %s", resp.Status)
+	}
+	return nil
+}
+
+// CheckPartialReports verifies that partial reports of a single container instance can be sent to Anchore, which
+// needs the v2 ECS inventory API. The v1 API predates partial reports and takes every report as the inventory of the
+// whole cluster.
+func CheckPartialReports(version AnchoreVersion) error {
+	if version.API.Version != "2" {
+		return fmt.Errorf("%w, got API version %q", ErrPartialReportsUnsupported, version.API.Version)
+	}
+	return nil
+}
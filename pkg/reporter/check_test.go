@@ -0,0 +1,114 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/ecs-inventory/pkg/connection"
+)
+
+var checkAnchoreDetails = connection.AnchoreInfo{
+	URL:      "https://ancho.re",
+	User:     "admin",
+	Password: "foobar",
+	Account:  "test",
+	HTTP: connection.HTTPConfig{
+		TimeoutSeconds: 10,
+		Insecure:       true,
+	},
+}
+
+func TestFetchVersion(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://ancho.re").
+		Get("/version").
+		Reply(200).
+		JSON(map[string]interface{}{
+			"api":     map[string]interface{}{"version": "2"},
+			"service": map[string]interface{}{"version": "5.0.0"},
+		})
+
+	ver, err := FetchVersion(checkAnchoreDetails)
+	require.NoError(t, err)
+	assert.Equal(t, "2", ver.API.Version)
+	assert.Equal(t, "5.0.0", ver.Service.Version)
+
+	gock.New("https://ancho.re").
+		Get("/version").
+		Reply(503)
+
+	_, err = FetchVersion(checkAnchoreDetails)
+	assert.ErrorContains(t, err, "503")
+}
+
+func TestCheckAuth(t *testing.T) {
+	v2 := AnchoreVersion{}
+	v2.API.Version = "2"
+
+	tests := []struct {
+		name    string
+		version AnchoreVersion
+		path    string
+		status  int
+		wantErr error
+	}{
+		{
+			name:    "valid credentials against v2",
+			version: v2,
+			path:    "/v2/account",
+			status:  200,
+		},
+		{
+			name:   "valid credentials against v1",
+			path:   "/v1/account",
+			status: 200,
+		},
+		{
+			name:    "invalid credentials",
+			version: v2,
+			path:    "/v2/account",
+			status:  401,
+			wantErr: ErrUnauthorized,
+		},
+		{
+			name:    "no access to account",
+			version: v2,
+			path:    "/v2/account",
+			status:  403,
+			wantErr: ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			gock.New("https://ancho.re").
+				Get(tt.path).
+				MatchHeader("x-anchore-account", "test").
+				BasicAuth("admin", "foobar").
+				Reply(tt.status).
+				JSON(map[string]interface{}{})
+
+			err := CheckAuth(checkAnchoreDetails, tt.version)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func TestCheckPartialReports(t *testing.T) {
+	v2 := AnchoreVersion{}
+	v2.API.Version = "2"
+	assert.NoError(t, CheckPartialReports(v2))
+
+	v1 := AnchoreVersion{}
+	v1.API.Version = "1"
+	assert.ErrorIs(t, CheckPartialReports(v1), ErrPartialReportsUnsupported)
+}
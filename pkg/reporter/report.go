@@ -1,18 +1,121 @@
 package reporter
 
 type Report struct {
-	Timestamp  string      `json:"timestamp"` // Should be generated using time.Now.UTC() and formatted according to RFC Y-M-DTH:M:SZ
-	ClusterARN string      `json:"cluster_arn"`
+	Timestamp  string `json:"timestamp"` // Should be generated using time.Now.UTC() and formatted according to RFC Y-M-DTH:M:SZ
+	ClusterARN string `json:"cluster_arn"`
+	AccountID  string `json:"account_id,omitempty"`
+	Region     string `json:"region,omitempty"`
+	// Partial is set on reports that only include the tasks and containers on one container instance of the cluster,
+	// the one in ContainerInstanceARN. Only sent in daemon mode, and only to the v2 API, see CheckPartialReports.
+	Partial              bool   `json:"partial,omitempty"`
+	ContainerInstanceARN string `json:"container_instance_arn,omitempty"`
+	// Cluster is the cluster's own metadata, it is not set if the cluster could not be described
+	Cluster    *Cluster    `json:"cluster,omitempty"`
 	Containers []Container `json:"containers,omitempty"`
 	Tasks      []Task      `json:"tasks,omitempty"`
 	Services   []Service   `json:"services,omitempty"`
+	// AgentUpgrades are the container instances in the cluster whose ECS agent needs upgrading to report image digests
+	AgentUpgrades []AgentUpgrade `json:"agent_upgrades,omitempty"`
+	// SecretFindings are the environment variables in the task definitions of the cluster's tasks that look like secrets
+	SecretFindings []SecretFinding `json:"secret_findings,omitempty"`
+	// ImageDrift are the images that the tasks of a service (or task definition) run more than one digest of
+	ImageDrift []ImageDrift `json:"image_drift,omitempty"`
+	// Findings are the problems with the services of the cluster that can be alerted on
+	Findings []Finding `json:"findings,omitempty"`
+	// DeployableImages are the images declared by task definitions that can be deployed to the cluster, whether or not
+	// they are running, only collected when enabled. The images in use are the ones in Containers.
+	DeployableImages []DeployableImage `json:"deployable_images,omitempty"`
+}
+
+// DeployableImage is an image declared by a container of a task definition
+type DeployableImage struct {
+	Image      string `json:"image"`
+	TaskDefARN string `json:"task_definition_arn"`
+	Container  string `json:"container"`
+	// Source is task-definition (the latest revision of an ACTIVE task definition family run by a service of the
+	// cluster) or scheduled-task (a task definition an EventBridge rule runs on the cluster)
+	Source string `json:"source"`
+	// RuleARN is the EventBridge rule that runs a scheduled task
+	RuleARN string `json:"rule_arn,omitempty"`
+}
+
+// Finding is a problem with a service
+type Finding struct {
+	// Type is outdated-task-definition (tasks of the service are running an older task definition than the service's
+	// current task definition) or stuck-deployment (the latest deployment of the service has failed, or has not
+	// replaced the tasks of older deployments in time)
+	Type       string `json:"type"`
+	ServiceARN string `json:"service_arn"`
+	// TaskDefARN is the service's current task definition
+	TaskDefARN string `json:"task_definition_arn,omitempty"`
+	// OutdatedTaskDefARNs are the older task definitions still running
+	OutdatedTaskDefARNs []string `json:"outdated_task_definition_arns,omitempty"`
+	// Tasks are the tasks running an outdated task definition
+	Tasks []string `json:"tasks,omitempty"`
+	// DeploymentID is the ID of the stuck deployment
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Message      string `json:"message"`
+}
+
+// ImageDrift is an image reference (e.g. a mutable tag such as nginx:latest) that the tasks of a service run more than
+// one digest of. Tasks that were not started by a service are grouped by task definition instead.
+type ImageDrift struct {
+	ServiceARN string `json:"service_arn,omitempty"`
+	TaskDefARN string `json:"task_definition_arn,omitempty"`
+	Image      string `json:"image"`
+	// CurrentDigest is the digest run by the most recently started task
+	CurrentDigest string        `json:"current_digest"`
+	Digests       []DigestTasks `json:"digests"`
+	// StaleTasks are the tasks running a digest other than the current digest
+	StaleTasks []string `json:"stale_tasks"`
+}
+
+// DigestTasks are the tasks running a digest of an image
+type DigestTasks struct {
+	Digest string   `json:"digest"`
+	Tasks  []string `json:"tasks"`
+}
+
+// SecretFinding is an environment variable of a container in a task definition that looks like a secret, the value of
+// the variable is never reported
+type SecretFinding struct {
+	TaskDefARN string `json:"task_definition_arn"`
+	Container  string `json:"container"`
+	Variable   string `json:"variable"`
+	// RuleIDs are the rules the variable matched, e.g. secret-name or aws-access-key-id
+	RuleIDs []string `json:"rule_ids"`
+}
+
+type Cluster struct {
+	Name   string            `json:"name"`
+	Status string            `json:"status,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	// CapacityProviders are the capacity providers associated with the cluster, e.g. FARGATE or an auto scaling group
+	CapacityProviders []string `json:"capacity_providers,omitempty"`
+	// ContainerInsights is the containerInsights setting of the cluster, one of enabled, enhanced or disabled
+	ContainerInsights            string `json:"container_insights,omitempty"`
+	RegisteredContainerInstances int    `json:"registered_container_instances"`
+	RunningTasks                 int    `json:"running_tasks"`
+	PendingTasks                 int    `json:"pending_tasks"`
+	ActiveServices               int    `json:"active_services"`
 }
 
 type Container struct {
-	ARN         string `json:"arn"`
+	// ARN is the task ARN and the container name in partial reports, e.g. "<task ARN>/nginx", as the ECS agent doesn't
+	// have the ARN of the container
+	ARN string `json:"arn"`
+	// Name is the name of the container in its task definition, only set in partial reports
+	Name        string `json:"name,omitempty"`
 	ImageDigest string `json:"image_digest"`
 	ImageTag    string `json:"image_tag"`
 	TaskARN     string `json:"task_arn,omitempty"`
+	// LastStatus and HealthStatus are the state of the container as last reported by ECS, e.g. RUNNING and HEALTHY
+	LastStatus   string `json:"last_status,omitempty"`
+	HealthStatus string `json:"health_status,omitempty"`
+	// RuntimeID is the ID of the container in the container runtime (e.g. the Docker container ID)
+	RuntimeID string `json:"runtime_id,omitempty"`
+	// ExitCode is only set once the container has exited
+	ExitCode *int `json:"exit_code,omitempty"`
 }
 
 type Task struct {
@@ -20,9 +123,141 @@ type Task struct {
 	ServiceARN string            `json:"service_arn,omitempty"`
 	Tags       map[string]string `json:"tags,omitempty"`
 	TaskDefARN string            `json:"task_definition_arn,omitempty"`
+	// LaunchType is where the task runs, one of EC2, FARGATE, EXTERNAL or MANAGED_INSTANCES
+	LaunchType string `json:"launch_type,omitempty"`
+	// PlatformVersion is the Fargate platform version the task runs on
+	PlatformVersion string `json:"platform_version,omitempty"`
+	// PlatformVersionEndOfLife is set for Fargate tasks running on a platform version that is no longer supported
+	PlatformVersionEndOfLife bool   `json:"platform_version_end_of_life,omitempty"`
+	CapacityProvider         string `json:"capacity_provider,omitempty"`
+	CPUArchitecture          string `json:"cpu_architecture,omitempty"`
+	OSFamily                 string `json:"os_family,omitempty"`
+	AvailabilityZone         string `json:"availability_zone,omitempty"`
+	// LastStatus, DesiredStatus and HealthStatus are the state of the task as last reported by ECS, a task whose desired
+	// status is STOPPED while it is still RUNNING is draining
+	LastStatus    string `json:"last_status,omitempty"`
+	DesiredStatus string `json:"desired_status,omitempty"`
+	HealthStatus  string `json:"health_status,omitempty"`
+	// CreatedAt and StartedAt are RFC3339 timestamps
+	CreatedAt string `json:"created_at,omitempty"`
+	StartedAt string `json:"started_at,omitempty"`
+	// StartedBy is what started the task, e.g. the deployment ID of a service
+	StartedBy string `json:"started_by,omitempty"`
+	// Stopped is set for tasks that have stopped, which are only reported when stopped tasks are collected. Their images
+	// are no longer in use.
+	Stopped bool `json:"stopped,omitempty"`
+	// StopCode (e.g. EssentialContainerExited) and StoppedReason are why the task stopped
+	StopCode      string `json:"stop_code,omitempty"`
+	StoppedReason string `json:"stopped_reason,omitempty"`
+	// StoppingAt and StoppedAt are RFC3339 timestamps
+	StoppingAt string `json:"stopping_at,omitempty"`
+	StoppedAt  string `json:"stopped_at,omitempty"`
+	// DeploymentID is the ID of the service deployment or task set the task belongs to
+	DeploymentID string `json:"deployment_id,omitempty"`
+	// Posture is the security relevant configuration of the task, only collected when enabled
+	Posture *TaskPosture `json:"posture,omitempty"`
+	// ContainerInstance is the host the task runs on, tasks running on Fargate have none
+	ContainerInstance *ContainerInstance `json:"container_instance,omitempty"`
+}
+
+// TaskPosture is the security relevant configuration of a task, from its task definition
+type TaskPosture struct {
+	// NetworkMode is one of awsvpc, bridge, host or none, tasks using host share the network namespace of the host
+	NetworkMode string `json:"network_mode,omitempty"`
+	// PidMode and IpcMode are set to host or task when the namespace is shared, they are empty when it is not
+	PidMode string `json:"pid_mode,omitempty"`
+	IpcMode string `json:"ipc_mode,omitempty"`
+	// ExecuteCommand is set when ECS Exec is enabled, allowing commands to be run in the task's containers
+	ExecuteCommand bool               `json:"execute_command"`
+	Containers     []ContainerPosture `json:"containers,omitempty"`
+}
+
+type ContainerPosture struct {
+	Name       string `json:"name"`
+	Privileged bool   `json:"privileged"`
+	// User is the user the container runs as, if empty it runs as the user set in the image (which is root unless the
+	// image sets another user)
+	User string `json:"user,omitempty"`
+	// RunsAsRoot is set when the container is configured to run as root, either by name or by UID
+	RunsAsRoot             bool        `json:"runs_as_root"`
+	ReadonlyRootFilesystem bool        `json:"readonly_root_filesystem"`
+	CapabilitiesAdded      []string    `json:"capabilities_added,omitempty"`
+	CapabilitiesDropped    []string    `json:"capabilities_dropped,omitempty"`
+	HostMounts             []HostMount `json:"host_mounts,omitempty"`
+}
+
+// HostMount is a path on the host that is mounted into a container
+type HostMount struct {
+	SourcePath    string `json:"source_path"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+}
+
+// ContainerInstance is an EC2 instance (or external host) registered with a cluster, that tasks are placed on
+type ContainerInstance struct {
+	ARN           string `json:"arn"`
+	EC2InstanceID string `json:"ec2_instance_id,omitempty"`
+	AgentVersion  string `json:"agent_version,omitempty"`
+	DockerVersion string `json:"docker_version,omitempty"`
+	AMIID         string `json:"ami_id,omitempty"`
+	// CPUArchitecture and OSFamily are the ecs.cpu-architecture and ecs.os-family attributes of the instance
+	CPUArchitecture string `json:"cpu_architecture,omitempty"`
+	OSFamily        string `json:"os_family,omitempty"`
+}
+
+// AgentUpgrade is a container instance whose ECS agent is too old to report the image digests of its containers
+type AgentUpgrade struct {
+	ContainerInstance
+	// ContainersMissingDigest is the number of containers on the instance whose image digest was not reported
+	ContainersMissingDigest int `json:"containers_missing_digest"`
 }
 
 type Service struct {
-	ARN  string            `json:"arn"`
-	Tags map[string]string `json:"tags,omitempty"`
+	ARN          string            `json:"arn"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	DesiredCount int               `json:"desired_count,omitempty"`
+	RunningCount int               `json:"running_count,omitempty"`
+	PendingCount int               `json:"pending_count,omitempty"`
+	// TaskDefARN is the task definition of the primary deployment
+	TaskDefARN string `json:"task_definition_arn,omitempty"`
+	// DeploymentController is one of ECS, CODE_DEPLOY or EXTERNAL
+	DeploymentController string `json:"deployment_controller,omitempty"`
+	// SchedulingStrategy is one of REPLICA or DAEMON
+	SchedulingStrategy string `json:"scheduling_strategy,omitempty"`
+	// Deployments are the active deployments of a service using the ECS deployment controller
+	Deployments []Deployment `json:"deployments,omitempty"`
+	// TaskSets are the task sets of a service using the CODE_DEPLOY or EXTERNAL deployment controller
+	TaskSets []TaskSet `json:"task_sets,omitempty"`
+}
+
+type Deployment struct {
+	ID string `json:"id"`
+	// Status is one of PRIMARY, ACTIVE (an older deployment that is being replaced) or INACTIVE
+	Status       string `json:"status,omitempty"`
+	TaskDefARN   string `json:"task_definition_arn,omitempty"`
+	DesiredCount int    `json:"desired_count,omitempty"`
+	RunningCount int    `json:"running_count,omitempty"`
+	PendingCount int    `json:"pending_count,omitempty"`
+	FailedTasks  int    `json:"failed_tasks,omitempty"`
+	// RolloutState is one of IN_PROGRESS, COMPLETED or FAILED
+	RolloutState       string `json:"rollout_state,omitempty"`
+	RolloutStateReason string `json:"rollout_state_reason,omitempty"`
+	// CreatedAt and UpdatedAt are RFC3339 timestamps
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+type TaskSet struct {
+	ID  string `json:"id"`
+	ARN string `json:"arn,omitempty"`
+	// Status is one of PRIMARY, ACTIVE (e.g. the green task set of a blue/green deployment) or DRAINING
+	Status     string `json:"status,omitempty"`
+	TaskDefARN string `json:"task_definition_arn,omitempty"`
+	// ExternalID is the ID given by the deployment controller, e.g. the CodeDeploy deployment ID
+	ExternalID   string `json:"external_id,omitempty"`
+	DesiredCount int    `json:"desired_count,omitempty"`
+	RunningCount int    `json:"running_count,omitempty"`
+	PendingCount int    `json:"pending_count,omitempty"`
+	// StabilityStatus is one of STEADY_STATE or STABILIZING
+	StabilityStatus string `json:"stability_status,omitempty"`
 }
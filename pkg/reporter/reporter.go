@@ -3,7 +3,7 @@ package reporter
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,9 +11,10 @@ import (
 	"net/url"
 	"time"
 
-	"github.com/h2non/gock"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/anchore/ecs-inventory/internal/logger"
+	"github.com/anchore/ecs-inventory/internal/tracing"
 	"github.com/anchore/ecs-inventory/internal/tracker"
 	"github.com/anchore/ecs-inventory/pkg/connection"
 )
@@ -23,19 +24,27 @@ const v2ReportAPIPath = "v2/ecs-inventory"
 
 var apiPath = v2ReportAPIPath
 
+// Post reports the inventory to Anchore
 func Post(report Report, anchoreDetails connection.AnchoreInfo) error {
-	logger.Log.Info("Reporting results to Anchore")
+	return PostWithContext(context.Background(), report, anchoreDetails)
+}
+
+// PostWithContext reports the inventory to Anchore, logging with the logger of the context and tracing the request as
+// part of the context's span
+func PostWithContext(ctx context.Context, report Report, anchoreDetails connection.AnchoreInfo) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "reporter.Post", trace.WithAttributes(
+		tracing.Cluster.String(report.ClusterARN),
+		tracing.Tasks.Int(len(report.Tasks)),
+		tracing.Services.Int(len(report.Services)),
+		tracing.Containers.Int(len(report.Containers)),
+	))
+	defer func() { tracing.End(span, err) }()
+	log := logger.FromContext(ctx)
+	log.Info("Reporting results to Anchore")
 	defer tracker.TrackFunctionTime(time.Now(), fmt.Sprintf("Posting Inventory Report for cluster %s", report.ClusterARN))
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
-	} // #nosec G402
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
-	}
-	gock.InterceptClient(client)
+	client := newHTTPClient(anchoreDetails)
 
-	req, err := prepareRequest(report, anchoreDetails)
+	req, err := prepareRequest(ctx, report, anchoreDetails)
 
 	if err != nil {
 		return err
@@ -66,8 +75,8 @@ func Post(report Report, anchoreDetails connection.AnchoreInfo) error {
 		}
 
 		if apiPath != previousAPIPath {
-			logger.Log.Info("Retrying inventory report with new endpoint", "apiEndpoint", apiEndpoint)
-			return Post(report, anchoreDetails)
+			log.Info("Retrying inventory report with new endpoint", "apiEndpoint", apiEndpoint)
+			return PostWithContext(ctx, report, anchoreDetails)
 		}
 
 		return fmt.Errorf("failed to report data to Anchore: %+v", resp)
@@ -82,26 +91,26 @@ func Post(report Report, anchoreDetails connection.AnchoreInfo) error {
 		return fmt.Errorf("failed to read response from Anchore: %w", err)
 	}
 	if len(respBody) > 0 && !json.Valid(respBody) {
-		logger.Log.Debug("Anchore response body: ", string(respBody))
+		log.Debug("Anchore response body: ", string(respBody))
 		return fmt.Errorf("failed to report data to Anchore not a valid json response: %+v", resp)
 	}
-	logger.Log.Debug("Successfully reported results to Anchore")
+	log.Debug("Successfully reported results to Anchore")
 	return nil
 }
 
-func prepareRequest(report Report, anchoreDetails connection.AnchoreInfo) (*http.Request, error) {
+func prepareRequest(ctx context.Context, report Report, anchoreDetails connection.AnchoreInfo) (*http.Request, error) {
 	apiEndpoint, err := url.JoinPath(anchoreDetails.URL, apiPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse API URL: %w", err)
 	}
-	logger.Log.Debug("Reporting results to Anchore", "Endpoint", apiEndpoint)
+	logger.FromContext(ctx).Debug("Reporting results to Anchore", "Endpoint", apiEndpoint)
 
 	reqBody, err := json.Marshal(report)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize results as JSON: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiEndpoint, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request to report data to Anchore: %w", err)
 	}
@@ -126,37 +135,9 @@ type AnchoreVersion struct {
 
 func fetchVersionedAPIPath(anchoreDetails connection.AnchoreInfo) (string, error) {
 	logger.Log.Debug("Detecting Anchore API version")
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: anchoreDetails.HTTP.Insecure},
-	} // #nosec G402
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(anchoreDetails.HTTP.TimeoutSeconds) * time.Second,
-	}
-	gock.InterceptClient(client) // Required to use gock for testing custom client
-
-	versionEndpoint, err := url.JoinPath(anchoreDetails.URL, "version")
-	if err != nil {
-		return v1ReportAPIPath, fmt.Errorf("failed to parse API URL: %w", err)
-	}
-
-	resp, err := client.Get(versionEndpoint)
-	if err != nil {
-		return v1ReportAPIPath, fmt.Errorf("failed to contact Anchore API: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return v1ReportAPIPath, fmt.Errorf("failed to retrieve Anchore API version: %+v", resp)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return v1ReportAPIPath, fmt.Errorf("failed to read Anchore API version: %w", err)
-	}
-
-	ver := AnchoreVersion{}
-	err = json.Unmarshal(body, &ver)
+	ver, err := FetchVersion(anchoreDetails)
 	if err != nil {
-		return v1ReportAPIPath, fmt.Errorf("failed to parse API version: %w", err)
+		return v1ReportAPIPath, err
 	}
 
 	logger.Log.Debugf("Anchore API version: %v", ver)
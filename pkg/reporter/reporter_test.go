@@ -1,6 +1,7 @@
 package reporter
 
 import (
+	"context"
 	"io"
 	"testing"
 
@@ -234,7 +235,7 @@ func Test_prepareRequest(t *testing.T) {
 		Account:  "testaccount",
 	}
 
-	req, err := prepareRequest(report, anchoreDetails)
+	req, err := prepareRequest(context.Background(), report, anchoreDetails)
 	require.NoError(t, err)
 
 	// Verify URL